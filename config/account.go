@@ -2,9 +2,11 @@ package config
 
 import (
 	"io"
+	"log"
 	"runtime"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 type AccountConfig struct {
@@ -12,7 +14,25 @@ type AccountConfig struct {
 	Password   string
 	PrivateKey []byte
 	Passphrase []byte
-	Charset    string
+	// Certificate of the PrivateKey in authorized_keys format, e.g. the content of id_ed25519-cert.pub
+	Certificate []byte
+	// Signers are tried before PrivateKey, e.g. the certificates minted for the web user
+	Signers []ssh.Signer
+	Charset string
+
+	// HostKeyPolicy defaults to Default.HostKeyPolicy
+	HostKeyPolicy HostKeyPolicy
+	// HostKeyCallback overrides HostKeyPolicy if not nil
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Agent signs the authentication with its keys, see SystemAgent and NewKeyring
+	Agent agent.Agent
+	// ForwardAgent forwards Agent to the sessions, so that the user can hop onward from the host
+	ForwardAgent bool
+
+	// Prompter asks for the missing password, the keyboard-interactive challenges and the unknown host keys.
+	// It defaults to TerminalPrompter over the reader and writer of NewSSHStandard.
+	Prompter Prompter
 }
 
 func (a *AccountConfig) SetDefault() *AccountConfig {
@@ -23,6 +43,14 @@ func (a *AccountConfig) SetDefault() *AccountConfig {
 			a.Charset = "UTF-8"
 		}
 	}
+	if len(a.HostKeyPolicy) == 0 {
+		policy, err := ParseHostKeyPolicy(Default.HostKeyPolicy)
+		if err != nil {
+			log.Println(err)
+			policy = HostKeyStrict
+		}
+		a.HostKeyPolicy = policy
+	}
 	return a
 }
 
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var ErrNoSystemAgent = errors.New("SSH_AUTH_SOCK is not set, the ssh-agent of the server is not available")
+
+// SystemAgent returns the ssh-agent of the server by SSH_AUTH_SOCK.
+// Every operation opens a new connection to the socket, so the agent never needs to be closed.
+func SystemAgent() (agent.ExtendedAgent, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if len(socket) == 0 {
+		return nil, ErrNoSystemAgent
+	}
+	return NewSocketAgent(socket), nil
+}
+
+func NewSocketAgent(socket string) agent.ExtendedAgent {
+	return &socketAgent{socket: socket}
+}
+
+// KeyringKey is a private key added to the in-process keyring
+type KeyringKey struct {
+	PrivateKey []byte
+	Passphrase []byte
+	Comment    string
+}
+
+// NewKeyring returns an in-process agent holding the keys
+func NewKeyring(keys ...KeyringKey) (agent.Agent, error) {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		var raw interface{}
+		var err error
+		if len(key.Passphrase) > 0 {
+			raw, err = ssh.ParseRawPrivateKeyWithPassphrase(key.PrivateKey, key.Passphrase)
+		} else {
+			raw, err = ssh.ParseRawPrivateKey(key.PrivateKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = keyring.Add(agent.AddedKey{PrivateKey: raw, Comment: key.Comment}); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+type socketAgent struct {
+	socket string
+}
+
+func (a *socketAgent) do(fn func(agent.ExtendedAgent) error) error {
+	conn, err := net.Dial("unix", a.socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(agent.NewClient(conn))
+}
+
+func (a *socketAgent) List() (keys []*agent.Key, err error) {
+	err = a.do(func(c agent.ExtendedAgent) error {
+		keys, err = c.List()
+		return err
+	})
+	return
+}
+
+func (a *socketAgent) Sign(key ssh.PublicKey, data []byte) (sig *ssh.Signature, err error) {
+	err = a.do(func(c agent.ExtendedAgent) error {
+		sig, err = c.Sign(key, data)
+		return err
+	})
+	return
+}
+
+func (a *socketAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (sig *ssh.Signature, err error) {
+	err = a.do(func(c agent.ExtendedAgent) error {
+		sig, err = c.SignWithFlags(key, data, flags)
+		return err
+	})
+	return
+}
+
+func (a *socketAgent) Add(key agent.AddedKey) error {
+	return a.do(func(c agent.ExtendedAgent) error { return c.Add(key) })
+}
+
+func (a *socketAgent) Remove(key ssh.PublicKey) error {
+	return a.do(func(c agent.ExtendedAgent) error { return c.Remove(key) })
+}
+
+func (a *socketAgent) RemoveAll() error {
+	return a.do(func(c agent.ExtendedAgent) error { return c.RemoveAll() })
+}
+
+func (a *socketAgent) Lock(passphrase []byte) error {
+	return a.do(func(c agent.ExtendedAgent) error { return c.Lock(passphrase) })
+}
+
+func (a *socketAgent) Unlock(passphrase []byte) error {
+	return a.do(func(c agent.ExtendedAgent) error { return c.Unlock(passphrase) })
+}
+
+func (a *socketAgent) Extension(extensionType string, contents []byte) (resp []byte, err error) {
+	err = a.do(func(c agent.ExtendedAgent) error {
+		resp, err = c.Extension(extensionType, contents)
+		return err
+	})
+	return
+}
+
+func (a *socketAgent) Signers() ([]ssh.Signer, error) {
+	keys, err := a.List()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]ssh.Signer, len(keys))
+	for i, key := range keys {
+		signers[i] = &agentSigner{agent: a, pub: key}
+	}
+	return signers, nil
+}
+
+// agentSigner signs by the agent, like the signers returned by agent.NewClient
+type agentSigner struct {
+	agent agent.ExtendedAgent
+	pub   ssh.PublicKey
+}
+
+func (s *agentSigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *agentSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.agent.Sign(s.pub, data)
+}
+
+func (s *agentSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	switch algorithm {
+	case ssh.KeyAlgoRSASHA256:
+		return s.agent.SignWithFlags(s.pub, data, agent.SignatureFlagRsaSha256)
+	case ssh.KeyAlgoRSASHA512:
+		return s.agent.SignWithFlags(s.pub, data, agent.SignatureFlagRsaSha512)
+	default:
+		return s.Sign(rand, data)
+	}
+}
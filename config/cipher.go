@@ -1,27 +1,231 @@
 package config
 
-import "golang.org/x/crypto/ssh"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrUnknownAlgorithmPreset = errors.New("unknown algorithm preset")
+
+// the algorithm presets
+const (
+	AlgorithmsModern     = "modern"     // AEAD ciphers, curve25519 and ETM MACs only
+	AlgorithmsCompatible = "compatible" // the defaults of golang.org/x/crypto/ssh with aes128-cbc
+	AlgorithmsLegacy     = "legacy"     // also SHA-1, group1, 3DES and arcfour for old network gear
+)
+
+// Algorithms are the algorithms offered to the host in the order of preference
+type Algorithms struct {
+	Ciphers           []string `json:"ciphers,omitempty"`
+	KeyExchanges      []string `json:"keyExchanges,omitempty"`
+	MACs              []string `json:"macs,omitempty"`
+	HostKeyAlgorithms []string `json:"hostKeyAlgorithms,omitempty"`
+}
+
+func (a *Algorithms) Clone() *Algorithms {
+	return &Algorithms{
+		Ciphers:           append([]string{}, a.Ciphers...),
+		KeyExchanges:      append([]string{}, a.KeyExchanges...),
+		MACs:              append([]string{}, a.MACs...),
+		HostKeyAlgorithms: append([]string{}, a.HostKeyAlgorithms...),
+	}
+}
+
+// Apply sets the algorithms of the client config, the empty lists get the defaults of golang.org/x/crypto/ssh.
+// The names are not filtered: the ones golang.org/x/crypto/ssh does not implement are offered but never agreed,
+// so the handshake fails with "no common algorithm" if nothing else is listed.
+func (a *Algorithms) Apply(cfg *ssh.ClientConfig) {
+	cfg.Ciphers = append([]string{}, a.Ciphers...)
+	cfg.KeyExchanges = append([]string{}, a.KeyExchanges...)
+	cfg.MACs = append([]string{}, a.MACs...)
+	cfg.HostKeyAlgorithms = append([]string{}, a.HostKeyAlgorithms...)
+	cfg.SetDefaults()
+}
 
 var (
-	defaultCiphers   = []string{"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-gcm@openssh.com", "arcfour256", "arcfour128", "aes128-cbc", "3des-cbc", "aes192-cbc", "aes256-cbc"}
-	supportedCiphers = SupportedCiphers()
+	modernHostKeyAlgorithms = []string{
+		ssh.CertAlgoED25519v01, ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+		ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+	}
+
+	algorithmPresets = map[string]*Algorithms{
+		AlgorithmsModern: {
+			Ciphers: []string{
+				"chacha20-poly1305@openssh.com", "aes256-gcm@openssh.com", "aes128-gcm@openssh.com",
+				"aes256-ctr", "aes192-ctr", "aes128-ctr",
+			},
+			KeyExchanges: []string{
+				"curve25519-sha256", "curve25519-sha256@libssh.org",
+				"diffie-hellman-group16-sha512", "diffie-hellman-group-exchange-sha256",
+			},
+			MACs:              []string{"hmac-sha2-512-etm@openssh.com", "hmac-sha2-256-etm@openssh.com"},
+			HostKeyAlgorithms: modernHostKeyAlgorithms,
+		},
+		AlgorithmsCompatible: {
+			Ciphers: []string{
+				"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+				"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-cbc",
+			},
+			KeyExchanges: []string{
+				"curve25519-sha256", "curve25519-sha256@libssh.org",
+				"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+				"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+				"diffie-hellman-group-exchange-sha256", "diffie-hellman-group14-sha1",
+			},
+			MACs: []string{
+				"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+				"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1",
+			},
+			HostKeyAlgorithms: append(append([]string{}, modernHostKeyAlgorithms...), ssh.CertAlgoRSAv01, ssh.KeyAlgoRSA),
+		},
+		AlgorithmsLegacy: {
+			Ciphers: []string{
+				"aes128-ctr", "aes192-ctr", "aes256-ctr",
+				"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+				"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+			},
+			KeyExchanges: []string{
+				"curve25519-sha256", "curve25519-sha256@libssh.org",
+				"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+				"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+				"diffie-hellman-group-exchange-sha256", "diffie-hellman-group14-sha1",
+				"diffie-hellman-group-exchange-sha1", "diffie-hellman-group1-sha1",
+			},
+			MACs: []string{
+				"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+				"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+			},
+			HostKeyAlgorithms: append(append([]string{}, modernHostKeyAlgorithms...),
+				ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA),
+		},
+	}
 )
 
+// AlgorithmPresets returns the names of the presets
+func AlgorithmPresets() []string {
+	return []string{AlgorithmsModern, AlgorithmsCompatible, AlgorithmsLegacy}
+}
+
+// AlgorithmPreset returns a copy of the preset
+func AlgorithmPreset(name string) (*Algorithms, error) {
+	preset, ok := algorithmPresets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithmPreset, name)
+	}
+	return preset.Clone(), nil
+}
+
+// DefaultAlgorithms returns the preset of Default.SSHAlgorithms, it is "compatible" if not set
+func DefaultAlgorithms() *Algorithms {
+	name := Default.SSHAlgorithms
+	if len(name) == 0 {
+		name = AlgorithmsCompatible
+	}
+	algorithms, err := AlgorithmPreset(name)
+	if err != nil {
+		log.Println(err)
+		algorithms, _ = AlgorithmPreset(AlgorithmsCompatible)
+	}
+	return algorithms
+}
+
+// SupportedCiphers returns the ciphers of the "compatible" preset
 func SupportedCiphers() []string {
-	config := &ssh.ClientConfig{}
-	config.SetDefaults()
-	for _, cipher := range []string{"aes128-cbc"} {
+	algorithms, _ := AlgorithmPreset(AlgorithmsCompatible)
+	return algorithms.Ciphers
+}
+
+// AlgorithmOptions customize a preset with the OpenSSH list syntax, see ApplyAlgorithmList
+type AlgorithmOptions struct {
+	Preset            string `json:"preset,omitempty"` // defaults to Default.SSHAlgorithms
+	Ciphers           string `json:"ciphers,omitempty"`
+	KexAlgorithms     string `json:"kexAlgorithms,omitempty"`
+	MACs              string `json:"macs,omitempty"`
+	HostKeyAlgorithms string `json:"hostKeyAlgorithms,omitempty"`
+}
+
+func (o *AlgorithmOptions) IsZero() bool {
+	return *o == AlgorithmOptions{}
+}
+
+func (o *AlgorithmOptions) Algorithms() (*Algorithms, error) {
+	var algorithms *Algorithms
+	if len(o.Preset) > 0 {
+		var err error
+		if algorithms, err = AlgorithmPreset(o.Preset); err != nil {
+			return nil, err
+		}
+	} else {
+		algorithms = DefaultAlgorithms()
+	}
+	algorithms.Ciphers = ApplyAlgorithmList(algorithms.Ciphers, o.Ciphers)
+	algorithms.KeyExchanges = ApplyAlgorithmList(algorithms.KeyExchanges, o.KexAlgorithms)
+	algorithms.MACs = ApplyAlgorithmList(algorithms.MACs, o.MACs)
+	algorithms.HostKeyAlgorithms = ApplyAlgorithmList(algorithms.HostKeyAlgorithms, o.HostKeyAlgorithms)
+	return algorithms, nil
+}
+
+// ApplyAlgorithmList applies the OpenSSH algorithm list to the defaults.
+// "+a,b" appends, "-a,b" removes (wildcards allowed), "^a,b" prepends and "a,b" replaces.
+func ApplyAlgorithmList(defaults []string, list string) []string {
+	if len(list) == 0 {
+		return defaults
+	}
+	switch list[0] {
+	case '+':
+		return appendMissing(append([]string{}, defaults...), SplitList(list[1:])...)
+	case '^':
+		return appendMissing(SplitList(list[1:]), defaults...)
+	case '-':
+		patterns := SplitList(list[1:])
+		r := make([]string, 0, len(defaults))
+		for _, name := range defaults {
+			removed := false
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, name); ok {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				r = append(r, name)
+			}
+		}
+		return r
+	default:
+		return SplitList(list)
+	}
+}
+
+func appendMissing(list []string, names ...string) []string {
+	for _, name := range names {
 		found := false
-		for _, defaultCipher := range config.Ciphers {
-			if cipher == defaultCipher {
+		for _, v := range list {
+			if v == name {
 				found = true
 				break
 			}
 		}
-
 		if !found {
-			config.Ciphers = append(config.Ciphers, cipher)
+			list = append(list, name)
 		}
 	}
-	return config.Ciphers
+	return list
+}
+
+// NegotiatedAlgorithms are the algorithms chosen by the key exchange with the host
+type NegotiatedAlgorithms struct {
+	KeyExchange  string `json:"kex"`
+	HostKey      string `json:"hostKey"`
+	Cipher       string `json:"cipher"`                 // client to server
+	MAC          string `json:"mac,omitempty"`          // client to server, empty for the AEAD ciphers
+	ServerCipher string `json:"serverCipher,omitempty"` // server to client
+	ServerMAC    string `json:"serverMac,omitempty"`    // server to client
 }
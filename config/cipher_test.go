@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestApplyAlgorithmList(t *testing.T) {
+	defaults := []string{"aes128-ctr", "aes128-cbc", "3des-cbc"}
+	tests := map[string][]string{
+		"+aes256-ctr,aes128-ctr": {"aes128-ctr", "aes128-cbc", "3des-cbc", "aes256-ctr"},
+		"-*-cbc":                 {"aes128-ctr"},
+		"^3des-cbc":              {"3des-cbc", "aes128-ctr", "aes128-cbc"},
+		"aes256-gcm@openssh.com": {"aes256-gcm@openssh.com"},
+	}
+	for list, expected := range tests {
+		if r := config.ApplyAlgorithmList(defaults, list); !reflect.DeepEqual(r, expected) {
+			t.Errorf("%s: expected %v, got %v", list, expected, r)
+		}
+	}
+}
+
+func TestAlgorithmOptions(t *testing.T) {
+	options := &config.AlgorithmOptions{Preset: config.AlgorithmsModern, MACs: "+hmac-sha1"}
+	algorithms, err := options.Algorithms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig := &ssh.ClientConfig{}
+	algorithms.Apply(clientConfig)
+	for _, cipher := range clientConfig.Ciphers {
+		if cipher == "aes128-cbc" {
+			t.Fatal("the modern preset must not offer CBC ciphers")
+		}
+	}
+	if macs := clientConfig.MACs; macs[len(macs)-1] != "hmac-sha1" {
+		t.Fatalf("hmac-sha1 is expected to be appended: %v", macs)
+	}
+
+	legacy, err := config.AlgorithmPreset(config.AlgorithmsLegacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Apply(clientConfig)
+	if kex := clientConfig.KeyExchanges; kex[len(kex)-1] != "diffie-hellman-group1-sha1" {
+		t.Fatalf("the legacy preset must offer group1: %v", kex)
+	}
+
+	options = &config.AlgorithmOptions{Preset: "ancient"}
+	if _, err = options.Algorithms(); !errors.Is(err, config.ErrUnknownAlgorithmPreset) {
+		t.Fatalf("expected the unknown preset error, got %v", err)
+	}
+}
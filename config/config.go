@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/kardianos/osext"
 )
@@ -39,6 +40,52 @@ type Config struct {
 	Password    string
 	IDFile      string
 	SHFile      string
+	LocalAllow  string
+
+	TelnetTerminalTypes string
+	TelnetEnviron       string
+	TelnetLineMode      bool
+
+	KnownHostsFile string
+	HostKeyPolicy  string
+	SSHConfigFile  string
+	SSHAlgorithms  string
+
+	SSHKeepAlive         time.Duration
+	SSHKeepAliveCountMax int
+	WSKeepAlive          time.Duration
+	WSKeepAliveCountMax  int
+
+	SessionGrace  time.Duration
+	SessionBuffer int
+	ShareTTL      time.Duration // the lifetime of the share links
+
+	ForwardAllow        string
+	ForwardDeny         string
+	ForwardPublicListen bool
+
+	Record      bool
+	RecordDir   string
+	RecordInput bool // the input is recorded too, it contains the passwords typed in the terminal
+
+	AuthTokenFile    string
+	AuthHtpasswdFile string
+	AuthJWTSecret    string
+	AllowedOrigins   string
+
+	PolicyFile string
+	AuditLog   string
+
+	TicketTTL time.Duration
+
+	VaultFile string
+	VaultKey  string
+
+	CAKeyFile         string
+	CAKeyPassphrase   string
+	CAValidity        time.Duration
+	CAPrincipals      string
+	CACriticalOptions string
 }
 
 func (c *Config) SetDefault() *Config {
@@ -51,6 +98,27 @@ func (c *Config) SetDefault() *Config {
 	if len(c.ResourceDir) == 0 {
 		c.ResourceDir = AutoResourceDir()
 	}
+	if len(c.RecordDir) == 0 {
+		c.RecordDir = AutoRecordDir(c.LogDir)
+	}
+	if len(c.AuditLog) == 0 {
+		c.AuditLog = AutoAuditLog(c.LogDir)
+	}
+	if len(c.VaultFile) == 0 {
+		c.VaultFile = AutoVaultFile()
+	}
+	if len(c.VaultKey) == 0 {
+		c.VaultKey = os.Getenv("WEB_TERMINAL_VAULT_KEY")
+	}
+	if len(c.CAKeyPassphrase) == 0 {
+		c.CAKeyPassphrase = os.Getenv("WEB_TERMINAL_CA_PASSPHRASE")
+	}
+	if len(c.AuthJWTSecret) == 0 {
+		c.AuthJWTSecret = os.Getenv("WEB_TERMINAL_JWT_SECRET")
+	}
+	if len(c.KnownHostsFile) == 0 {
+		c.KnownHostsFile = AutoKnownHostsFile()
+	}
 	if !strings.HasSuffix(c.APPRoot, "/") {
 		c.APPRoot = c.APPRoot + "/"
 	}
@@ -84,6 +152,28 @@ func AutoLogDir() string {
 	return ``
 }
 
+func AutoKnownHostsFile() string {
+	return filepath.Join(ExecutableFolder, "known_hosts")
+}
+
+func AutoVaultFile() string {
+	return filepath.Join(ExecutableFolder, "vault.json")
+}
+
+func AutoRecordDir(logDir string) string {
+	if len(logDir) > 0 {
+		return filepath.Join(logDir, "recordings")
+	}
+	return filepath.Join(ExecutableFolder, "recordings")
+}
+
+func AutoAuditLog(logDir string) string {
+	if len(logDir) > 0 {
+		return filepath.Join(logDir, "audit.log")
+	}
+	return filepath.Join(ExecutableFolder, "audit.log")
+}
+
 func AutoMIBSDir() string {
 	files := []string{"mibs",
 		filepath.Join("lib", "mibs"),
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"time"
 )
 
 func FlagParse() {
@@ -9,7 +10,41 @@ func FlagParse() {
 	flag.BoolVar(&Default.Debug, "debug", false, "show debug message.")
 	flag.StringVar(&Default.MIBSDir, "mibs_dir", "", "set mibs directory.")
 	flag.StringVar(&Default.SHExecute, "sh_execute", "bash", "the shell path")
+	flag.StringVar(&Default.LocalAllow, "local_allow", "", "comma separated programs besides sh_execute which the local protocol may run under a pseudo-terminal, e.g. top,vim,/usr/bin/htop")
+	flag.StringVar(&Default.TelnetTerminalTypes, "telnet_ttype", "xterm", "comma separated terminal types offered to the telnet servers in order of preference, e.g. vt100,ansi,IBM-3278-2")
+	flag.StringVar(&Default.TelnetEnviron, "telnet_env", "", "comma separated NAME=value variables sent to the telnet servers by NEW-ENVIRON, the tickets may override their values")
+	flag.BoolVar(&Default.TelnetLineMode, "telnet_linemode", false, "accept the LINEMODE option of the telnet servers, only the character at a time mode is agreed")
 	flag.StringVar(&Default.APPRoot, "url_prefix", "/", "url prefix")
+	flag.StringVar(&Default.KnownHostsFile, "known_hosts", "", "the OpenSSH known_hosts file used to verify host keys")
+	flag.StringVar(&Default.HostKeyPolicy, "host_key_policy", "tofu", "host key verification: strict, tofu or ignore")
+	flag.StringVar(&Default.SSHConfigFile, "ssh_config", "", "the OpenSSH config file of the host aliases which can be connected by the 'host' parameter")
+	flag.StringVar(&Default.SSHAlgorithms, "ssh_algorithms", "compatible", "the default algorithm preset of the ssh connections: modern, compatible or legacy")
+	flag.DurationVar(&Default.SSHKeepAlive, "ssh_keepalive", 30*time.Second, "the interval of the keepalive requests to the ssh hosts, 0 disables them")
+	flag.IntVar(&Default.SSHKeepAliveCountMax, "ssh_keepalive_max", 3, "the ssh connection is closed after this number of keepalive requests without reply")
+	flag.DurationVar(&Default.WSKeepAlive, "ws_keepalive", 30*time.Second, "the interval of the ping frames to the browsers, 0 disables them")
+	flag.IntVar(&Default.WSKeepAliveCountMax, "ws_keepalive_max", 3, "the websocket is closed after this number of intervals without any frame from the browser")
+	flag.DurationVar(&Default.SessionGrace, "session_grace", time.Minute, "the ssh shell is kept for this period after the websocket is disconnected and can be resumed, 0 disables resuming")
+	flag.IntVar(&Default.SessionBuffer, "session_buffer", 256*1024, "the bytes of the shell output kept for the resumed websocket")
+	flag.DurationVar(&Default.ShareTTL, "share_ttl", time.Hour, "the share links of the ssh shells can be joined for this period, the viewers joined before are kept")
+	flag.StringVar(&Default.ForwardAllow, "forward_allow", "", "comma separated destinations allowed for port forwarding, e.g. 10.0.0.0/8:80,*.internal:8000-8099")
+	flag.StringVar(&Default.ForwardDeny, "forward_deny", "", "comma separated destinations denied for port forwarding")
+	flag.BoolVar(&Default.ForwardPublicListen, "forward_public_listen", false, "allow the SOCKS5 listeners of the dynamic forwards on non-loopback addresses, they have no authentication")
+	flag.BoolVar(&Default.Record, "record", false, "record sessions in asciicast v2 format, the 'record=true' parameter of a session enables it when the flag is off")
+	flag.StringVar(&Default.RecordDir, "record_dir", "", "the directory of the session recordings")
+	flag.BoolVar(&Default.RecordInput, "record_input", false, "record the input of the sessions too, it includes the passwords typed in the terminal, e.g. for sudo")
+	flag.StringVar(&Default.AuthTokenFile, "auth_tokens", "", "the static token file, each line is 'token name [role1,role2]'")
+	flag.StringVar(&Default.AuthHtpasswdFile, "auth_htpasswd", "", "the htpasswd file for HTTP Basic authentication (bcrypt or {SHA}), an optional third field lists the roles: user:hash:role1,role2")
+	flag.StringVar(&Default.AuthJWTSecret, "auth_jwt_secret", "", "the HMAC secret of the HS256 JWT tokens, or the WEB_TERMINAL_JWT_SECRET environment variable")
+	flag.StringVar(&Default.PolicyFile, "policy", "", "the JSON policy file limiting which hosts, protocols and commands the users may use")
+	flag.StringVar(&Default.AuditLog, "audit_log", "", "the audit log file, default is audit.log in the logs directory")
+	flag.DurationVar(&Default.TicketTTL, "ticket_ttl", 30*time.Second, "the lifetime of the single-use connection tickets")
+	flag.StringVar(&Default.VaultFile, "vault_file", "", "the encrypted file of the saved host profiles")
+	flag.StringVar(&Default.VaultKey, "vault_key", "", "the master key of the vault, or the WEB_TERMINAL_VAULT_KEY environment variable, the vault is disabled if empty")
+	flag.StringVar(&Default.CAKeyFile, "ca_key", "", "the user CA private key, if set a short-lived certificate is issued for the web user on every ssh connection")
+	flag.DurationVar(&Default.CAValidity, "ca_validity", 5*time.Minute, "the validity of the issued certificates")
+	flag.StringVar(&Default.CAPrincipals, "ca_principals", "{principal}", "comma separated principals of the issued certificates, {principal} is the web user, every listed login name is granted to all the web users")
+	flag.StringVar(&Default.CACriticalOptions, "ca_critical_options", "", "critical options of the issued certificates, e.g. 'source-address=10.0.0.0/8;force-command=/bin/date'")
+	flag.StringVar(&Default.AllowedOrigins, "allowed_origins", "", "comma separated origins allowed to open websockets besides the same origin, '*' allows all")
 
 	flag.StringVar(&Default.Password, "pw", "", "")
 	flag.StringVar(&Default.IDFile, "i", "", "")
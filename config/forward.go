@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DefaultForwardPolicy builds the policy from Default.ForwardAllow and Default.ForwardDeny
+func DefaultForwardPolicy() *ForwardPolicy {
+	return &ForwardPolicy{
+		Allow: SplitList(Default.ForwardAllow),
+		Deny:  SplitList(Default.ForwardDeny),
+
+		PublicListen: Default.ForwardPublicListen,
+	}
+}
+
+func NewForwardPolicy(allow ...string) *ForwardPolicy {
+	return &ForwardPolicy{Allow: allow}
+}
+
+// ForwardPolicy 端口转发目标地址的访问策略。
+// Each rule is "host:port", "host" or "[ipv6]:port"; host can be a glob pattern or CIDR,
+// port can be "*", a number or a range like "8000-8099". Deny rules take precedence.
+type ForwardPolicy struct {
+	Allow []string
+	Deny  []string
+
+	// PublicListen allows the listeners on web-terminal server, e.g. the SOCKS5 proxy without authentication, on non-loopback addresses
+	PublicListen bool
+}
+
+func (p *ForwardPolicy) AddAllow(rules ...string) *ForwardPolicy {
+	p.Allow = append(p.Allow, rules...)
+	return p
+}
+
+func (p *ForwardPolicy) AddDeny(rules ...string) *ForwardPolicy {
+	p.Deny = append(p.Deny, rules...)
+	return p
+}
+
+// Check returns an error if the destination address is not allowed
+func (p *ForwardPolicy) Check(address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port of %q: %w", address, err)
+	}
+	if p != nil {
+		for _, rule := range p.Deny {
+			if MatchAddress(rule, host, port) {
+				return fmt.Errorf("destination %q is denied by rule %q", address, rule)
+			}
+		}
+		for _, rule := range p.Allow {
+			if MatchAddress(rule, host, port) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("destination %q is not allowed", address)
+}
+
+// CheckListen returns an error if the listen address of web-terminal server is not a loopback address and PublicListen is off
+func (p *ForwardPolicy) CheckListen(address string) error {
+	if p != nil && p.PublicListen {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(host, `localhost`) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listen address %q is not a loopback address", address)
+}
+
+// SplitList splits a comma separated list and drops empty items
+func SplitList(s string) []string {
+	var r []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// MatchAddress reports whether host and port match the rule (see ForwardPolicy)
+func MatchAddress(rule string, host string, port int) bool {
+	hostPattern, portPattern := splitRule(rule)
+	return MatchHost(hostPattern, host) && MatchPort(portPattern, port)
+}
+
+func splitRule(rule string) (host string, port string) {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(rule, `[`) {
+		if end := strings.Index(rule, `]`); end > 0 {
+			host = rule[1:end]
+			port = strings.TrimPrefix(rule[end+1:], `:`)
+			return
+		}
+	}
+	if strings.Count(rule, `:`) == 1 {
+		pos := strings.LastIndex(rule, `:`)
+		return rule[:pos], rule[pos+1:]
+	}
+	return rule, ``
+}
+
+// MatchHost matches the host by CIDR ("10.0.0.0/8"), glob ("*.example.com") or exact name (case insensitive)
+func MatchHost(pattern string, host string) bool {
+	if len(pattern) == 0 || pattern == `*` {
+		return true
+	}
+	if strings.Contains(pattern, `/`) {
+		_, ipNet, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return false
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ipNet.Contains(ip)
+	}
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	if ok, err := path.Match(pattern, host); err == nil && ok {
+		return true
+	}
+	return pattern == host
+}
+
+// MatchPort matches the port by "*", a number or a range like "8000-8099"
+func MatchPort(pattern string, port int) bool {
+	if len(pattern) == 0 || pattern == `*` {
+		return true
+	}
+	if pos := strings.Index(pattern, `-`); pos > 0 {
+		from, err1 := strconv.Atoi(pattern[:pos])
+		to, err2 := strconv.Atoi(pattern[pos+1:])
+		return err1 == nil && err2 == nil && port >= from && port <= to
+	}
+	n, err := strconv.Atoi(pattern)
+	return err == nil && n == port
+}
@@ -0,0 +1,58 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+func TestForwardPolicy(t *testing.T) {
+	policy := config.NewForwardPolicy(`10.0.0.0/8:80`, `*.internal:8000-8099`, `[::1]:*`, `db.example.com`).
+		AddDeny(`10.0.0.1`)
+	cases := map[string]bool{
+		`10.1.2.3:80`:         true,
+		`10.1.2.3:81`:         false,
+		`10.0.0.1:80`:         false,
+		`web.internal:8080`:   true,
+		`web.internal:9000`:   false,
+		`[::1]:22`:            true,
+		`DB.example.com:5432`: true,
+		`example.com:80`:      false,
+	}
+	for address, allowed := range cases {
+		err := policy.Check(address)
+		if allowed && err != nil {
+			t.Errorf(`%s should be allowed: %v`, address, err)
+		} else if !allowed && err == nil {
+			t.Errorf(`%s should be denied`, address)
+		}
+	}
+	var empty *config.ForwardPolicy
+	if empty.Check(`127.0.0.1:22`) == nil {
+		t.Error(`nil policy must deny everything`)
+	}
+}
+
+func TestForwardPolicyCheckListen(t *testing.T) {
+	policy := config.NewForwardPolicy()
+	cases := map[string]bool{
+		`127.0.0.1:1080`: true,
+		`[::1]:1080`:     true,
+		`localhost:0`:    true,
+		`0.0.0.0:1080`:   false,
+		`:1080`:          false,
+		`10.0.0.5:1080`:  false,
+	}
+	for address, allowed := range cases {
+		err := policy.CheckListen(address)
+		if allowed && err != nil {
+			t.Errorf(`%s should be allowed: %v`, address, err)
+		} else if !allowed && err == nil {
+			t.Errorf(`%s should be refused`, address)
+		}
+	}
+	policy.PublicListen = true
+	if err := policy.CheckListen(`0.0.0.0:1080`); err != nil {
+		t.Errorf(`PublicListen must allow any address: %v`, err)
+	}
+}
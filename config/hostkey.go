@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy 主机密钥校验策略
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict only accepts host keys already recorded in known_hosts
+	HostKeyStrict HostKeyPolicy = `strict`
+	// HostKeyTOFU asks the user to accept unknown host keys and records them (trust on first use)
+	HostKeyTOFU HostKeyPolicy = `tofu`
+	// HostKeyIgnore disables host key verification
+	HostKeyIgnore HostKeyPolicy = `ignore`
+)
+
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch p := HostKeyPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case HostKeyStrict, HostKeyTOFU, HostKeyIgnore:
+		return p, nil
+	case ``:
+		return HostKeyTOFU, nil
+	default:
+		return ``, fmt.Errorf("unsupported host key policy: %q", s)
+	}
+}
+
+// HostKeyConfirm asks the user whether an unknown host key should be trusted
+type HostKeyConfirm func(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error)
+
+var (
+	ErrHostKeyRejected = errors.New("host key verification failed: rejected by user")
+	knownHostsStores   = sync.Map{}
+)
+
+// DefaultKnownHosts returns the store of Default.KnownHostsFile
+func DefaultKnownHosts() *KnownHosts {
+	file := Default.KnownHostsFile
+	if len(file) == 0 {
+		file = AutoKnownHostsFile()
+	}
+	return GetKnownHosts(file)
+}
+
+// GetKnownHosts returns the shared store of the known_hosts file
+func GetKnownHosts(file string) *KnownHosts {
+	store, _ := knownHostsStores.LoadOrStore(file, NewKnownHosts(file))
+	return store.(*KnownHosts)
+}
+
+func NewKnownHosts(file string) *KnownHosts {
+	return &KnownHosts{file: file}
+}
+
+// KnownHosts OpenSSH 格式的 known_hosts 文件
+type KnownHosts struct {
+	file string
+	mu   sync.Mutex
+}
+
+func (k *KnownHosts) File() string {
+	return k.file
+}
+
+func (k *KnownHosts) ensureFile() error {
+	if _, err := os.Stat(k.file); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(k.file, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Check returns a *knownhosts.KeyError if the key is unknown (empty Want) or mismatched
+func (k *KnownHosts) Check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.ensureFile(); err != nil {
+		return err
+	}
+	callback, err := knownhosts.New(k.file)
+	if err != nil {
+		return err
+	}
+	return callback(hostname, remote, key)
+}
+
+// Add appends the host key to the known_hosts file
+func (k *KnownHosts) Add(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.ensureFile(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(k.file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// HostKeyCallback builds a ssh.HostKeyCallback for the policy.
+// In TOFU mode the unknown hosts are rejected like the strict mode if confirm is nil, nobody can be asked.
+func (k *KnownHosts) HostKeyCallback(policy HostKeyPolicy, confirm HostKeyConfirm) ssh.HostKeyCallback {
+	if policy == HostKeyIgnore {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := k.Check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key verification failed: REMOTE HOST IDENTIFICATION HAS CHANGED for %s (%s %s, expected by %s:%d): %w",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), keyErr.Want[0].Filename, keyErr.Want[0].Line, err)
+		}
+		if policy != HostKeyTOFU || confirm == nil {
+			return fmt.Errorf("host key verification failed: no %s host key is known for %s", key.Type(), hostname)
+		}
+		ok, err := confirm(hostname, remote, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrHostKeyRejected
+		}
+		return k.Add(hostname, remote, key)
+	}
+}
+
+// HostKeyConfirmFunc asks the question like OpenSSH does and reads the answer line from the reader
+func HostKeyConfirmFunc(reader *bufio.Reader, writer io.Writer) HostKeyConfirm {
+	return HostKeyConfirmByPrompter(TerminalPrompter(reader, writer))
+}
+
+// ReadAnswer reads one line typed in the terminal, which ends with CR or LF
+func ReadAnswer(reader *bufio.Reader, writer io.Writer, echo bool) (string, error) {
+	var line []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return string(line), err
+		}
+		switch b {
+		case '\r', '\n':
+			if b == '\r' && reader.Buffered() > 0 {
+				if next, _ := reader.Peek(1); len(next) == 1 && next[0] == '\n' {
+					reader.ReadByte()
+				}
+			}
+			io.WriteString(writer, "\r\n")
+			return string(line), nil
+		case 0x7f, '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				if echo {
+					io.WriteString(writer, "\b \b")
+				}
+			}
+		default:
+			line = append(line, b)
+			if echo {
+				writer.Write([]byte{b})
+			}
+		}
+	}
+}
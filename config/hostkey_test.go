@@ -0,0 +1,60 @@
+package config_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHostsPolicy(t *testing.T) {
+	store := config.NewKnownHosts(filepath.Join(t.TempDir(), `known_hosts`))
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2222}
+	hostname := `example.com:2222`
+	key := newHostKey(t)
+
+	if err := store.HostKeyCallback(config.HostKeyStrict, nil)(hostname, remote, key); err == nil {
+		t.Fatal(`strict policy must reject an unknown host`)
+	}
+
+	// nobody can be asked, the unknown host is not trusted
+	if err := store.HostKeyCallback(config.HostKeyTOFU, nil)(hostname, remote, key); err == nil {
+		t.Fatal(`tofu policy without confirm must reject an unknown host`)
+	}
+
+	rejected := func(string, net.Addr, ssh.PublicKey) (bool, error) { return false, nil }
+	if err := store.HostKeyCallback(config.HostKeyTOFU, rejected)(hostname, remote, key); err != config.ErrHostKeyRejected {
+		t.Fatalf(`expected rejection, got %v`, err)
+	}
+
+	accepted := func(string, net.Addr, ssh.PublicKey) (bool, error) { return true, nil }
+	if err := store.HostKeyCallback(config.HostKeyTOFU, accepted)(hostname, remote, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.HostKeyCallback(config.HostKeyStrict, nil)(hostname, remote, key); err != nil {
+		t.Fatalf(`recorded host must be accepted: %v`, err)
+	}
+
+	if err := store.HostKeyCallback(config.HostKeyTOFU, accepted)(hostname, remote, newHostKey(t)); err == nil {
+		t.Fatal(`changed host key must be rejected even in tofu mode`)
+	}
+	if err := store.HostKeyCallback(config.HostKeyIgnore, nil)(hostname, remote, newHostKey(t)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,459 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrProxyJumpLoop     = errors.New("ProxyJump loop detected")
+	ErrTooManyProxyJumps = errors.New("too many ProxyJump hosts")
+	// ErrInvalidHostName is returned for the host names which may not be put in ProxyCommand, e.g. "$(cmd).corp" matching "Host *.corp"
+	ErrInvalidHostName = errors.New("invalid host name")
+)
+
+// MaxProxyJumps limits the jump hosts of a host
+const MaxProxyJumps = 8
+
+// OpenSSHHost is the resolved configuration of a host in an OpenSSH config file
+type OpenSSHHost struct {
+	Alias         string
+	HostName      string
+	Port          int
+	User          string
+	IdentityFiles []string
+	// ProxyJump is the list of [user@]host[:port] destinations, the first one is resolved by the config recursively
+	ProxyJump    []string
+	ProxyCommand string
+	// Algorithms are the Ciphers, KexAlgorithms, MACs and HostKeyAlgorithms options
+	Algorithms          AlgorithmOptions
+	ServerAliveInterval time.Duration
+	ServerAliveCountMax int
+
+	err error // the alias or the HostName may not be expanded in ProxyCommand
+}
+
+type openSSHOption struct {
+	key  string
+	args []string
+}
+
+type openSSHBlock struct {
+	patterns []string
+	options  []openSSHOption
+}
+
+func (b *openSSHBlock) match(alias string) bool {
+	matched := false
+	for _, pattern := range b.patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.ToLower(strings.TrimPrefix(pattern, "!")), strings.ToLower(alias)); !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// explicit reports whether the block names hosts instead of only "Host *"
+func (b *openSSHBlock) explicit() bool {
+	for _, pattern := range b.patterns {
+		if pattern != "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSSHConfig is an OpenSSH client config file (~/.ssh/config).
+// Only the Host blocks are supported, the Match blocks are ignored.
+type OpenSSHConfig struct {
+	blocks []*openSSHBlock
+}
+
+func LoadOpenSSHConfig(file string) (*OpenSSHConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ParseOpenSSHConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return c, nil
+}
+
+func ParseOpenSSHConfig(r io.Reader) (*OpenSSHConfig, error) {
+	c := &OpenSSHConfig{}
+	// the options before the first Host apply to all the hosts
+	block := &openSSHBlock{patterns: []string{"*"}}
+	c.blocks = append(c.blocks, block)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		key, args, err := splitOpenSSHLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		switch key {
+		case "host":
+			block = &openSSHBlock{patterns: args}
+			c.blocks = append(c.blocks, block)
+		case "match":
+			block = &openSSHBlock{}
+			c.blocks = append(c.blocks, block)
+		default:
+			block.options = append(block.options, openSSHOption{key: key, args: args})
+		}
+	}
+	return c, scanner.Err()
+}
+
+// splitOpenSSHLine splits "Keyword arg1 arg2" or "Keyword=arg", the arguments may be double quoted
+func splitOpenSSHLine(line string) (string, []string, error) {
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return "", nil, fmt.Errorf("missing argument of %q", line)
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+	var args []string
+	for len(rest) > 0 {
+		var arg string
+		if rest[0] == '"' {
+			end = strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return key, nil, errors.New("unterminated quoted string")
+			}
+			arg, rest = rest[1:end+1], rest[end+2:]
+		} else if end = strings.IndexAny(rest, " \t"); end < 0 {
+			arg, rest = rest, ""
+		} else {
+			arg, rest = rest[:end], rest[end:]
+		}
+		args = append(args, arg)
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	if len(args) == 0 {
+		return key, nil, fmt.Errorf("missing argument of %q", key)
+	}
+	return key, args, nil
+}
+
+// Lookup resolves the options of the alias, the first obtained value of an option is used like OpenSSH.
+// found is false if no Host block other than "Host *" matches the alias.
+func (c *OpenSSHConfig) Lookup(alias string) (host *OpenSSHHost, found bool) {
+	host = &OpenSSHHost{Alias: alias}
+	seen := map[string]bool{}
+	for _, block := range c.blocks {
+		if !block.match(alias) {
+			continue
+		}
+		if block.explicit() {
+			found = true
+		}
+		for _, option := range block.options {
+			if option.key == "identityfile" {
+				host.IdentityFiles = append(host.IdentityFiles, option.args[0])
+				continue
+			}
+			if seen[option.key] {
+				continue
+			}
+			seen[option.key] = true
+			host.set(option)
+		}
+	}
+	if len(host.HostName) == 0 {
+		host.HostName = alias
+	} else {
+		host.HostName = strings.ReplaceAll(host.HostName, "%h", alias)
+	}
+	if host.Port == 0 {
+		host.Port = 22
+	}
+	for i, file := range host.IdentityFiles {
+		host.IdentityFiles[i] = host.expand(file)
+	}
+	if len(host.ProxyCommand) > 0 {
+		// the command is run by the shell, the alias comes from the browser
+		if !validHostName(host.Alias) || !validHostName(host.HostName) {
+			host.ProxyCommand, host.err = "", fmt.Errorf("%w: %q", ErrInvalidHostName, host.Alias)
+		} else {
+			host.ProxyCommand = host.expand(host.ProxyCommand)
+		}
+	}
+	return host, found
+}
+
+// validHostName reports whether the name has only the letters, the digits and ".-_:" and does not begin with "-" like OpenSSH
+func validHostName(name string) bool {
+	if len(name) == 0 || name[0] == '-' {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '.', r == '-', r == '_', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (h *OpenSSHHost) set(option openSSHOption) {
+	arg := option.args[0]
+	switch option.key {
+	case "hostname":
+		h.HostName = arg
+	case "port":
+		h.Port, _ = strconv.Atoi(arg)
+	case "user":
+		h.User = arg
+	case "proxyjump":
+		// ProxyJump and ProxyCommand are exclusive, the first one wins
+		if len(h.ProxyCommand) > 0 || h.ProxyJump != nil {
+			return
+		}
+		h.ProxyJump = []string{}
+		if !strings.EqualFold(arg, "none") {
+			h.ProxyJump = SplitList(arg)
+		}
+	case "proxycommand":
+		if len(h.ProxyCommand) > 0 || h.ProxyJump != nil {
+			return
+		}
+		if strings.EqualFold(arg, "none") {
+			h.ProxyJump = []string{}
+			return
+		}
+		h.ProxyCommand = strings.Join(option.args, " ")
+	case "ciphers":
+		h.Algorithms.Ciphers = arg
+	case "kexalgorithms":
+		h.Algorithms.KexAlgorithms = arg
+	case "macs":
+		h.Algorithms.MACs = arg
+	case "hostkeyalgorithms":
+		h.Algorithms.HostKeyAlgorithms = arg
+	case "serveraliveinterval":
+		seconds, _ := strconv.Atoi(arg)
+		h.ServerAliveInterval = time.Duration(seconds) * time.Second
+		if seconds == 0 {
+			// "ServerAliveInterval 0" disables the keepalives like OpenSSH
+			h.ServerAliveInterval = -1
+		}
+	case "serveralivecountmax":
+		h.ServerAliveCountMax, _ = strconv.Atoi(arg)
+	}
+}
+
+// expand replaces the tokens %h, %p, %r, %n, %d, %u and %% and the leading "~"
+func (h *OpenSSHHost) expand(s string) string {
+	var localUser, home string
+	if u, err := user.Current(); err == nil {
+		localUser, home = u.Username, u.HomeDir
+	}
+	if strings.HasPrefix(s, "~/") {
+		s = filepath.Join(home, s[2:])
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'h':
+			b.WriteString(h.HostName)
+		case 'p':
+			b.WriteString(strconv.Itoa(h.Port))
+		case 'r':
+			b.WriteString(h.User)
+		case 'n':
+			b.WriteString(h.Alias)
+		case 'd':
+			b.WriteString(home)
+		case 'u':
+			b.WriteString(localUser)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// destination resolves the [user@]host[:port] destination of ProxyJump
+func (c *OpenSSHConfig) destination(dest string) *OpenSSHHost {
+	var userName, port string
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		userName, dest = dest[:i], dest[i+1:]
+	}
+	if host, p, err := splitHostPort(dest); err == nil {
+		dest, port = host, p
+	}
+	host, _ := c.Lookup(dest)
+	if len(userName) > 0 {
+		host.User = userName
+	}
+	if n, err := strconv.Atoi(port); err == nil && n > 0 {
+		host.Port = n
+	}
+	return host
+}
+
+func splitHostPort(dest string) (string, string, error) {
+	if strings.HasPrefix(dest, "[") || strings.Count(dest, ":") == 1 {
+		return net.SplitHostPort(dest)
+	}
+	return dest, "", errors.New("no port")
+}
+
+// Chain returns the hosts to connect in order, the jump hosts first and the alias last
+func (c *OpenSSHConfig) Chain(alias string) ([]*OpenSSHHost, error) {
+	host, _ := c.Lookup(alias)
+	return c.chain(host, map[string]bool{})
+}
+
+func (c *OpenSSHConfig) chain(host *OpenSSHHost, visiting map[string]bool) ([]*OpenSSHHost, error) {
+	key := strings.ToLower(host.Alias)
+	if visiting[key] {
+		return nil, fmt.Errorf("%w: %s", ErrProxyJumpLoop, host.Alias)
+	}
+	if host.err != nil {
+		return nil, host.err
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+	if len(host.ProxyJump) == 0 {
+		return []*OpenSSHHost{host}, nil
+	}
+	hops, err := c.chain(c.destination(host.ProxyJump[0]), visiting)
+	if err != nil {
+		return nil, err
+	}
+	// like "ssh -J", the other jump hosts are connected through the previous ones
+	for _, dest := range host.ProxyJump[1:] {
+		hop := c.destination(dest)
+		hop.ProxyJump, hop.ProxyCommand, hop.err = nil, "", nil
+		hops = append(hops, hop)
+	}
+	if len(hops) > MaxProxyJumps {
+		return nil, fmt.Errorf("%w: %s", ErrTooManyProxyJumps, host.Alias)
+	}
+	return append(hops, host), nil
+}
+
+// Account loads the IdentityFile keys and their certificates (the "-cert.pub" files).
+// The missing files and the keys protected by passphrases are skipped.
+func (h *OpenSSHHost) Account() (*AccountConfig, error) {
+	account := &AccountConfig{User: h.User}
+	for _, file := range h.IdentityFiles {
+		pemBytes, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pemBytes)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				log.Printf("IdentityFile %s is skipped: the passphrase is required", file)
+				continue
+			}
+			return nil, fmt.Errorf("IdentityFile %s: %w", file, err)
+		}
+		if certificate, err := os.ReadFile(file + "-cert.pub"); err == nil {
+			certSigner, err := NewCertSigner(certificate, signer)
+			if err != nil {
+				return nil, fmt.Errorf("IdentityFile %s: %w", file, err)
+			}
+			account.Signers = append(account.Signers, certSigner)
+		}
+		account.Signers = append(account.Signers, signer)
+	}
+	return account, nil
+}
+
+// HostConfig builds the config of the host with the account, see NewSSHStandard
+func (h *OpenSSHHost) HostConfig(reader io.Reader, writer io.Writer, account *AccountConfig) (*HostConfig, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	algorithms, err := h.Algorithms.Algorithms()
+	if err != nil {
+		return nil, err
+	}
+	clientConfig, err := NewSSHStandard(reader, writer, account)
+	if err != nil {
+		return nil, err
+	}
+	hostConfig := NewHostConfig(clientConfig, h.HostName, h.Port).SetAccount(account).SetAlgorithms(algorithms)
+	hostConfig.ProxyCommand = h.ProxyCommand
+	hostConfig.ServerAliveInterval = h.ServerAliveInterval
+	hostConfig.ServerAliveCountMax = h.ServerAliveCountMax
+	return hostConfig, nil
+}
+
+// SSHConfig builds the config of the alias with its jump chain.
+// prepare is called with the account of every host before the client config is built, it may be nil.
+func (c *OpenSSHConfig) SSHConfig(alias string, reader io.Reader, writer io.Writer, prepare func(*OpenSSHHost, *AccountConfig) error) (*SSHConfig, error) {
+	hosts, err := c.Chain(alias)
+	if err != nil {
+		return nil, err
+	}
+	hostConfigs := make([]*HostConfig, 0, len(hosts))
+	for _, host := range hosts {
+		account, err := host.Account()
+		if err != nil {
+			return nil, err
+		}
+		if prepare != nil {
+			if err = prepare(host, account); err != nil {
+				return nil, err
+			}
+		}
+		if len(account.User) == 0 {
+			// like OpenSSH, the local user is the default
+			if u, err := user.Current(); err == nil {
+				account.User = u.Username
+			}
+		}
+		hostConfig, err := host.HostConfig(reader, writer, account)
+		if err != nil {
+			return nil, fmt.Errorf("host %q: %w", host.Alias, err)
+		}
+		hostConfigs = append(hostConfigs, hostConfig)
+	}
+	return NewSSHConfig(hostConfigs...), nil
+}
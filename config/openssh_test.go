@@ -0,0 +1,116 @@
+package config_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+const testOpenSSHConfig = `
+# global options
+ServerAliveInterval 15
+
+Host bastion
+    HostName 203.0.113.10
+    User jump
+    Port 2222
+
+Host inner
+    HostName=10.0.0.2
+    ProxyJump bastion,admin@mid:2200
+    IdentityFile "/keys/inner key"
+
+Host prod-* !prod-legacy
+    User deploy
+    ProxyCommand nc %h %p
+    Ciphers -*-cbc
+
+Host *.corp
+    ProxyCommand ssh -W %h:%p bastion
+
+Host loop-a
+    ProxyJump loop-b
+Host loop-b
+    ProxyJump loop-a
+
+Host *
+    User nobody
+    IdentityFile /keys/default
+    KexAlgorithms ^curve25519-sha256
+`
+
+func TestOpenSSHConfigLookup(t *testing.T) {
+	c, err := config.ParseOpenSSHConfig(strings.NewReader(testOpenSSHConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, found := c.Lookup("inner")
+	if !found || host.HostName != "10.0.0.2" || host.Port != 22 || host.User != "nobody" {
+		t.Fatalf("unexpected host: %+v", host)
+	}
+	if !reflect.DeepEqual(host.IdentityFiles, []string{"/keys/inner key", "/keys/default"}) {
+		t.Fatalf("unexpected identity files: %v", host.IdentityFiles)
+	}
+	if host.ServerAliveInterval != 15*time.Second || host.Algorithms.KexAlgorithms != "^curve25519-sha256" {
+		t.Fatalf("the global and the wildcard options are expected: %+v", host)
+	}
+
+	host, found = c.Lookup("prod-db")
+	if !found || host.User != "deploy" || host.ProxyCommand != "nc prod-db 22" {
+		t.Fatalf("unexpected host: %+v", host)
+	}
+	if host, found = c.Lookup("prod-legacy"); found || host.User != "nobody" {
+		t.Fatalf("the negated pattern must not match: %+v", host)
+	}
+}
+
+func TestOpenSSHConfigChain(t *testing.T) {
+	c, err := config.ParseOpenSSHConfig(strings.NewReader(testOpenSSHConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := c.Chain("inner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hops []string
+	for _, host := range hosts {
+		hops = append(hops, fmt.Sprintf("%s@%s:%d", host.User, host.HostName, host.Port))
+	}
+	expected := []string{"jump@203.0.113.10:2222", "admin@mid:2200", "nobody@10.0.0.2:22"}
+	if !reflect.DeepEqual(hops, expected) {
+		t.Fatalf("expected %v, got %v", expected, hops)
+	}
+	if _, err = c.Chain("loop-a"); !errors.Is(err, config.ErrProxyJumpLoop) {
+		t.Fatalf("expected the loop error, got %v", err)
+	}
+}
+
+func TestOpenSSHConfigHostileAlias(t *testing.T) {
+	c, err := config.ParseOpenSSHConfig(strings.NewReader(testOpenSSHConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, found := c.Lookup("web-1.corp")
+	if !found || host.ProxyCommand != "ssh -W web-1.corp:22 bastion" {
+		t.Fatalf("unexpected host: %+v", host)
+	}
+	// the wildcard matches the shell metacharacters, the alias must not reach the shell
+	for _, alias := range []string{"$(touch${IFS}pwn).corp", "a;id;.corp", "a|id.corp", "`id`.corp", "-oProxyCommand=id.corp"} {
+		host, _ = c.Lookup(alias)
+		if len(host.ProxyCommand) > 0 {
+			t.Fatalf("%q: the ProxyCommand is expanded: %q", alias, host.ProxyCommand)
+		}
+		if _, err = c.Chain(alias); !errors.Is(err, config.ErrInvalidHostName) {
+			t.Fatalf("%q: expected the invalid host name error, got %v", alias, err)
+		}
+		if _, err = host.HostConfig(nil, nil, &config.AccountConfig{}); !errors.Is(err, config.ErrInvalidHostName) {
+			t.Fatalf("%q: expected the invalid host name error, got %v", alias, err)
+		}
+	}
+}
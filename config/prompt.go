@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrPromptCanceled = errors.New("the prompt is canceled by the user")
+
+const (
+	PromptPassword            = "password"
+	PromptKeyboardInteractive = "keyboard-interactive"
+	PromptConfirm             = "confirm" // the answer is "yes" or "no"
+
+	// MaxAuthTries limits the password prompts of each authentication method
+	MaxAuthTries = 3
+)
+
+// Prompt asks the user, it is sent to the browser as {"type":"prompt", ...}
+type Prompt struct {
+	Type        string           `json:"type"` // always "prompt"
+	ID          string           `json:"id"`
+	Kind        string           `json:"kind"` // password, keyboard-interactive or confirm
+	User        string           `json:"user,omitempty"`
+	Host        string           `json:"host,omitempty"`
+	Name        string           `json:"name,omitempty"`
+	Instruction string           `json:"instruction,omitempty"`
+	Questions   []PromptQuestion `json:"questions"`
+	Error       string           `json:"error,omitempty"` // e.g. the previous password is wrong
+}
+
+type PromptQuestion struct {
+	Text string `json:"text"`
+	Echo bool   `json:"echo"`
+}
+
+// PromptAnswer is the reply of the browser: {"type":"prompt_answer", "id":..., "answers":[...]}
+type PromptAnswer struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id"`
+	Answers []string `json:"answers"`
+	Cancel  bool     `json:"cancel,omitempty"`
+}
+
+// Prompter asks the questions and returns the answers in the same order, it returns ErrPromptCanceled if the user cancels
+type Prompter interface {
+	Prompt(p *Prompt) ([]string, error)
+}
+
+type PrompterFunc func(p *Prompt) ([]string, error)
+
+func (f PrompterFunc) Prompt(p *Prompt) ([]string, error) {
+	return f(p)
+}
+
+// TerminalPrompter asks in the terminal, the answers of the questions without echo are not echoed
+func TerminalPrompter(reader *bufio.Reader, writer io.Writer) Prompter {
+	return PrompterFunc(func(p *Prompt) ([]string, error) {
+		if len(p.Error) > 0 {
+			io.WriteString(writer, p.Error+"\r\n")
+		}
+		if len(p.Instruction) > 0 {
+			io.WriteString(writer, strings.ReplaceAll(p.Instruction, "\n", "\r\n")+"\r\n")
+		}
+		answers := make([]string, len(p.Questions))
+		for i, q := range p.Questions {
+			if _, err := io.WriteString(writer, q.Text); err != nil {
+				return nil, err
+			}
+			answer, err := ReadAnswer(reader, writer, q.Echo)
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	})
+}
+
+// HostKeyConfirmByPrompter asks the user to accept the unknown host key
+func HostKeyConfirmByPrompter(prompter Prompter) HostKeyConfirm {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+		host := hostname
+		if remote != nil && remote.String() != hostname {
+			host += ` (` + remote.String() + `)`
+		}
+		p := &Prompt{
+			Kind: PromptConfirm,
+			Host: hostname,
+			Instruction: "The authenticity of host '" + host + "' can't be established.\n" +
+				strings.ToUpper(strings.TrimPrefix(key.Type(), "ssh-")) + " key fingerprint is " + ssh.FingerprintSHA256(key) + ".",
+			Questions: []PromptQuestion{{Text: "Are you sure you want to continue connecting (yes/no)? ", Echo: true}},
+		}
+		for i := 0; i < 3; i++ {
+			answers, err := prompter.Prompt(p)
+			if err != nil {
+				if err == ErrPromptCanceled {
+					return false, nil
+				}
+				return false, err
+			}
+			if len(answers) > 0 {
+				switch strings.ToLower(strings.TrimSpace(answers[0])) {
+				case "yes", "y":
+					return true, nil
+				case "no", "n":
+					return false, nil
+				}
+			}
+			p.Error = "Please type 'yes' or 'no'"
+		}
+		return false, nil
+	}
+}
+
+// PasswordPrompt uses the password of the account first, then asks the user, the failed password is reported by Prompt.Error
+func PasswordPrompt(prompter Prompter, account *AccountConfig) ssh.AuthMethod {
+	tries := 0
+	return ssh.RetryableAuthMethod(ssh.PasswordCallback(func() (string, error) {
+		tries++
+		if tries == 1 && len(account.Password) > 0 {
+			return account.Password, nil
+		}
+		p := &Prompt{
+			Kind:      PromptPassword,
+			User:      account.User,
+			Questions: []PromptQuestion{{Text: account.User + "'s password: "}},
+		}
+		if tries > 1 {
+			p.Error = "Permission denied, please try again."
+		}
+		answers, err := prompter.Prompt(p)
+		if err != nil {
+			return "", err
+		}
+		if len(answers) == 0 {
+			return "", ErrPromptCanceled
+		}
+		return answers[0], nil
+	}), MaxAuthTries)
+}
+
+// KeyboardInteractivePrompt answers the first password question by the password of the account,
+// all the other challenges (e.g. OTP) are asked together in one prompt
+func KeyboardInteractivePrompt(prompter Prompter, account *AccountConfig) ssh.AuthMethod {
+	var (
+		passwordCount         int
+		emptyInteractiveCount int
+	)
+	enc := CharsetEncoding(account.Charset)
+	decode := func(s string) string {
+		if enc == nil {
+			return s
+		}
+		if r, err := enc.NewDecoder().String(s); err == nil {
+			return r
+		}
+		return s
+	}
+	return ssh.RetryableAuthMethod(ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if len(questions) == 0 {
+			if emptyInteractiveCount++; emptyInteractiveCount > 50 {
+				return nil, errors.New("interactive count is too much")
+			}
+			return nil, nil
+		}
+		if len(questions) == 1 && !echos[0] && isPasswordQuestion(questions[0]) {
+			passwordCount++
+			if passwordCount == 1 && len(account.Password) > 0 {
+				return []string{account.Password}, nil
+			}
+		}
+		p := &Prompt{
+			Kind:        PromptKeyboardInteractive,
+			User:        account.User,
+			Name:        decode(name),
+			Instruction: decode(instruction),
+			Questions:   make([]PromptQuestion, len(questions)),
+		}
+		for i, q := range questions {
+			p.Questions[i] = PromptQuestion{Text: decode(q), Echo: echos[i]}
+		}
+		if passwordCount > 1 {
+			p.Error = "Permission denied, please try again."
+		}
+		answers, err := prompter.Prompt(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(answers) != len(questions) {
+			return nil, ErrPromptCanceled
+		}
+		return answers, nil
+	}), MaxAuthTries)
+}
+
+func isPasswordQuestion(question string) bool {
+	question = strings.ToLower(strings.TrimSpace(question))
+	return strings.HasPrefix(question, "password") || strings.HasSuffix(question, "password:")
+}
@@ -0,0 +1,131 @@
+package config_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// dialTestServer runs the handshake against a server on the loopback interface
+func dialTestServer(t *testing.T, serverConfig *ssh.ServerConfig, account *config.AccountConfig) error {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go ssh.DiscardRequests(reqs)
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "")
+		}
+	}()
+	account.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	clientConfig, err := config.NewSSHStandard(nil, nil, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn, _, _, err := ssh.NewClientConn(c, l.Addr().String(), clientConfig)
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+func TestPasswordPrompt(t *testing.T) {
+	var prompts []*config.Prompt
+	account := &config.AccountConfig{
+		User:     "root",
+		Password: "wrong",
+		Prompter: config.PrompterFunc(func(p *config.Prompt) ([]string, error) {
+			prompts = append(prompts, p)
+			return []string{"right"}, nil
+		}),
+	}
+	err := dialTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "right" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || prompts[0].Kind != config.PromptPassword || len(prompts[0].Error) == 0 {
+		t.Fatalf("expected one re-prompt with an error, got %+v", prompts)
+	}
+}
+
+func TestKeyboardInteractivePrompt(t *testing.T) {
+	var prompts []*config.Prompt
+	account := &config.AccountConfig{
+		User:     "root",
+		Password: "secret",
+		Prompter: config.PrompterFunc(func(p *config.Prompt) ([]string, error) {
+			prompts = append(prompts, p)
+			return []string{"123456"}, nil
+		}),
+	}
+	err := dialTestServer(t, &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err != nil || len(answers) != 1 || answers[0] != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			answers, err = challenge("", "Two-factor authentication", []string{"Verification code: "}, []bool{true})
+			if err != nil || len(answers) != 1 || answers[0] != "123456" {
+				return nil, errors.New("wrong code")
+			}
+			return nil, nil
+		},
+	}, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || prompts[0].Kind != config.PromptKeyboardInteractive || !prompts[0].Questions[0].Echo {
+		t.Fatalf("expected only the OTP to be prompted, got %+v", prompts)
+	}
+
+	account.Prompter = config.PrompterFunc(func(p *config.Prompt) ([]string, error) {
+		return nil, config.ErrPromptCanceled
+	})
+	account.Password = ""
+	if err = dialTestServer(t, &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			_, err := challenge("", "", []string{"Password: "}, []bool{false})
+			return nil, err
+		},
+	}, account); err == nil {
+		t.Fatal("the canceled prompt must fail the dial")
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"golang.org/x/crypto/ssh"
 )
 
@@ -13,6 +15,23 @@ type HostConfig struct {
 	Host    string
 	Port    int
 	Account *AccountConfig
+	// ProxyCommand connects to the host through the stdin and stdout of the command, only the first hop supports it
+	ProxyCommand string
+	// ServerAliveInterval is the interval of the keepalive requests, zero uses Default.SSHKeepAlive and a negative value disables them
+	ServerAliveInterval time.Duration
+	// ServerAliveCountMax is the number of missed keepalive replies before the connection is closed, zero uses Default.SSHKeepAliveCountMax
+	ServerAliveCountMax int
+}
+
+func (c *HostConfig) SetHostKeyCallback(callback ssh.HostKeyCallback) *HostConfig {
+	c.ClientConfig.HostKeyCallback = callback
+	return c
+}
+
+// SetAlgorithms replaces the algorithms offered to the host, see AlgorithmPreset
+func (c *HostConfig) SetAlgorithms(algorithms *Algorithms) *HostConfig {
+	algorithms.Apply(c.ClientConfig)
+	return c
 }
 
 func (c *HostConfig) SetAccount(account *AccountConfig) *HostConfig {
@@ -40,6 +59,8 @@ type SSHConfig struct {
 	End       *HostConfig
 	Jumps     []*HostConfig
 	Transform *TransformConfig
+	// Forward defaults to DefaultForwardPolicy() if nil
+	Forward *ForwardPolicy
 }
 
 func (c *SSHConfig) SetEnd(endHostConfig *HostConfig) *SSHConfig {
@@ -51,3 +72,15 @@ func (c *SSHConfig) AddJump(jumpHostConfigs ...*HostConfig) *SSHConfig {
 	c.Jumps = append(c.Jumps, jumpHostConfigs...)
 	return c
 }
+
+func (c *SSHConfig) SetForwardPolicy(policy *ForwardPolicy) *SSHConfig {
+	c.Forward = policy
+	return c
+}
+
+func (c *SSHConfig) ForwardPolicy() *ForwardPolicy {
+	if c.Forward == nil {
+		return DefaultForwardPolicy()
+	}
+	return c.Forward
+}
@@ -3,8 +3,8 @@ package config
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
-	"strings"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -26,14 +26,27 @@ func NewHostConfigWithAccount(ws io.ReadWriter, account *AccountConfig, host str
 }
 
 func NewSSHStandard(reader io.Reader, writer io.Writer, account *AccountConfig) (*ssh.ClientConfig, error) {
+	account.SetDefault()
 	// Dial code is taken from the ssh package example
 	sshConfig := &ssh.ClientConfig{
-		Config:          ssh.Config{Ciphers: supportedCiphers},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: account.HostKeyCallback,
 		User:            account.User,
 		Auth:            []ssh.AuthMethod{},
 	}
-	account.SetDefault()
+	DefaultAlgorithms().Apply(sshConfig)
+	prompter := account.Prompter
+	if prompter == nil && reader != nil && writer != nil {
+		prompter = TerminalPrompter(bufio.NewReader(reader), writer)
+	}
+	if sshConfig.HostKeyCallback == nil {
+		var confirm HostKeyConfirm
+		if prompter != nil {
+			confirm = HostKeyConfirmByPrompter(prompter)
+		}
+		sshConfig.HostKeyCallback = DefaultKnownHosts().HostKeyCallback(account.HostKeyPolicy, confirm)
+	}
+	// the client tries every method only once, so the keys and the agent share one publickey method
+	signers := append([]ssh.Signer{}, account.Signers...)
 	if account.PrivateKey != nil {
 		var signer ssh.Signer
 		var err error
@@ -46,60 +59,57 @@ func NewSSHStandard(reader io.Reader, writer io.Writer, account *AccountConfig)
 		if err != nil {
 			return sshConfig, err
 		}
-		sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signer))
+		if len(account.Certificate) > 0 {
+			certSigner, err := NewCertSigner(account.Certificate, signer)
+			if err != nil {
+				return sshConfig, err
+			}
+			signers = append(signers, certSigner)
+		}
+		signers = append(signers, signer)
+	}
+	if account.Agent != nil {
+		sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			agentSigners, err := account.Agent.Signers()
+			if err != nil {
+				return signers, nil
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
+	} else if len(signers) > 0 {
+		sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signers...))
 	}
 
-	if len(account.Password) > 0 {
+	if prompter != nil {
+		// a missing or wrong password is asked again
+		sshConfig.Auth = append(sshConfig.Auth,
+			PasswordPrompt(prompter, account),
+			KeyboardInteractivePrompt(prompter, account),
+		)
+	} else if len(account.Password) > 0 {
 		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(account.Password))
-		if reader != nil && writer != nil {
-			bufReader := bufio.NewReader(reader)
-			sshConfig.Auth = append(sshConfig.Auth, ssh.KeyboardInteractive(KeyboardInteractivefunc(bufReader, writer, account)))
-		}
 	}
 
 	sshConfig.SetDefaults()
 	return sshConfig, nil
 }
 
-func KeyboardInteractivefunc(reader *bufio.Reader, writer io.Writer, account *AccountConfig) func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-	var (
-		passwordCount         int
-		emptyInteractiveCount int
-		password              = account.Password
-		charset               = account.Charset
-	)
-	return func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-		if len(questions) == 0 {
-			emptyInteractiveCount++
-			if emptyInteractiveCount++; emptyInteractiveCount > 50 {
-				return nil, errors.New("interactive count is too much")
-			}
-			return nil, nil
-		}
-		for _, question := range questions {
-			io.WriteString(DecodeBy(charset, writer), question)
-			//panic(question)
-			question = strings.TrimSpace(question)
-			if len(question) > 12 {
-				question = question[0:12]
-			}
-			question = strings.ToLower(question)
-			switch question {
-			case "password:", "password as ", "password for":
-				passwordCount++
-				if passwordCount == 1 {
-					answers = append(answers, password)
-					break
-				}
-				fallthrough
-			default:
-				line, _, e := reader.ReadLine()
-				if nil != e {
-					return nil, e
-				}
-				answers = append(answers, string(line))
-			}
-		}
-		return answers, nil
+// NewCertSigner presents the OpenSSH certificate (authorized_keys format) with the private key
+func NewCertSigner(certificate []byte, signer ssh.Signer) (ssh.Signer, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("the certificate is not an OpenSSH certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("the certificate is not a user certificate")
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("the certificate does not match the private key: %w", err)
 	}
+	return certSigner, nil
 }
@@ -7,11 +7,14 @@ require (
 	github.com/admpub/errors v0.8.2
 	github.com/admpub/log v1.3.6
 	github.com/admpub/websocket v1.0.4
+	github.com/creack/pty v1.1.21
 	github.com/fd/go-shellwords v0.0.0-20130603174837-6a119423524d
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
 	github.com/pkg/errors v0.9.1
+	github.com/pkg/sftp v1.13.6
 	golang.org/x/crypto v0.24.0
 	golang.org/x/net v0.26.0
+	golang.org/x/sys v0.21.0
 	golang.org/x/text v0.16.0
 )
 
@@ -30,6 +33,7 @@ require (
 	github.com/goccy/go-json v0.10.3 // indirect
 	github.com/hashicorp/go-version v1.7.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
@@ -42,5 +46,4 @@ require (
 	github.com/webx-top/tagfast v0.0.1 // indirect
 	github.com/webx-top/validation v0.0.3 // indirect
 	golang.org/x/sync v0.7.0 // indirect
-	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/auth"
+	wsx "github.com/admpub/web-terminal/library/websocket"
+)
+
+var (
+	ErrOriginNotAllowed = errors.New("origin is not allowed")
+
+	// Authenticator authenticates the requests of all endpoints, nil disables the authentication
+	Authenticator auth.Authenticator
+
+	PrincipalContextKey = struct{ name string }{"principal"}
+)
+
+// NewAuthenticator builds the authenticator configured by c, it returns nil if no authentication is configured
+func NewAuthenticator(c *config.Config) (auth.Authenticator, error) {
+	var chain auth.Chain
+	if len(c.AuthTokenFile) > 0 {
+		tokens, err := auth.LoadStaticTokens(c.AuthTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, tokens)
+	}
+	if len(c.AuthJWTSecret) > 0 {
+		chain = append(chain, auth.NewJWT([]byte(c.AuthJWTSecret)))
+	}
+	if len(c.AuthHtpasswdFile) > 0 {
+		htpasswd, err := auth.LoadHtpasswd(c.AuthHtpasswdFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, htpasswd)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// GetPrincipal returns the authenticated principal of the HTTP request
+func GetPrincipal(r *http.Request) *auth.Principal {
+	principal, _ := r.Context().Value(PrincipalContextKey).(*auth.Principal)
+	return principal
+}
+
+// CheckOrigin 中间件：拒绝不被允许的来源
+func CheckOrigin(ctx *Context) error {
+	if !wsx.CheckOrigin(ctx.Request()) {
+		return ErrOriginNotAllowed
+	}
+	return nil
+}
+
+// Authenticate 中间件：认证并把 principal 放到 Context
+func Authenticate(ctx *Context) error {
+	if Authenticator == nil {
+		return nil
+	}
+	principal, err := Authenticator.Authenticate(ctx.Request())
+	if err != nil {
+		return err
+	}
+	ctx.Principal = principal
+	return nil
+}
+
+// AuthHandler protects the HTTP handler by the Authenticator.
+// The token of the "token" query parameter is kept in a cookie for the static files of the page.
+func AuthHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Authenticator == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		principal, err := Authenticator.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="web-terminal"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		auth.SetTokenCookie(w, r, principal, config.Default.APPRoot)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), PrincipalContextKey, principal)))
+	})
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/audit"
+	"github.com/admpub/web-terminal/library/ca"
+)
+
+// CertSigner mints a short-lived certificate for the web user on every connection, nil disables it
+var CertSigner *ca.Signer
+
+// issueCertificate adds the certificate minted for the authenticated web user to the account
+func (ctx *Context) issueCertificate(account *config.AccountConfig, host string, port int) error {
+	if CertSigner == nil || ctx.Principal == nil {
+		return nil
+	}
+	signer, cert, err := CertSigner.Mint(ctx.Principal.Name)
+	if err != nil {
+		return fmt.Errorf("failed to issue the certificate: %w", err)
+	}
+	account.Signers = append(account.Signers, signer)
+	if AuditLog != nil {
+		event := &audit.Event{
+			Action:    "certificate",
+			Principal: ctx.Principal.Name,
+			Protocol:  ctx.Protocol,
+			Host:      host,
+			Port:      port,
+			Allowed:   true,
+			Reason:    fmt.Sprintf("serial %d, principals %s", cert.Serial, strings.Join(cert.ValidPrincipals, ",")),
+		}
+		if r := ctx.Request(); r != nil {
+			event.Remote = r.RemoteAddr
+		}
+		if aerr := AuditLog.Log(event); aerr != nil {
+			logString(nil, "write audit log failed: "+aerr.Error())
+		}
+	}
+	return nil
+}
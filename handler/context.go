@@ -1,11 +1,11 @@
 package handler
 
 import (
-	"strconv"
 	"sync"
 
 	"github.com/admpub/web-terminal/config"
-	"golang.org/x/net/websocket"
+	"github.com/admpub/web-terminal/library/auth"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
 )
 
 var (
@@ -16,12 +16,15 @@ var (
 )
 
 type Context struct {
-	*websocket.Conn
-	Data   sync.Map
-	Config *config.SSHConfig
+	*websocketx.Conn
+	Data      sync.Map
+	Config    *config.SSHConfig
+	Principal *auth.Principal // the authenticated user, nil if the authentication is disabled
+	Protocol  string          // ssh, ssh_exec, sftp, forward, telnet, cmd or replay, see the Protocol middleware
+	Ticket    *Ticket         // the connection parameters, see ResolveTicket
 }
 
-func NewContext(ws *websocket.Conn) *Context {
+func NewContext(ws *websocketx.Conn) *Context {
 	return &Context{
 		Conn:   ws,
 		Data:   sync.Map{},
@@ -34,20 +37,25 @@ func (ctx *Context) GetSSHAccount() *config.AccountConfig {
 	if ok {
 		return account
 	}
-	user := ParamGet(ctx, "user")
-	pwd := ParamGet(ctx, "password")
-	charset := ParamGet(ctx, "charset")
-	// Dial code is taken from the ssh package example
+	// the credentials are only read from the ticket, never from the URL
 	account = &config.AccountConfig{
-		User:     user,
-		Password: pwd,
-		Charset:  charset,
+		Charset: ParamGet(ctx, "charset"),
 	}
-	if privKey := ParamGet(ctx, "privateKey"); len(privKey) > 0 {
-		account.PrivateKey = []byte(privKey)
-	}
-	if passphrase := ParamGet(ctx, "passphrase"); len(passphrase) > 0 {
-		account.Passphrase = []byte(passphrase)
+	if t := ctx.Ticket; t != nil {
+		account.User = t.User
+		account.Password = t.Password
+		if len(t.Charset) > 0 {
+			account.Charset = t.Charset
+		}
+		if len(t.PrivateKey) > 0 {
+			account.PrivateKey = []byte(t.PrivateKey)
+		}
+		if len(t.Passphrase) > 0 {
+			account.Passphrase = []byte(t.Passphrase)
+		}
+		if len(t.Certificate) > 0 {
+			account.Certificate = []byte(t.Certificate)
+		}
 	}
 	return account
 }
@@ -55,22 +63,41 @@ func (ctx *Context) GetSSHAccount() *config.AccountConfig {
 func (ctx *Context) GetHostConfig() (*config.HostConfig, error) {
 	hostConfig, ok := ctx.Request().Context().Value(SSHAccountContextKey).(*config.HostConfig)
 	if ok {
+		if err := ctx.Authorize(hostConfig.Host, hostConfig.Port, ""); err != nil {
+			return nil, err
+		}
 		return hostConfig, nil
 	}
-	hostname := ParamGet(ctx, "hostname")
-	port := ParamGet(ctx, "port")
-	if len(port) == 0 {
-		port = "22"
+	if id := ParamGet(ctx, "profile"); len(id) > 0 {
+		return ctx.profileHostConfig(id)
 	}
-	portN, err := strconv.Atoi(port)
-	if err != nil {
+	if alias := ParamGet(ctx, "host"); len(alias) > 0 {
+		return ctx.aliasHostConfig(alias)
+	}
+	if ctx.Ticket == nil {
+		return nil, ErrTicketRequired
+	}
+	hostname := ctx.Ticket.Hostname
+	portN := ctx.Ticket.Port
+	if portN == 0 {
+		portN = 22
+	}
+	if err := ctx.Authorize(hostname, portN, ""); err != nil {
 		return nil, err
 	}
 	account := ctx.GetSSHAccount()
+	account.Prompter = ctx.Prompter(hostname)
+	if err := ctx.issueCertificate(account, hostname, portN); err != nil {
+		return nil, err
+	}
+	algorithms, err := (&config.AlgorithmOptions{Preset: ctx.Ticket.Algorithms}).Algorithms()
+	if err != nil {
+		return nil, err
+	}
 	hostConfig, err = config.NewHostConfigWithAccount(ctx.Conn, account, hostname, portN)
 	if err != nil {
 		return hostConfig, err
 	}
-	hostConfig.SetAccount(account)
+	hostConfig.SetAccount(account).SetAlgorithms(algorithms)
 	return hostConfig, err
 }
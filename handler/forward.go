@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	sshx "github.com/admpub/web-terminal/library/ssh"
+)
+
+// Forward 端口转发：经 ssh 主机转发本地(-L)、远程(-R)和动态(-D)端口，消息见 sshx.ForwardMessage。
+// Every destination is checked by the forward policy and by the access policy of the "forward" protocol.
+func Forward(ctx *Context) error {
+	defer ctx.Close()
+	if ctx.Config.End == nil {
+		hostConfig, err := ctx.GetHostConfig()
+		if err != nil {
+			return fmt.Errorf("Failed to dial: %w", err)
+		}
+		ctx.Config.SetEnd(hostConfig)
+	}
+	sshClient := sshx.New(ctx.Config)
+	sshClient.CheckTarget = ctx.authorizeAddress
+	if err := sshClient.ConnectClient(); err != nil {
+		return err
+	}
+	defer sshClient.Close()
+	return sshClient.ServeForward(ctx.Conn)
+}
+
+// authorizeAddress checks the "host:port" destination by the access policy
+func (ctx *Context) authorizeAddress(address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port of %q: %w", address, err)
+	}
+	return ctx.Authorize(host, port, "")
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/admpub/web-terminal/library/auth"
+	"github.com/admpub/web-terminal/library/policy"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+)
+
+func TestForwardAuthorizeAddress(t *testing.T) {
+	defer func(p *policy.Policy) { Policy = p }(Policy)
+	Policy = &policy.Policy{Allow: []*policy.Rule{
+		{Protocols: []string{"forward"}, Hosts: []string{"10.0.0.0/8:80"}},
+	}}
+	ctx := NewContext(&websocketx.Conn{})
+	ctx.Principal = &auth.Principal{Name: "bob"}
+	ctx.Protocol = "forward"
+	if err := ctx.authorizeAddress("10.0.0.5:80"); err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{"10.0.0.5:22", "192.168.0.1:80", "10.0.0.5", "10.0.0.5:http"} {
+		if err := ctx.authorizeAddress(address); err == nil {
+			t.Fatalf("%s must be denied", address)
+		}
+	}
+	// the rules of the other protocols do not allow the forwards
+	ctx.Protocol = "ssh"
+	if err := ctx.authorizeAddress("10.0.0.5:80"); err == nil {
+		t.Fatal("the ssh rule must not allow the forward")
+	}
+}
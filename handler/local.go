@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/pty"
+)
+
+var ErrProgramNotAllowed = errors.New("the program is not allowed, see -local_allow")
+
+// localProgram returns the program of the "exec" parameter, it is config.Default.SHExecute by default
+func localProgram(name string) (string, error) {
+	if len(name) == 0 || name == config.Default.SHExecute {
+		return config.Default.SHExecute, nil
+	}
+	for _, allowed := range config.SplitList(config.Default.LocalAllow) {
+		// the allowed programs are matched by the full path or by the base name
+		if name == allowed || filepath.Base(allowed) == name && !filepath.IsAbs(name) {
+			return allowed, nil
+		}
+	}
+	return "", ErrProgramNotAllowed
+}
+
+// LocalShell 在伪终端中运行本地 shell 或 -local_allow 允许的程序，消息格式与 library/ssh 相同
+func LocalShell(ctx *Context) error {
+	program, err := localProgram(ParamGet(ctx, "exec"))
+	if err != nil {
+		return err
+	}
+	if err = ctx.Authorize("", 0, filepath.Base(program)); err != nil {
+		return err
+	}
+	query := ctx.Request().URL.Query()
+	var args []string
+	for i := 0; i < 1000; i++ {
+		arguments, ok := query["arg"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		args = append(args, arguments...)
+	}
+	return pty.New(&pty.Config{
+		Command: program,
+		Args:    args,
+		Dir:     query.Get("wd"),
+		Rows:    toInt(query.Get("rows"), 40),
+		Cols:    toInt(query.Get("columns"), 80),
+	}).Serve(ctx.Conn)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+func TestLocalProgram(t *testing.T) {
+	defer func(shell, allow string) {
+		config.Default.SHExecute, config.Default.LocalAllow = shell, allow
+	}(config.Default.SHExecute, config.Default.LocalAllow)
+	config.Default.SHExecute = "bash"
+	config.Default.LocalAllow = "top, /usr/bin/htop"
+
+	for name, want := range map[string]string{
+		"":              "bash",
+		"bash":          "bash",
+		"top":           "top",
+		"htop":          "/usr/bin/htop",
+		"/usr/bin/htop": "/usr/bin/htop",
+		"vim":           "",
+		"/tmp/htop":     "",
+		"/usr/bin/top":  "",
+	} {
+		got, err := localProgram(name)
+		if got != want || (err != nil) != (len(want) == 0) {
+			t.Errorf("localProgram(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
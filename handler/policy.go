@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/admpub/web-terminal/library/audit"
+	"github.com/admpub/web-terminal/library/auth"
+	"github.com/admpub/web-terminal/library/policy"
+)
+
+var (
+	// Policy limits the hosts, protocols and commands of the principals, nil allows everything
+	Policy *policy.Policy
+
+	// AuditLog records the access decisions, nil disables the audit log
+	AuditLog *audit.Logger
+)
+
+// Protocol 中间件：设置 Context 的协议名，用于访问策略
+func Protocol(name string) func(*Context) error {
+	return func(ctx *Context) error {
+		ctx.Protocol = name
+		return nil
+	}
+}
+
+// Authorize checks the access by the Policy and writes the decision to the audit log.
+// host is empty for local commands and replays, command is empty for remote hosts.
+func (ctx *Context) Authorize(host string, port int, command string) error {
+	var remote string
+	if r := ctx.Request(); r != nil {
+		remote = r.RemoteAddr
+	}
+	return authorize(&policy.Request{
+		Principal: ctx.Principal,
+		Protocol:  ctx.Protocol,
+		Host:      host,
+		Port:      port,
+		Command:   command,
+	}, remote)
+}
+
+func authorize(req *policy.Request, remote string) error {
+	var err error
+	if Policy != nil {
+		err = Policy.Check(req)
+	}
+	if AuditLog != nil {
+		event := &audit.Event{
+			Action:    "access",
+			Principal: principalName(req.Principal),
+			Remote:    remote,
+			Protocol:  req.Protocol,
+			Host:      req.Host,
+			Port:      req.Port,
+			Command:   req.Command,
+			Allowed:   err == nil,
+		}
+		var denied *policy.DeniedError
+		if errors.As(err, &denied) {
+			event.Reason = denied.Rule
+		}
+		if aerr := AuditLog.Log(event); aerr != nil {
+			logString(nil, "write audit log failed: "+aerr.Error())
+		}
+	}
+	return err
+}
+
+func principalName(principal *auth.Principal) string {
+	if principal == nil {
+		return ""
+	}
+	return principal.Name
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/auth"
+	"github.com/admpub/web-terminal/library/policy"
+	"github.com/admpub/web-terminal/library/vault"
+)
+
+var (
+	ErrVaultDisabled   = errors.New("the credential vault is disabled, see -vault_key")
+	ErrProfileNotFound = errors.New("profile is not found")
+
+	// Vault stores the host profiles, nil disables the profiles
+	Vault vault.Backend
+)
+
+// RoleAdmin may manage the profiles
+const RoleAdmin = "admin"
+
+func principalRoles(principal *auth.Principal) []string {
+	return Policy.PrincipalRoles(principal)
+}
+
+func isAdmin(principal *auth.Principal) bool {
+	for _, role := range principalRoles(principal) {
+		if role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func profileAllowed(profile *vault.Profile, principal *auth.Principal) bool {
+	if len(profile.Principals) == 0 {
+		return true
+	}
+	return policy.MatchPrincipal(profile.Principals, principal, principalRoles(principal))
+}
+
+// profileHostConfig resolves the profile into the end host config, the jump hosts are added to ctx.Config
+func (ctx *Context) profileHostConfig(id string) (*config.HostConfig, error) {
+	if Vault == nil {
+		return nil, ErrVaultDisabled
+	}
+	chain, err := vault.Chain(Vault, id)
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return nil, ErrProfileNotFound
+		}
+		return nil, err
+	}
+	hostConfigs := make([]*config.HostConfig, 0, len(chain))
+	for _, profile := range chain {
+		// the profiles which are not allowed are reported as not found
+		if !profileAllowed(profile, ctx.Principal) {
+			return nil, ErrProfileNotFound
+		}
+		if err = ctx.Authorize(profile.Host, profile.GetPort(), ""); err != nil {
+			return nil, err
+		}
+		account := profile.Account()
+		account.Agent, err = vault.Agent(Vault, profile)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", profile.ID, err)
+		}
+		account.ForwardAgent = profile.ForwardAgent && account.Agent != nil
+		account.Prompter = ctx.Prompter(profile.Host)
+		if err = ctx.issueCertificate(account, profile.Host, profile.GetPort()); err != nil {
+			return nil, err
+		}
+		if charset := ParamGet(ctx, "charset"); len(charset) > 0 {
+			account.Charset = charset
+		}
+		algorithms, err := profile.Algorithms.Algorithms()
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", profile.ID, err)
+		}
+		hostConfig, err := config.NewHostConfigWithAccount(ctx.Conn, account, profile.Host, profile.GetPort())
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", profile.ID, err)
+		}
+		hostConfigs = append(hostConfigs, hostConfig.SetAccount(account).SetAlgorithms(algorithms))
+	}
+	end := len(hostConfigs) - 1
+	ctx.Config.AddJump(hostConfigs[:end]...)
+	return hostConfigs[end], nil
+}
+
+// Profiles lists the profiles without secrets by GET, saves a profile by POST and deletes a profile by DELETE with the "id" parameter.
+// Only the principals with the admin role may save or delete.
+func Profiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeError := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	if Vault == nil {
+		writeError(http.StatusNotFound, ErrVaultDisabled)
+		return
+	}
+	principal := GetPrincipal(r)
+	if r.Method != http.MethodGet && !isAdmin(principal) {
+		writeError(http.StatusForbidden, errors.New("only the administrators may change the profiles"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		profiles, err := Vault.List()
+		if err != nil {
+			writeError(http.StatusInternalServerError, err)
+			return
+		}
+		list := make([]*vault.Info, 0, len(profiles))
+		for _, profile := range profiles {
+			if profileAllowed(profile, principal) {
+				list = append(list, profile.Info())
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost, http.MethodPut:
+		profile := &vault.Profile{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTicketBody)).Decode(profile); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+		if err := Vault.Put(profile); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+		json.NewEncoder(w).Encode(profile.Info())
+	case http.MethodDelete:
+		err := Vault.Delete(r.URL.Query().Get("id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == vault.ErrNotFound {
+				status = http.StatusNotFound
+			}
+			writeError(status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
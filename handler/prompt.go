@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+)
+
+// PromptTimeout limits the time the user takes to answer a prompt
+var PromptTimeout = 5 * time.Minute
+
+// Prompter asks the browser by {"type":"prompt"} messages, host is shown in the dialog
+func (ctx *Context) Prompter(host string) config.Prompter {
+	return &wsPrompter{conn: ctx.Conn, host: host}
+}
+
+// wsPrompter waits for the {"type":"prompt_answer"} message of the prompt, other messages are dropped
+type wsPrompter struct {
+	conn *websocketx.Conn
+	host string
+	seq  int
+}
+
+func (w *wsPrompter) Prompt(p *config.Prompt) ([]string, error) {
+	w.seq++
+	p.Type = "prompt"
+	p.ID = strconv.Itoa(w.seq)
+	if len(p.Host) == 0 {
+		p.Host = w.host
+	}
+	if err := w.conn.WriteJSON(p); err != nil {
+		return nil, err
+	}
+	// the read deadline is extended by the pongs, the blocked read is failed at the timeout
+	timer := time.AfterFunc(PromptTimeout, func() {
+		w.conn.SetReadDeadline(time.Now())
+	})
+	defer timer.Stop()
+	for {
+		msg, err := w.conn.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if msg.Type != "prompt_answer" {
+			continue // typed in the terminal before connected
+		}
+		answer := config.PromptAnswer{}
+		if err := json.Unmarshal(msg.Raw, &answer); err != nil || answer.ID != p.ID {
+			continue
+		}
+		if answer.Cancel {
+			return nil, config.ErrPromptCanceled
+		}
+		return answer.Answers, nil
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
+)
+
+// RecordingExtension 录像文件扩展名
+const RecordingExtension = ".cast"
+
+func recordDir() string {
+	if len(config.Default.RecordDir) > 0 {
+		return config.Default.RecordDir
+	}
+	return config.AutoRecordDir(config.Default.LogDir)
+}
+
+// isRecordEnabled the "record" parameter may enable recording, it never disables the recording required by config.Default.Record
+func isRecordEnabled(ctx *Context) bool {
+	if config.Default.Record {
+		return true
+	}
+	return "true" == strings.ToLower(ParamGet(ctx, "record"))
+}
+
+func newRecordingID(session *asciicast.Session) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	host := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, session.Host)
+	if len(host) == 0 {
+		host = "localhost"
+	}
+	return time.Now().Format("20060102-150405") + "-" + session.Protocol + "-" + host + "-" + hex.EncodeToString(b)
+}
+
+// newRecorder starts recording the session, it returns nil if recording is disabled
+func newRecorder(ctx *Context, session *asciicast.Session, title string, columns, rows int) (*asciicast.Recorder, error) {
+	if !isRecordEnabled(ctx) {
+		return nil, nil
+	}
+	session.Owner = principalName(ctx.Principal)
+	dir := recordDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the recordings directory: %w", err)
+	}
+	file := filepath.Join(dir, newRecordingID(session)+RecordingExtension)
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the recording: %w", err)
+	}
+	recorder, err := asciicast.NewRecorder(f, &asciicast.Header{
+		Width:   columns,
+		Height:  rows,
+		Title:   title,
+		Env:     map[string]string{"TERM": "xterm"},
+		Session: session,
+	})
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write the recording: %w", err)
+	}
+	return recorder, nil
+}
+
+// multiWriter is like io.MultiWriter but skips nil writers, it returns nil if all writers are nil
+func multiWriter(writers ...io.Writer) io.Writer {
+	var r []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			r = append(r, w)
+		}
+	}
+	switch len(r) {
+	case 0:
+		return nil
+	case 1:
+		return r[0]
+	default:
+		return io.MultiWriter(r...)
+	}
+}
+
+// recordWriter writes the events to the recording until it fails, e.g. the disk is full.
+// The failure is logged and the recording is detached, the terminal keeps working.
+type recordWriter struct {
+	w      io.Writer
+	failed atomic.Bool
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	if w.failed.Load() {
+		return len(p), nil
+	}
+	if _, err := w.w.Write(p); err != nil {
+		w.failed.Store(true)
+		log.Println("recording stopped:", err)
+	}
+	return len(p), nil
+}
+
+func recordOutput(recorder *asciicast.Recorder, dst io.Writer) io.Writer {
+	if recorder == nil {
+		return dst
+	}
+	return io.MultiWriter(dst, &recordWriter{w: recorder.Output()})
+}
+
+// recordInput returns nil unless config.Default.RecordInput is on, the input contains the typed passwords
+func recordInput(recorder *asciicast.Recorder) io.Writer {
+	if recorder == nil || !config.Default.RecordInput {
+		return nil
+	}
+	return &recordWriter{w: recorder.Input()}
+}
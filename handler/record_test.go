@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
+)
+
+func TestIsRecordEnabled(t *testing.T) {
+	defer func(record bool, paramGet func(*Context, string) string) {
+		config.Default.Record, ParamGet = record, paramGet
+	}(config.Default.Record, ParamGet)
+
+	for _, c := range []struct {
+		required bool
+		param    string
+		want     bool
+	}{
+		{false, "", false},
+		{false, "true", true},
+		{false, "false", false},
+		{true, "", true},
+		// the client may not turn off the recording required by the server
+		{true, "false", true},
+	} {
+		config.Default.Record = c.required
+		ParamGet = func(*Context, string) string { return c.param }
+		if got := isRecordEnabled(nil); got != c.want {
+			t.Errorf("record=%v, ?record=%q: got %v", c.required, c.param, got)
+		}
+	}
+}
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("no space left on device")
+}
+
+func TestRecordWriterDetaches(t *testing.T) {
+	var out bytes.Buffer
+	failing := &failingWriter{}
+	w := &recordWriter{w: failing}
+	dst := multiWriter(&out, w)
+	for _, s := range []string{"ls", " -l"} {
+		if n, err := dst.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("the terminal output failed: %d, %v", n, err)
+		}
+	}
+	if out.String() != "ls -l" || failing.writes != 1 {
+		t.Fatalf("output %q, %d writes to the recording", out.String(), failing.writes)
+	}
+}
+
+func TestRecordInputOptIn(t *testing.T) {
+	defer func(recordInput bool) { config.Default.RecordInput = recordInput }(config.Default.RecordInput)
+	var buf bytes.Buffer
+	recorder, err := asciicast.NewRecorder(nopCloser{&buf}, &asciicast.Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Default.RecordInput = false
+	if recordInput(recorder) != nil {
+		t.Fatal("the input must not be recorded by default")
+	}
+	config.Default.RecordInput = true
+	if recordInput(recorder) == nil {
+		t.Fatal("the input is not recorded")
+	}
+	if recordInput(nil) != nil {
+		t.Fatal("nil recorder records nothing")
+	}
+}
+
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/admpub/web-terminal/library/asciicast"
+	"github.com/admpub/web-terminal/library/auth"
+	"github.com/admpub/web-terminal/library/policy"
+)
+
+var (
+	ErrInvalidRecordingID = errors.New("invalid recording id")
+	ErrRecordingNotFound  = errors.New("recording is not found")
+
+	recordingIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// RecordingInfo 录像元数据
+type RecordingInfo struct {
+	ID       string    `json:"id"`
+	Protocol string    `json:"protocol,omitempty"`
+	Host     string    `json:"host,omitempty"`
+	Port     int       `json:"port,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Owner    string    `json:"owner,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	Size     int64     `json:"size"`
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+}
+
+// ResolveRecording returns the path of the recording inside the recordings directory.
+// Path traversal and symbolic links pointing outside the directory are refused.
+func ResolveRecording(id string) (string, error) {
+	id = strings.TrimSuffix(id, RecordingExtension)
+	if !recordingIDRegexp.MatchString(id) || strings.Contains(id, "..") {
+		return "", ErrInvalidRecordingID
+	}
+	root, err := filepath.EvalSymlinks(recordDir())
+	if err != nil {
+		return "", ErrRecordingNotFound
+	}
+	file, err := filepath.EvalSymlinks(filepath.Join(root, id+RecordingExtension))
+	if err != nil {
+		return "", ErrRecordingNotFound
+	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", ErrInvalidRecordingID
+	}
+	fi, err := os.Stat(file)
+	if err != nil || !fi.Mode().IsRegular() {
+		return "", ErrRecordingNotFound
+	}
+	return file, nil
+}
+
+func readRecordingInfo(id string, file string) (*RecordingInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header, duration, err := asciicast.Summary(f)
+	if err != nil {
+		return nil, err
+	}
+	info := &RecordingInfo{
+		ID:       id,
+		Title:    header.Title,
+		Start:    time.Unix(header.Timestamp, 0),
+		Duration: duration,
+		Size:     fi.Size(),
+		Width:    header.Width,
+		Height:   header.Height,
+	}
+	if header.Session != nil {
+		info.Protocol = header.Session.Protocol
+		info.Host = header.Session.Host
+		info.Port = header.Session.Port
+		info.User = header.Session.User
+		info.Owner = header.Session.Owner
+	}
+	return info, nil
+}
+
+// recordingAllowed the recordings can be listed and replayed by their owner and the admins
+func recordingAllowed(owner string, principal *auth.Principal) bool {
+	return owner == principalName(principal) || isAdmin(principal)
+}
+
+// ListRecordings returns the recordings of the principal in the recordings directory, the newest first
+func ListRecordings(principal *auth.Principal) ([]*RecordingInfo, error) {
+	entries, err := os.ReadDir(recordDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*RecordingInfo{}, nil
+		}
+		return nil, err
+	}
+	list := make([]*RecordingInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), RecordingExtension) {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), RecordingExtension)
+		file, err := ResolveRecording(id)
+		if err != nil {
+			continue
+		}
+		info, err := readRecordingInfo(id, file)
+		if err != nil || !recordingAllowed(info.Owner, principal) {
+			continue
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start.After(list[j].Start)
+	})
+	return list, nil
+}
+
+// Recordings lists the recordings, or returns the metadata of one recording by the "id" parameter
+func Recordings(w http.ResponseWriter, r *http.Request) {
+	var data interface{}
+	principal := GetPrincipal(r)
+	err := authorize(&policy.Request{Principal: principal, Protocol: "replay"}, r.RemoteAddr)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if id := r.URL.Query().Get("id"); len(id) > 0 {
+		var file string
+		file, err = ResolveRecording(id)
+		var info *RecordingInfo
+		if err == nil {
+			info, err = readRecordingInfo(strings.TrimSuffix(id, RecordingExtension), file)
+		}
+		if err == nil && !recordingAllowed(info.Owner, principal) {
+			err = ErrRecordingNotFound
+		}
+		data = info
+	} else {
+		data, err = ListRecordings(principal)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case ErrInvalidRecordingID:
+			status = http.StatusBadRequest
+		case ErrRecordingNotFound:
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err = json.NewEncoder(w).Encode(data); err != nil {
+		logString(nil, fmt.Sprintf("encode recordings failed: %v", err))
+	}
+}
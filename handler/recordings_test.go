@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
+	"github.com/admpub/web-terminal/library/auth"
+)
+
+func TestResolveRecording(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	old := config.Default.RecordDir
+	config.Default.RecordDir = root
+	defer func() { config.Default.RecordDir = old }()
+
+	os.WriteFile(filepath.Join(root, `20240101-000000-ssh-host-1234.cast`), []byte(`{"version":2}`), 0600)
+	os.WriteFile(filepath.Join(outside, `secret.cast`), []byte(`{"version":2}`), 0600)
+	if err := os.Symlink(filepath.Join(outside, `secret.cast`), filepath.Join(root, `escape.cast`)); err != nil {
+		t.Skip(err)
+	}
+
+	if _, err := ResolveRecording(`20240101-000000-ssh-host-1234`); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{`../secret`, `..`, `/etc/shadow`, `a/../../secret`, `escape`, `missing`, ``} {
+		if file, err := ResolveRecording(id); err == nil {
+			t.Errorf(`%q must be refused, resolved to %s`, id, file)
+		}
+	}
+}
+
+func TestRecordingsByOwner(t *testing.T) {
+	root := t.TempDir()
+	old := config.Default.RecordDir
+	config.Default.RecordDir = root
+	defer func() { config.Default.RecordDir = old }()
+	for id, owner := range map[string]string{"alice-1": "alice", "bob-1": "bob"} {
+		f, err := os.Create(filepath.Join(root, id+RecordingExtension))
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := asciicast.NewRecorder(f, &asciicast.Header{Width: 80, Height: 24, Session: &asciicast.Session{Protocol: "ssh", Owner: owner}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Close()
+	}
+	alice := &auth.Principal{Name: "alice"}
+	admin := &auth.Principal{Name: "carol", Roles: []string{RoleAdmin}}
+
+	list, err := ListRecordings(alice)
+	if err != nil || len(list) != 1 || list[0].ID != "alice-1" || list[0].Owner != "alice" {
+		t.Fatalf("alice must list her recording only: %+v, %v", list, err)
+	}
+	if list, _ = ListRecordings(admin); len(list) != 2 {
+		t.Fatalf("the admin must list all the recordings: %+v", list)
+	}
+	if list, _ = ListRecordings(nil); len(list) != 0 {
+		t.Fatalf("the anonymous user must not list the recordings of the others: %+v", list)
+	}
+
+	get := func(principal *auth.Principal, id string) int {
+		r := httptest.NewRequest(http.MethodGet, "/recordings?id="+id, nil)
+		r = r.WithContext(context.WithValue(r.Context(), PrincipalContextKey, principal))
+		w := httptest.NewRecorder()
+		Recordings(w, r)
+		return w.Code
+	}
+	if code := get(alice, "alice-1"); code != http.StatusOK {
+		t.Fatalf("alice gets %d for her recording", code)
+	}
+	if code := get(alice, "bob-1"); code != http.StatusNotFound {
+		t.Fatalf("alice gets %d for the recording of bob", code)
+	}
+	if code := get(admin, "bob-1"); code != http.StatusOK {
+		t.Fatalf("the admin gets %d", code)
+	}
+}
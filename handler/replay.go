@@ -1,24 +1,132 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/admpub/web-terminal/library/asciicast"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
 )
 
-// Replay .
+// ReplayControl is sent by the browser to control the playback
+type ReplayControl struct {
+	Type   string  `json:"type"`             // pause, resume, toggle, speed, seek, search
+	Speed  float64 `json:"speed,omitempty"`  // speed
+	Offset float64 `json:"offset,omitempty"` // seek, in seconds
+	Text   string  `json:"text,omitempty"`   // search
+}
+
+// Replay plays the recording addressed by the "id" parameter, see ResolveRecording
 func Replay(ctx *Context) error {
 	defer ctx.Close()
-	fileName := ParamGet(ctx, "file")
-	charset := fixCharset(ParamGet(ctx, "charset"))
+	if err := ctx.Authorize("", 0, ""); err != nil {
+		return err
+	}
+	id := ParamGet(ctx, "id")
+	fileName, err := ResolveRecording(id)
+	if nil != err {
+		return fmt.Errorf("open recording %q failed: %w", id, err)
+	}
 	dumpOut, err := os.Open(fileName)
 	if nil != err {
-		return fmt.Errorf("open '"+fileName+"' failed: %w", err)
+		return fmt.Errorf("open recording %q failed: %w", id, err)
 	}
 	defer dumpOut.Close()
 
-	if _, err := io.Copy(decodeBy(charset, ctx), dumpOut); err != nil {
-		return fmt.Errorf("copy of stdout failed: %w", err)
+	header, events, err := asciicast.Decode(dumpOut)
+	if err != nil {
+		return fmt.Errorf("read recording %q failed: %w", id, err)
+	}
+	var owner string
+	if header.Session != nil {
+		owner = header.Session.Owner
+	}
+	if !recordingAllowed(owner, ctx.Principal) {
+		// the recordings of the others are not revealed
+		return fmt.Errorf("open recording %q failed: %w", id, ErrRecordingNotFound)
+	}
+
+	player := asciicast.NewPlayer(header, events, ctx)
+	idleLimit := 2 * time.Second
+	if header.IdleTimeLimit > 0 {
+		idleLimit = time.Duration(header.IdleTimeLimit * float64(time.Second))
+	}
+	if v := ParamGet(ctx, "idle_limit"); len(v) > 0 {
+		if d, err := time.ParseDuration(v); err == nil {
+			idleLimit = d
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+			idleLimit = time.Duration(f * float64(time.Second))
+		}
+	}
+	player.SetIdleTimeLimit(idleLimit)
+	if v := ParamGet(ctx, "speed"); len(v) > 0 {
+		if speed, err := strconv.ParseFloat(v, 64); err == nil {
+			player.SetSpeed(speed)
+		}
+	}
+
+	playCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		receiveReplayControl(ctx.Conn, player)
+	}()
+	if v := ParamGet(ctx, "search"); len(v) > 0 {
+		player.Search(v)
+	} else if v := ParamGet(ctx, "offset"); len(v) > 0 {
+		if offset, err := strconv.ParseFloat(v, 64); err == nil {
+			player.Seek(time.Duration(offset * float64(time.Second)))
+		}
+	}
+	err = player.Play(playCtx)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// receiveReplayControl handles the control messages until the websocket is closed.
+// Keys typed in the terminal are supported too: space toggles pause, "+" and "-" change the speed.
+func receiveReplayControl(ws *websocketx.Conn, player *asciicast.Player) {
+	for {
+		msg, err := ws.Receive()
+		if err != nil {
+			return
+		}
+		if msg.Type == websocketx.MessageTypeStdin {
+			for _, key := range string(msg.Data) {
+				switch key {
+				case ' ':
+					player.TogglePause()
+				case '+':
+					player.SetSpeed(player.Speed() * 2)
+				case '-':
+					player.SetSpeed(player.Speed() / 2)
+				}
+			}
+			continue
+		}
+		control := ReplayControl{}
+		if err := json.Unmarshal(msg.Raw, &control); err != nil {
+			continue
+		}
+		switch control.Type {
+		case "pause":
+			player.Pause()
+		case "resume":
+			player.Resume()
+		case "toggle":
+			player.TogglePause()
+		case "speed":
+			player.SetSpeed(control.Speed)
+		case "seek":
+			player.Seek(time.Duration(control.Offset * float64(time.Second)))
+		case "search":
+			player.Search(control.Text)
+		}
 	}
-	return nil
 }
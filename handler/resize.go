@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ResizeDebounce 合并拖动浏览器窗口时连续的 resize 消息，每个间隔内只应用最后的大小
+var ResizeDebounce = 100 * time.Millisecond
+
+// resizer applies the last requested terminal size at most once per ResizeDebounce
+type resizer struct {
+	mu          sync.Mutex
+	cols, rows  int // the applied size
+	pendingCols int
+	pendingRows int
+	timer       *time.Timer
+	stopped     bool
+	applyMu     sync.Mutex // the sizes are applied in order
+	apply       func(cols, rows int) error
+}
+
+func newResizer(cols, rows int, apply func(cols, rows int) error) *resizer {
+	return &resizer{cols: cols, rows: rows, apply: apply}
+}
+
+// Resize schedules the size, the size already applied is ignored
+func (r *resizer) Resize(cols, rows int) {
+	if cols <= 0 || rows <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	r.pendingCols, r.pendingRows = cols, rows
+	if r.timer == nil {
+		r.timer = time.AfterFunc(ResizeDebounce, r.flush)
+	}
+}
+
+func (r *resizer) flush() {
+	r.mu.Lock()
+	r.timer = nil
+	cols, rows := r.pendingCols, r.pendingRows
+	if r.stopped || cols == r.cols && rows == r.rows {
+		r.mu.Unlock()
+		return
+	}
+	r.cols, r.rows = cols, rows
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+	r.mu.Unlock()
+	if err := r.apply(cols, rows); err != nil {
+		log.Println("resize failed:", err)
+	}
+}
+
+// Stop drops the scheduled size
+func (r *resizer) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResizer(t *testing.T) {
+	defer func(d time.Duration) { ResizeDebounce = d }(ResizeDebounce)
+	ResizeDebounce = 20 * time.Millisecond
+
+	var mu sync.Mutex
+	var applied [][2]int
+	r := newResizer(80, 24, func(cols, rows int) error {
+		mu.Lock()
+		applied = append(applied, [2]int{cols, rows})
+		mu.Unlock()
+		return nil
+	})
+	check := func(want ...[2]int) {
+		t.Helper()
+		time.Sleep(5 * ResizeDebounce)
+		mu.Lock()
+		defer mu.Unlock()
+		if len(applied) != len(want) {
+			t.Fatalf("applied %v, want %v", applied, want)
+		}
+		for i := range want {
+			if applied[i] != want[i] {
+				t.Fatalf("applied %v, want %v", applied, want)
+			}
+		}
+	}
+
+	// the burst of a dragged window is applied once with the last size
+	r.Resize(90, 30)
+	r.Resize(100, 35)
+	r.Resize(120, 40)
+	check([2]int{120, 40})
+
+	// the applied size and the invalid sizes are ignored
+	r.Resize(120, 40)
+	r.Resize(0, 10)
+	check([2]int{120, 40})
+
+	r.Resize(80, 24)
+	r.Stop()
+	r.Resize(100, 30)
+	check([2]int{120, 40})
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/session"
+	sshx "github.com/admpub/web-terminal/library/ssh"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+)
+
+var (
+	// Sessions 断线后保留的 ssh 会话，在宽限期内可以用恢复令牌重新连接，也可以通过共享链接观看或协助操作
+	Sessions = session.NewManager(session.DefaultGracePeriod, session.DefaultBufferSize)
+
+	ErrNotSessionOwner = errors.New("only the owner can share the session or revoke the viewers")
+)
+
+// SessionMessage tells the browser its role in the session,
+// the owner gets the token to resume the session after the websocket is disconnected
+type SessionMessage struct {
+	Type   string       `json:"type"`            // session, resumed or joined
+	Token  string       `json:"token,omitempty"` // the resume token of the owner, it changes on every resume
+	Offset int64        `json:"offset"`          // the output offset of the next frame, the output before it is lost if it is greater than the requested offset
+	Grace  int          `json:"grace,omitempty"` // the grace period in seconds
+	ID     string       `json:"id,omitempty"`    // the participant id of the viewer
+	Role   session.Role `json:"role"`
+}
+
+// ShareControl is sent by the browser to manage the sharing and the input control:
+// share (the owner creates a link of the role), unshare (the owner revokes the link of the token),
+// revoke (the owner closes the viewer of the id), control (take the control) and release (give up the control)
+type ShareControl struct {
+	Type  string       `json:"type"`
+	Role  session.Role `json:"role,omitempty"`
+	Token string       `json:"token,omitempty"`
+	ID    string       `json:"id,omitempty"`
+}
+
+// ShareMessage returns the share link token to the owner
+type ShareMessage struct {
+	Type    string       `json:"type"` // share
+	Token   string       `json:"token,omitempty"`
+	Role    session.Role `json:"role,omitempty"`
+	Expires *time.Time   `json:"expires,omitempty"` // the link can not be joined after it
+	Error   string       `json:"error,omitempty"`
+}
+
+// InputDeniedMessage tells the participant that its input is dropped
+type InputDeniedMessage struct {
+	Type       string `json:"type"` // input_denied
+	Reason     string `json:"reason"`
+	Controller string `json:"controller,omitempty"` // the participant holding the control
+}
+
+// shellSession is the state of a resumable ssh shell
+type shellSession struct {
+	client  *sshx.SSH
+	config  *config.SSHConfig
+	resizer *resizer
+}
+
+// resumeShell attaches the websocket to the session of the resume token,
+// the output since the "offset" parameter is replayed
+func (ctx *Context) resumeShell(token string) error {
+	sess, err := Sessions.Resume(token, principalName(ctx.Principal))
+	if err != nil {
+		return err
+	}
+	state, ok := sess.Value.(*shellSession)
+	if !ok {
+		return session.ErrSessionNotFound
+	}
+	ctx.Config = state.config
+	offset, _ := strconv.ParseInt(ParamGet(ctx, "offset"), 10, 64)
+	return ctx.attachShell(sess, offset, "resumed")
+}
+
+// joinShell attaches the websocket to the session of the share link
+func (ctx *Context) joinShell(shareToken string) error {
+	sess, err := Sessions.Shared(shareToken)
+	if err != nil {
+		return err
+	}
+	state, ok := sess.Value.(*shellSession)
+	if !ok {
+		return session.ErrShareNotFound
+	}
+	ctx.Config = state.config
+	if err = ctx.Authorize(state.config.End.Host, state.config.End.Port, ""); err != nil {
+		return err
+	}
+	name := principalName(ctx.Principal)
+	if len(name) == 0 {
+		name = "guest"
+	}
+	p, err := sess.Join(ctx.Conn, name, shareToken, func(p *session.Participant) interface{} {
+		return &SessionMessage{Type: "joined", ID: p.ID, Role: p.Role}
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.serveParticipant(sess, p)
+}
+
+// attachShell attaches the websocket to the session as the owner
+func (ctx *Context) attachShell(sess *session.Session, offset int64, messageType string) error {
+	token := sess.Token()
+	p, err := sess.Attach(ctx.Conn, offset, func(start int64) interface{} {
+		if !Sessions.Resumable() {
+			return nil
+		}
+		return &SessionMessage{
+			Type:   messageType,
+			Token:  token,
+			Offset: start,
+			Grace:  int(Sessions.GracePeriod().Seconds()),
+			Role:   session.RoleOwner,
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.serveParticipant(sess, p)
+}
+
+// serveParticipant sends the input of the websocket to the shell until the websocket or the shell is closed.
+// The session is kept for the grace period if the owner's websocket is closed first.
+func (ctx *Context) serveParticipant(sess *session.Session, p *session.Participant) error {
+	defer sess.Leave(p)
+	input := make(chan struct{})
+	go func() {
+		defer close(input)
+		denied := false
+		for {
+			msg, err := ctx.Receive()
+			if err != nil {
+				return
+			}
+			if msg.Type == websocketx.MessageTypeResize {
+				// the terminal has the size of the owner's browser
+				if state, ok := sess.Value.(*shellSession); ok && state.resizer != nil && p.Role == session.RoleOwner {
+					state.resizer.Resize(msg.Cols, msg.Rows)
+				}
+				continue
+			}
+			if msg.Type != websocketx.MessageTypeStdin {
+				if control := parseShareControl(msg.Raw); control != nil {
+					ctx.handleShareControl(sess, p, control)
+				}
+				continue
+			}
+			err = sess.WriteInput(p, msg.Data)
+			if err == nil {
+				denied = false
+				continue
+			}
+			if errors.Is(err, session.ErrReadOnly) || errors.Is(err, session.ErrControlBusy) {
+				// tell once until the input is accepted again
+				if !denied {
+					denied = true
+					ctx.sendInputDenied(sess, err)
+				}
+				continue
+			}
+			return
+		}
+	}()
+	select {
+	case <-sess.Done():
+	case <-input:
+		select {
+		case <-sess.Done():
+		default:
+			return nil
+		}
+	}
+	if p.Role != session.RoleOwner {
+		return nil
+	}
+	sess.Leave(p) // the output queued before the reason
+	return ctx.checkConnectionLost(sess.Value.(*shellSession).client, sess.Err())
+}
+
+func (ctx *Context) sendInputDenied(sess *session.Session, err error) {
+	ctx.WriteJSON(&InputDeniedMessage{Type: "input_denied", Reason: err.Error(), Controller: sess.Controller()})
+}
+
+// parseShareControl returns nil if the message is not a ShareControl, e.g. the prompt answer
+func parseShareControl(data []byte) *ShareControl {
+	control := &ShareControl{}
+	if err := json.Unmarshal(data, control); err != nil {
+		return nil
+	}
+	switch control.Type {
+	case "share", "unshare", "revoke", "control", "release":
+		return control
+	default:
+		return nil
+	}
+}
+
+func (ctx *Context) handleShareControl(sess *session.Session, p *session.Participant, control *ShareControl) {
+	switch control.Type {
+	case "control":
+		if err := sess.TakeControl(p); err != nil {
+			ctx.sendInputDenied(sess, err)
+		}
+		return
+	case "release":
+		sess.ReleaseControl(p)
+		return
+	}
+	if p.Role != session.RoleOwner {
+		ctx.WriteJSON(&ShareMessage{Type: "share", Error: ErrNotSessionOwner.Error()})
+		return
+	}
+	switch control.Type {
+	case "share":
+		token, expires, err := sess.Share(control.Role, config.Default.ShareTTL)
+		if err != nil {
+			ctx.WriteJSON(&ShareMessage{Type: "share", Error: err.Error()})
+			return
+		}
+		ctx.WriteJSON(&ShareMessage{Type: "share", Token: token, Role: control.Role, Expires: &expires})
+	case "unshare":
+		sess.Unshare(control.Token)
+	case "revoke":
+		if err := sess.Revoke(control.ID); err != nil {
+			ctx.WriteJSON(&ShareMessage{Type: "share", Error: err.Error()})
+		}
+	}
+}
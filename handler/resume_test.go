@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/session"
+	"golang.org/x/net/websocket"
+)
+
+type nopClient struct{ bytes.Buffer }
+
+func (*nopClient) Close() error { return nil }
+
+func TestParseShareControl(t *testing.T) {
+	for data, want := range map[string]string{
+		`{"type":"share","role":"readonly"}`: "share",
+		`{"type":"control"}`:                 "control",
+		`{"type":"prompt_answer"}`:           "",
+		`{"type":"share"`:                    "",
+		`ls -l`:                              "",
+	} {
+		var got string
+		if control := parseShareControl([]byte(data)); control != nil {
+			got = control.Type
+		}
+		if got != want {
+			t.Errorf("%s: type %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestJoinShell(t *testing.T) {
+	old := Sessions
+	Sessions = session.NewManager(time.Minute, 1024)
+	defer func() { Sessions = old }()
+
+	sess, err := Sessions.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	input := &bytes.Buffer{}
+	sess.Input = input
+	sess.Value = &shellSession{config: &config.SSHConfig{End: &config.HostConfig{Host: "10.0.0.1", Port: 22}}}
+	if _, err = sess.Attach(&nopClient{}, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	sess.Write([]byte("$ "))
+	share, _, err := sess.Share(session.RoleReadOnly, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(BuidHandler(SSHShell))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ssh?share=" + share
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	joined := &SessionMessage{}
+	if err = websocket.JSON.Receive(ws, joined); err != nil {
+		t.Fatal(err)
+	}
+	if joined.Type != "joined" || joined.Role != session.RoleReadOnly || len(joined.Token) > 0 {
+		t.Fatalf("joined = %+v", joined)
+	}
+	var replay string
+	if err = websocket.Message.Receive(ws, &replay); err != nil || replay != "$ " {
+		t.Fatalf("replay = %q, %v", replay, err)
+	}
+	presence := &session.PresenceMessage{}
+	if err = websocket.JSON.Receive(ws, presence); err != nil || len(presence.Participants) != 2 {
+		t.Fatalf("presence = %+v, %v", presence, err)
+	}
+
+	websocket.Message.Send(ws, "rm -rf /\n")
+	denied := &InputDeniedMessage{}
+	if err = websocket.JSON.Receive(ws, denied); err != nil || denied.Type != "input_denied" {
+		t.Fatalf("denied = %+v, %v", denied, err)
+	}
+	websocket.Message.Send(ws, `{"type":"share","role":"readwrite"}`)
+	reply := &ShareMessage{}
+	if err = websocket.JSON.Receive(ws, reply); err != nil || reply.Error != ErrNotSessionOwner.Error() {
+		t.Fatalf("the viewer shared the session: %+v, %v", reply, err)
+	}
+	if input.Len() > 0 {
+		t.Fatalf("the read-only input reached the terminal: %q", input.String())
+	}
+	if b, _ := json.Marshal(sess.Participants()); !bytes.Contains(b, []byte(joined.ID)) {
+		t.Fatalf("the viewer is not listed: %s", b)
+	}
+}
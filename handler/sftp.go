@@ -0,0 +1,456 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+
+	sshx "github.com/admpub/web-terminal/library/ssh"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/pkg/sftp"
+)
+
+const (
+	sftpDefaultChunkSize = 32 * 1024
+	sftpMaxChunkSize     = 1024 * 1024
+	// sftpReadLimit leaves room for a base64 chunk in a JSON request wrapped by a legacy stdin message
+	sftpReadLimit = 4 * sftpMaxChunkSize
+)
+
+// SFTPRequest is sent by the browser as a JSON text frame, {"type":"sftp"} if the Message schema is used
+type SFTPRequest struct {
+	ID        string `json:"id"`
+	Op        string `json:"op"`
+	Path      string `json:"path,omitempty"`
+	Target    string `json:"target,omitempty"`    // rename
+	Mode      string `json:"mode,omitempty"`      // chmod, mkdir, upload. octal, e.g. "0644"
+	Parents   bool   `json:"parents,omitempty"`   // mkdir -p
+	Recursive bool   `json:"recursive,omitempty"` // remove
+	Offset    int64  `json:"offset,omitempty"`    // download, upload, write
+	Size      int64  `json:"size,omitempty"`      // upload
+	ChunkSize int    `json:"chunkSize,omitempty"` // download
+	Data      []byte `json:"data,omitempty"`      // write
+}
+
+// SFTPResponse is sent to the browser as a JSON text frame
+type SFTPResponse struct {
+	Type   string          `json:"type"` // sftp
+	ID     string          `json:"id"`
+	Op     string          `json:"op"`
+	Error  string          `json:"error,omitempty"`
+	Path   string          `json:"path,omitempty"`
+	File   *SFTPFileInfo   `json:"file,omitempty"`
+	Files  []*SFTPFileInfo `json:"files,omitempty"`
+	Offset int64           `json:"offset,omitempty"`
+	Size   int64           `json:"size,omitempty"`
+	Data   []byte          `json:"data,omitempty"`
+	EOF    bool            `json:"eof,omitempty"`
+}
+
+type SFTPFileInfo struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	Perm    string `json:"perm"`
+	IsDir   bool   `json:"isDir"`
+	IsLink  bool   `json:"isLink"`
+	ModTime int64  `json:"modTime"`
+}
+
+func newSFTPFileInfo(dir string, fi os.FileInfo) *SFTPFileInfo {
+	return &SFTPFileInfo{
+		Name:    fi.Name(),
+		Path:    path.Join(dir, fi.Name()),
+		Size:    fi.Size(),
+		Mode:    fi.Mode().String(),
+		Perm:    fmt.Sprintf("%04o", fi.Mode().Perm()),
+		IsDir:   fi.IsDir(),
+		IsLink:  fi.Mode()&os.ModeSymlink != 0,
+		ModTime: fi.ModTime().Unix(),
+	}
+}
+
+// SFTP 文件管理
+func SFTP(ctx *Context) error {
+	defer ctx.Close()
+	if ctx.Config.End == nil {
+		hostConfig, err := ctx.GetHostConfig()
+		if err != nil {
+			return fmt.Errorf("Failed to dial: %w", err)
+		}
+		ctx.Config.SetEnd(hostConfig)
+	}
+	sshClient, err := sshx.NewClient(context.Background(), ctx.Config, 0)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+	defer client.Close()
+	return newSFTPSession(ctx.Conn, client).serve()
+}
+
+func newSFTPSession(ws *websocketx.Conn, client *sftp.Client) *sftpSession {
+	ws.SetReadLimit(sftpReadLimit)
+	return &sftpSession{
+		ws:        ws,
+		client:    client,
+		uploads:   map[string]*sftpUpload{},
+		downloads: map[string]context.CancelFunc{},
+	}
+}
+
+type sftpUpload struct {
+	file *sftp.File
+	path string
+	size int64
+}
+
+type sftpSession struct {
+	ws        *websocketx.Conn
+	client    *sftp.Client
+	mu        sync.Mutex
+	uploads   map[string]*sftpUpload
+	downloads map[string]context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func (s *sftpSession) send(resp *SFTPResponse) error {
+	resp.Type = "sftp"
+	return s.ws.WriteJSON(resp)
+}
+
+func (s *sftpSession) serve() error {
+	defer s.cleanup()
+	for {
+		msg, err := s.ws.Receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		// the requests of the legacy clients have no type
+		data := msg.Raw
+		if msg.Type == websocketx.MessageTypeStdin {
+			data = msg.Data
+		}
+		req := &SFTPRequest{}
+		if err = json.Unmarshal(data, req); err != nil {
+			// the id is answered if it can be found, the session goes on
+			var id struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(data, &id)
+			if err = s.send(&SFTPResponse{ID: id.ID, Error: "malformed request: " + err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		resp, err := s.handle(req)
+		if resp == nil {
+			continue
+		}
+		resp.ID = req.ID
+		resp.Op = req.Op
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		if err = s.send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *sftpSession) cleanup() {
+	s.mu.Lock()
+	for _, cancel := range s.downloads {
+		cancel()
+	}
+	for id, upload := range s.uploads {
+		upload.file.Close()
+		delete(s.uploads, id)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// handle returns nil response if the answer is sent asynchronously
+func (s *sftpSession) handle(req *SFTPRequest) (*SFTPResponse, error) {
+	resp := &SFTPResponse{Path: req.Path}
+	switch req.Op {
+	case "list":
+		dir := req.Path
+		if len(dir) == 0 {
+			wd, err := s.client.Getwd()
+			if err != nil {
+				return resp, err
+			}
+			dir = wd
+			resp.Path = wd
+		}
+		files, err := s.client.ReadDir(dir)
+		if err != nil {
+			return resp, err
+		}
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].IsDir() != files[j].IsDir() {
+				return files[i].IsDir()
+			}
+			return files[i].Name() < files[j].Name()
+		})
+		resp.Files = make([]*SFTPFileInfo, len(files))
+		for i, fi := range files {
+			resp.Files[i] = newSFTPFileInfo(dir, fi)
+		}
+	case "stat":
+		fi, err := s.client.Lstat(req.Path)
+		if err != nil {
+			return resp, err
+		}
+		resp.File = newSFTPFileInfo(path.Dir(req.Path), fi)
+	case "chmod":
+		mode, err := parseFileMode(req.Mode, 0)
+		if err != nil {
+			return resp, err
+		}
+		return resp, s.client.Chmod(req.Path, mode)
+	case "mkdir":
+		var err error
+		if req.Parents {
+			err = s.client.MkdirAll(req.Path)
+		} else {
+			err = s.client.Mkdir(req.Path)
+		}
+		if err != nil || len(req.Mode) == 0 {
+			return resp, err
+		}
+		mode, err := parseFileMode(req.Mode, 0755)
+		if err != nil {
+			return resp, err
+		}
+		return resp, s.client.Chmod(req.Path, mode)
+	case "rename":
+		if len(req.Target) == 0 {
+			return resp, errors.New("target is required")
+		}
+		resp.Path = req.Target
+		if _, ok := s.client.HasExtension("posix-rename@openssh.com"); ok {
+			return resp, s.client.PosixRename(req.Path, req.Target)
+		}
+		return resp, s.client.Rename(req.Path, req.Target)
+	case "remove":
+		if req.Recursive {
+			return resp, s.client.RemoveAll(req.Path)
+		}
+		return resp, s.client.Remove(req.Path)
+	case "download":
+		return s.download(req)
+	case "cancel":
+		s.mu.Lock()
+		if cancel, ok := s.downloads[req.ID]; ok {
+			cancel()
+		}
+		if upload, ok := s.uploads[req.ID]; ok {
+			upload.file.Close()
+			delete(s.uploads, req.ID)
+		}
+		s.mu.Unlock()
+	case "upload":
+		return resp, s.openUpload(req)
+	case "write":
+		return s.writeUpload(req)
+	case "close":
+		return s.closeUpload(req)
+	default:
+		return resp, fmt.Errorf("unsupported operation: %q", req.Op)
+	}
+	return resp, nil
+}
+
+func parseFileMode(s string, defaults os.FileMode) (os.FileMode, error) {
+	if len(s) == 0 {
+		if defaults == 0 {
+			return 0, errors.New("mode is required")
+		}
+		return defaults, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q: %w", s, err)
+	}
+	return os.FileMode(mode) & os.ModePerm, nil
+}
+
+// download streams the file in chunks, each chunk carries the offset and the file size for progress
+func (s *sftpSession) download(req *SFTPRequest) (*SFTPResponse, error) {
+	resp := &SFTPResponse{Path: req.Path}
+	file, err := s.client.Open(req.Path)
+	if err != nil {
+		return resp, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return resp, err
+	}
+	if fi.IsDir() {
+		file.Close()
+		return resp, errors.New("can not download a directory")
+	}
+	if req.Offset > 0 {
+		if _, err = file.Seek(req.Offset, io.SeekStart); err != nil {
+			file.Close()
+			return resp, err
+		}
+	}
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = sftpDefaultChunkSize
+	} else if chunkSize > sftpMaxChunkSize {
+		chunkSize = sftpMaxChunkSize
+	}
+	downloadCtx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if _, ok := s.downloads[req.ID]; ok {
+		s.mu.Unlock()
+		cancel()
+		file.Close()
+		return resp, fmt.Errorf("download %q is already in progress", req.ID)
+	}
+	s.downloads[req.ID] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			file.Close()
+			s.mu.Lock()
+			delete(s.downloads, req.ID)
+			s.mu.Unlock()
+			cancel()
+			s.wg.Done()
+		}()
+		offset := req.Offset
+		buf := make([]byte, chunkSize)
+		for {
+			if downloadCtx.Err() != nil {
+				s.send(&SFTPResponse{ID: req.ID, Op: req.Op, Path: req.Path, Offset: offset, Size: fi.Size(), Error: `canceled`})
+				return
+			}
+			n, err := file.Read(buf)
+			chunk := &SFTPResponse{ID: req.ID, Op: req.Op, Path: req.Path, Offset: offset, Size: fi.Size()}
+			if n > 0 {
+				chunk.Data = buf[:n]
+				offset += int64(n)
+			}
+			if err != nil {
+				if err == io.EOF {
+					chunk.EOF = true
+				} else {
+					chunk.Error = err.Error()
+				}
+			}
+			if n > 0 || err != nil {
+				if sendErr := s.send(chunk); sendErr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return nil, nil
+}
+
+func (s *sftpSession) openUpload(req *SFTPRequest) error {
+	mode, err := parseFileMode(req.Mode, 0644)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if req.Offset <= 0 {
+		flags |= os.O_TRUNC
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.uploads[req.ID]; ok {
+		return fmt.Errorf("upload %q is already in progress", req.ID)
+	}
+	file, err := s.client.OpenFile(req.Path, flags)
+	if err != nil {
+		return err
+	}
+	if err = file.Chmod(mode); err != nil {
+		file.Close()
+		return err
+	}
+	s.uploads[req.ID] = &sftpUpload{file: file, path: req.Path, size: req.Size}
+	return nil
+}
+
+func (s *sftpSession) getUpload(id string) (*sftpUpload, error) {
+	s.mu.Lock()
+	upload, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("upload %q is not found", id)
+	}
+	return upload, nil
+}
+
+// writeUpload writes one chunk and answers with the new offset for progress
+func (s *sftpSession) writeUpload(req *SFTPRequest) (*SFTPResponse, error) {
+	resp := &SFTPResponse{}
+	upload, err := s.getUpload(req.ID)
+	if err != nil {
+		return resp, err
+	}
+	resp.Path = upload.path
+	resp.Size = upload.size
+	if len(req.Data) > sftpMaxChunkSize {
+		return resp, fmt.Errorf("chunk is too large: %d > %d", len(req.Data), sftpMaxChunkSize)
+	}
+	// the chunk must be within the size announced by the upload request
+	if req.Offset < 0 || upload.size > 0 && req.Offset+int64(len(req.Data)) > upload.size {
+		return resp, fmt.Errorf("chunk at offset %d is out of the file size %d", req.Offset, upload.size)
+	}
+	n, err := upload.file.WriteAt(req.Data, req.Offset)
+	resp.Offset = req.Offset + int64(n)
+	return resp, err
+}
+
+func (s *sftpSession) closeUpload(req *SFTPRequest) (*SFTPResponse, error) {
+	resp := &SFTPResponse{}
+	upload, err := s.getUpload(req.ID)
+	if err != nil {
+		return resp, err
+	}
+	s.mu.Lock()
+	delete(s.uploads, req.ID)
+	s.mu.Unlock()
+	resp.Path = upload.path
+	if err = upload.file.Close(); err != nil {
+		return resp, err
+	}
+	fi, err := s.client.Stat(upload.path)
+	if err != nil {
+		return resp, err
+	}
+	resp.File = newSFTPFileInfo(path.Dir(upload.path), fi)
+	resp.Size = fi.Size()
+	resp.EOF = true
+	return resp, nil
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/admpub/websocket"
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTPClient connects a client to an in-process sftp server of the local file system
+func newTestSFTPClient(t *testing.T) *sftp.Client {
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRead, serverWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return client
+}
+
+type sftpTestClient struct {
+	t  *testing.T
+	ws *websocket.Conn
+	// session is sent after serve returns
+	session chan *sftpSession
+}
+
+func newSFTPTestClient(t *testing.T) *sftpTestClient {
+	client := newTestSFTPClient(t)
+	c := &sftpTestClient{t: t, session: make(chan *sftpSession, 1)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		s := newSFTPSession(ws, client)
+		s.serve()
+		c.session <- s
+	}))
+	t.Cleanup(server.Close)
+	dialer := websocket.Dialer{Subprotocols: []string{websocketx.Subprotocol}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { ws.Close() })
+	c.ws = ws
+	return c
+}
+
+func (c *sftpTestClient) send(req *SFTPRequest) {
+	c.t.Helper()
+	msg := struct {
+		Version int    `json:"v"`
+		Type    string `json:"type"`
+		*SFTPRequest
+	}{websocketx.ProtocolVersion, "sftp", req}
+	if err := c.ws.WriteJSON(&msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *sftpTestClient) receive() *SFTPResponse {
+	c.t.Helper()
+	resp := &SFTPResponse{}
+	if err := c.ws.ReadJSON(resp); err != nil {
+		c.t.Fatal(err)
+	}
+	if resp.Type != "sftp" {
+		c.t.Fatalf("unexpected response type %q", resp.Type)
+	}
+	return resp
+}
+
+// do sends the request and returns the response, the error of the response fails the test unless failure is expected
+func (c *sftpTestClient) do(req *SFTPRequest, failure bool) *SFTPResponse {
+	c.t.Helper()
+	c.send(req)
+	resp := c.receive()
+	if resp.ID != req.ID || resp.Op != req.Op {
+		c.t.Fatalf("the response %s %s does not answer %s %s", resp.ID, resp.Op, req.ID, req.Op)
+	}
+	if failure != (len(resp.Error) > 0) {
+		c.t.Fatalf("%s %s: unexpected error %q", req.Op, req.Path, resp.Error)
+	}
+	return resp
+}
+
+func TestSFTPFileOperations(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("hello"), 0644)
+	c := newSFTPTestClient(t)
+
+	c.do(&SFTPRequest{ID: "1", Op: "mkdir", Path: filepath.Join(dir, "a/b"), Parents: true, Mode: "0700"}, false)
+	resp := c.do(&SFTPRequest{ID: "2", Op: "list", Path: dir}, false)
+	if len(resp.Files) != 2 || resp.Files[0].Name != "a" || !resp.Files[0].IsDir || resp.Files[1].Name != "b.txt" || resp.Files[1].Size != 5 {
+		t.Fatalf("the directories must be listed first: %+v", resp.Files)
+	}
+	resp = c.do(&SFTPRequest{ID: "3", Op: "stat", Path: filepath.Join(dir, "a/b")}, false)
+	if resp.File.Perm != "0700" || !resp.File.IsDir {
+		t.Fatalf("unexpected file %+v", resp.File)
+	}
+
+	c.do(&SFTPRequest{ID: "4", Op: "chmod", Path: filepath.Join(dir, "b.txt"), Mode: "0600"}, false)
+	c.do(&SFTPRequest{ID: "5", Op: "chmod", Path: filepath.Join(dir, "b.txt")}, true)
+	c.do(&SFTPRequest{ID: "6", Op: "chmod", Path: filepath.Join(dir, "b.txt"), Mode: "rw"}, true)
+	if fi, _ := os.Stat(filepath.Join(dir, "b.txt")); fi.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected mode %v", fi.Mode())
+	}
+
+	c.do(&SFTPRequest{ID: "7", Op: "rename", Path: filepath.Join(dir, "b.txt")}, true)
+	resp = c.do(&SFTPRequest{ID: "8", Op: "rename", Path: filepath.Join(dir, "b.txt"), Target: filepath.Join(dir, "a/c.txt")}, false)
+	if resp.Path != filepath.Join(dir, "a/c.txt") {
+		t.Fatalf("the new path is expected: %q", resp.Path)
+	}
+	c.do(&SFTPRequest{ID: "9", Op: "stat", Path: filepath.Join(dir, "b.txt")}, true)
+
+	c.do(&SFTPRequest{ID: "10", Op: "remove", Path: filepath.Join(dir, "a")}, true)
+	c.do(&SFTPRequest{ID: "11", Op: "remove", Path: filepath.Join(dir, "a"), Recursive: true}, false)
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Fatalf("the directory is not removed: %v", err)
+	}
+	c.do(&SFTPRequest{ID: "12", Op: "format"}, true)
+}
+
+func TestSFTPDownload(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), sftpMaxChunkSize/10+1)
+	os.WriteFile(filepath.Join(dir, "f"), content, 0644)
+	c := newSFTPTestClient(t)
+
+	download := func(req *SFTPRequest, chunkSize int) {
+		t.Helper()
+		c.send(req)
+		var got []byte
+		for {
+			resp := c.receive()
+			if len(resp.Error) > 0 || resp.ID != req.ID || resp.Size != int64(len(content)) {
+				t.Fatalf("unexpected chunk %+v", resp)
+			}
+			if resp.Offset != req.Offset+int64(len(got)) {
+				t.Fatalf("offset %d, want %d", resp.Offset, req.Offset+int64(len(got)))
+			}
+			if len(resp.Data) > chunkSize {
+				t.Fatalf("chunk of %d bytes, the limit is %d", len(resp.Data), chunkSize)
+			}
+			got = append(got, resp.Data...)
+			if resp.EOF {
+				break
+			}
+		}
+		if !bytes.Equal(got, content[req.Offset:]) {
+			t.Fatalf("downloaded %d bytes, want %d", len(got), len(content[req.Offset:]))
+		}
+	}
+	download(&SFTPRequest{ID: "1", Op: "download", Path: filepath.Join(dir, "f"), Offset: int64(len(content)) - 25, ChunkSize: 10}, 10)
+	// the chunk size is limited
+	download(&SFTPRequest{ID: "2", Op: "download", Path: filepath.Join(dir, "f"), ChunkSize: 4 * sftpMaxChunkSize}, sftpMaxChunkSize)
+
+	c.do(&SFTPRequest{ID: "3", Op: "download", Path: dir}, true)
+	c.do(&SFTPRequest{ID: "4", Op: "download", Path: filepath.Join(dir, "missing")}, true)
+}
+
+func TestSFTPUpload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "up")
+	c := newSFTPTestClient(t)
+
+	c.do(&SFTPRequest{ID: "u", Op: "upload", Path: file, Size: 10, Mode: "0640"}, false)
+	c.do(&SFTPRequest{ID: "u", Op: "upload", Path: file, Size: 10}, true)
+	resp := c.do(&SFTPRequest{ID: "u", Op: "write", Offset: 0, Data: []byte("hello")}, false)
+	if resp.Offset != 5 || resp.Size != 10 {
+		t.Fatalf("unexpected progress %+v", resp)
+	}
+	// the chunks out of the announced size are refused
+	c.do(&SFTPRequest{ID: "u", Op: "write", Offset: 8, Data: []byte("world")}, true)
+	c.do(&SFTPRequest{ID: "u", Op: "write", Offset: -1, Data: []byte("x")}, true)
+	c.do(&SFTPRequest{ID: "u", Op: "write", Offset: 5, Data: []byte("world")}, false)
+	c.do(&SFTPRequest{ID: "other", Op: "write", Data: []byte("x")}, true)
+	resp = c.do(&SFTPRequest{ID: "u", Op: "close"}, false)
+	if !resp.EOF || resp.Size != 10 || resp.File.Perm != "0640" {
+		t.Fatalf("unexpected result %+v %+v", resp, resp.File)
+	}
+	if b, _ := os.ReadFile(file); string(b) != "helloworld" {
+		t.Fatalf("uploaded %q", b)
+	}
+	c.do(&SFTPRequest{ID: "u", Op: "close"}, true)
+
+	// the upload left open is closed on disconnect
+	c.do(&SFTPRequest{ID: "left", Op: "upload", Path: filepath.Join(dir, "left"), Size: 3}, false)
+	c.do(&SFTPRequest{ID: "left", Op: "write", Data: []byte("abc")}, false)
+	c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	select {
+	case s := <-c.session:
+		if len(s.uploads) != 0 || len(s.downloads) != 0 {
+			t.Fatalf("the transfers are left: %v %v", s.uploads, s.downloads)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session does not end")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "left")); string(b) != "abc" {
+		t.Fatalf("uploaded %q", b)
+	}
+}
+
+func TestSFTPMalformedRequest(t *testing.T) {
+	dir := t.TempDir()
+	c := newSFTPTestClient(t)
+
+	// the id is answered when it can be decoded
+	c.ws.WriteMessage(websocket.TextMessage, []byte(`{"v":1,"type":"sftp","id":"bad","op":"list","offset":"x"}`))
+	if resp := c.receive(); resp.ID != "bad" || !strings.Contains(resp.Error, "malformed") {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	c.ws.WriteMessage(websocket.TextMessage, []byte(`{"v":1,"type":"sftp","id":`))
+	if resp := c.receive(); resp.ID != "" || !strings.Contains(resp.Error, "malformed") {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	// the session goes on
+	c.do(&SFTPRequest{ID: "1", Op: "list", Path: dir}, false)
+
+	// the frames over the read limit end the session
+	c.ws.WriteMessage(websocket.TextMessage, bytes.Repeat([]byte("x"), sftpReadLimit+1))
+	select {
+	case <-c.session:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session does not end")
+	}
+}
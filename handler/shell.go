@@ -12,9 +12,9 @@ import (
 	"time"
 
 	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
 	"github.com/admpub/web-terminal/library/utils"
 	"github.com/fd/go-shellwords/shellwords"
-	"golang.org/x/net/websocket"
 )
 
 func ExecShell(ctx *Context) error {
@@ -35,7 +35,7 @@ func ExecShell(ctx *Context) error {
 		args = append(args, arguments...)
 	}
 
-	return execShell(ctx.Conn, pa, args, charset, wd, stdin, timeout)
+	return execShell(ctx, pa, args, charset, wd, stdin, timeout)
 }
 
 func ExecShell2(ctx *Context) error {
@@ -53,11 +53,14 @@ func ExecShell2(ctx *Context) error {
 	pa = ss[0]
 	args := ss[1:]
 
-	return execShell(ctx.Conn, pa, args, charset, wd, stdin, timeout)
+	return execShell(ctx, pa, args, charset, wd, stdin, timeout)
 }
 
-func execShell(ws *websocket.Conn, pa string, args []string, charset, wd, stdin, timeoutStr string) error {
-	//ctx := NewContext(ws)
+func execShell(ctx *Context, pa string, args []string, charset, wd, stdin, timeoutStr string) error {
+	ws := ctx.Conn
+	if err := ctx.Authorize("", 0, pa); err != nil {
+		return err
+	}
 	charset = fixCharset(charset)
 
 	timeout := 10 * time.Minute
@@ -111,8 +114,23 @@ func execShell(ws *websocket.Conn, pa string, args []string, charset, wd, stdin,
 		}
 	}
 
+	recorder, err := newRecorder(ctx, &asciicast.Session{
+		Protocol: "cmd",
+		Charset:  charset,
+	}, strings.Join(append([]string{pa}, args...), " "), toInt(ParamGet(ctx, "columns"), 80), toInt(ParamGet(ctx, "rows"), 40))
+	if err != nil {
+		return err
+	}
+	if recorder != nil {
+		defer recorder.Close()
+	}
+	var input io.Reader = ws
+	if in := recordInput(recorder); in != nil {
+		input = warp(ws, in)
+	}
+
 	isConnectionAbandoned := false
-	output := decodeBy(charset, ws)
+	output := decodeBy(charset, recordOutput(recorder, ws))
 	if pp := strings.ToLower(pa); strings.HasSuffix(pp, "plink.exe") || strings.HasSuffix(pp, "plink") {
 		output = matchBy(output, "Connection abandoned.", func() {
 			isConnectionAbandoned = true
@@ -124,7 +142,7 @@ func execShell(ws *websocket.Conn, pa string, args []string, charset, wd, stdin,
 		cmd.Dir = wd
 	}
 	if stdin == "on" {
-		cmd.Stdin = ws
+		cmd.Stdin = input
 	}
 	cmd.Stderr = output
 	cmd.Stdout = output
@@ -137,14 +155,14 @@ func execShell(ws *websocket.Conn, pa string, args []string, charset, wd, stdin,
 			return err
 		}
 
-		newArgs := append(make([]string, len(args)+1))
+		newArgs := make([]string, len(args)+1)
 		newArgs[0] = pa
 		copy(newArgs[1:], args)
 		cmd = exec.Command(config.Default.SHExecute, newArgs...)
 		if len(wd) > 0 {
 			cmd.Dir = wd
 		}
-		cmd.Stdin = ws
+		cmd.Stdin = input
 		cmd.Stderr = output
 		cmd.Stdout = output
 
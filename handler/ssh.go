@@ -11,21 +11,45 @@ import (
 	"time"
 
 	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
 	sshx "github.com/admpub/web-terminal/library/ssh"
-	"golang.org/x/net/websocket"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
 )
 
+// AlgorithmsMessage reports the algorithms negotiated with the end host to the browser
+type AlgorithmsMessage struct {
+	Type string `json:"type"` // algorithms
+	Host string `json:"host"`
+	*config.NegotiatedAlgorithms
+}
+
+// ConnectionLostMessage tells the browser that the host stopped answering the keepalive requests
+type ConnectionLostMessage struct {
+	Type   string `json:"type"` // connection_lost
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+}
+
+// checkConnectionLost sends ConnectionLostMessage instead of the error if the connection is lost
+func (ctx *Context) checkConnectionLost(sshClient *sshx.SSH, err error) error {
+	lost := sshClient.ConnectionLost()
+	if lost == nil {
+		return err
+	}
+	log.Println(lost)
+	ctx.WriteJSON(&ConnectionLostMessage{Type: "connection_lost", Host: ctx.Config.End.Host, Reason: lost.Error()})
+	return nil
+}
+
+// SSHShell 启动 ssh 终端，"resume" 参数为恢复令牌时重新连接断线前的会话，"share" 参数为共享链接时加入他人的会话
 func SSHShell(ctx *Context) error {
-	var dumpOut, dumpIn io.WriteCloser
-	defer func() {
-		ctx.Close()
-		if nil != dumpOut {
-			dumpOut.Close()
-		}
-		if nil != dumpIn {
-			dumpIn.Close()
-		}
-	}()
+	defer ctx.Close()
+	if token := ParamGet(ctx, "resume"); len(token) > 0 {
+		return ctx.resumeShell(token)
+	}
+	if token := ParamGet(ctx, "share"); len(token) > 0 {
+		return ctx.joinShell(token)
+	}
 	columns := toInt(ParamGet(ctx, "columns"), 120)
 	rows := toInt(ParamGet(ctx, "rows"), 80)
 	debug := config.Default.Debug
@@ -45,35 +69,98 @@ func SSHShell(ctx *Context) error {
 	if err != nil {
 		return err
 	}
+	sess, err := Sessions.New(principalName(ctx.Principal))
+	if err != nil {
+		sshClient.Close()
+		return err
+	}
+	state := &shellSession{client: sshClient, config: ctx.Config}
+	sess.Value = state
+	sess.OnClose(func() {
+		sshClient.Close()
+	})
 	session := sshClient.Session
-	defer sshClient.Close()
+	if negotiated := sshClient.Negotiated; negotiated != nil {
+		ctx.WriteJSON(&AlgorithmsMessage{Type: "algorithms", Host: ctx.Config.End.Host, NegotiatedAlgorithms: negotiated})
+	}
+	var recorder *asciicast.Recorder
 	onInit := func() error {
-		ws := ctx.Conn
 		hostConfig := ctx.Config.End
-		combinedOut := decodeBy(hostConfig.Account.Charset, ws)
+		var err error
+		recorder, err = newRecorder(ctx, &asciicast.Session{
+			Protocol: "ssh",
+			Host:     hostConfig.Host,
+			Port:     hostConfig.Port,
+			User:     hostConfig.Account.User,
+			Charset:  hostConfig.Account.Charset,
+		}, hostConfig.Account.User+"@"+hostConfig.Host, columns, rows)
+		if err != nil {
+			return err
+		}
+		if nil != recorder {
+			sess.OnClose(func() {
+				recorder.Close()
+			})
+		}
+		// the output goes to the session, which keeps it for the resumed websocket
+		out := recordOutput(recorder, sess)
+		combinedOut := decodeBy(hostConfig.Account.Charset, out)
+		var dumpIn io.Writer
 		if debug {
-			dumpOut, err = os.OpenFile(config.Default.LogDir+hostConfig.Host+".dump_ssh_out.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+			dumpOut, err := os.OpenFile(config.Default.LogDir+hostConfig.Host+".dump_ssh_out.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 			if nil == err {
-				combinedOut = io.MultiWriter(dumpOut, decodeBy(hostConfig.Account.Charset, ws))
+				sess.OnClose(func() {
+					dumpOut.Close()
+				})
+				combinedOut = io.MultiWriter(dumpOut, decodeBy(hostConfig.Account.Charset, out))
 			}
 
-			dumpIn, err = os.OpenFile(config.Default.LogDir+hostConfig.Host+".dump_ssh_in.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
-			if nil != err {
-				dumpIn = nil
+			dumpInFile, err := os.OpenFile(config.Default.LogDir+hostConfig.Host+".dump_ssh_in.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+			if nil == err {
+				sess.OnClose(func() {
+					dumpInFile.Close()
+				})
+				dumpIn = dumpInFile
 			}
 		}
 
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("unable to open stdin: %w", err)
+		}
 		session.Stdout = combinedOut
 		session.Stderr = combinedOut
-		session.Stdin = warp(ws, dumpIn)
+		sess.Input = multiWriter(stdin, dumpIn, recordInput(recorder))
 		return nil
 	}
-	err = sshClient.StartShellWithCallback(onInit, rows, columns)
-	return err
+	if err = sshClient.RequestShell(onInit, rows, columns); err != nil {
+		sess.Close()
+		return err
+	}
+	// the resize messages of the owner change the window of the shell and are recorded
+	state.resizer = newResizer(columns, rows, func(cols, rows int) error {
+		if err := session.WindowChange(rows, cols); err != nil {
+			return err
+		}
+		if recorder != nil {
+			return recorder.Resize(cols, rows)
+		}
+		return nil
+	})
+	sess.OnClose(state.resizer.Stop)
+	go func() {
+		err := session.Wait()
+		if err != nil {
+			err = fmt.Errorf("unable to execute command: %w", err)
+		}
+		sess.CloseWithError(err)
+	}()
+	return ctx.attachShell(sess, 0, "session")
 }
 
 func SSHExec(ctx *Context) error {
 	var dumpOut, dumpIn io.WriteCloser
+	var recorder *asciicast.Recorder
 	defer func() {
 		ctx.Close()
 		if nil != dumpOut {
@@ -82,6 +169,9 @@ func SSHExec(ctx *Context) error {
 		if nil != dumpIn {
 			dumpIn.Close()
 		}
+		if nil != recorder {
+			recorder.Close()
+		}
 	}()
 	debug := config.Default.Debug
 	if "true" == strings.ToLower(ParamGet(ctx, "debug")) {
@@ -108,12 +198,23 @@ func SSHExec(ctx *Context) error {
 	session := sshClient.Session
 	defer sshClient.Close()
 	hostConfig := ctx.Config.End
-	combinedOut := decodeBy(hostConfig.Account.Charset, ws)
+	recorder, err = newRecorder(ctx, &asciicast.Session{
+		Protocol: "ssh",
+		Host:     hostConfig.Host,
+		Port:     hostConfig.Port,
+		User:     hostConfig.Account.User,
+		Charset:  hostConfig.Account.Charset,
+	}, hostConfig.Account.User+"@"+hostConfig.Host+": "+cmd, toInt(ParamGet(ctx, "columns"), 120), toInt(ParamGet(ctx, "rows"), 80))
+	if err != nil {
+		return err
+	}
+	out := recordOutput(recorder, ws)
+	combinedOut := decodeBy(hostConfig.Account.Charset, out)
 	if debug {
 		dumpOut, err = os.OpenFile(config.Default.LogDir+hostConfig.Host+"_"+cmdAlias+".dump_ssh_out.txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 		if nil == err {
 			fmt.Println("log to file", config.Default.LogDir+hostConfig.Host+"_"+cmdAlias+".dump_ssh_out.txt")
-			combinedOut = io.MultiWriter(dumpOut, decodeBy(hostConfig.Account.Charset, ws))
+			combinedOut = io.MultiWriter(dumpOut, decodeBy(hostConfig.Account.Charset, out))
 		} else {
 			fmt.Println("failed to open log file,", err)
 		}
@@ -129,19 +230,19 @@ func SSHExec(ctx *Context) error {
 
 	session.Stdout = combinedOut
 	session.Stderr = combinedOut
-	session.Stdin = warp(ws, dumpIn)
+	session.Stdin = warp(ws, multiWriter(dumpIn, recordInput(recorder)))
 
 	if err := session.Start(cmd); nil != err {
 		return fmt.Errorf("Unable to execute command: %w", err)
 	}
 	if err := session.Wait(); nil != err {
-		return fmt.Errorf("Unable to execute command: %w", err)
+		return ctx.checkConnectionLost(sshClient, fmt.Errorf("Unable to execute command: %w", err))
 	}
 	fmt.Println("exec ok")
 	return nil
 }
 
-func linuxSSH(ws *websocket.Conn, args []string, charset, wd string, timeout time.Duration) {
+func linuxSSH(ws *websocketx.Conn, args []string, charset, wd string, timeout time.Duration) {
 	log.Println("begin to execute ssh:", args)
 
 	// [ssh -batch -pw 8498b2c7 root@192.168.1.18 -f /var/lib/tpt/etc/scripts/abc.sh]
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+var (
+	ErrSSHConfigDisabled = errors.New("the ssh config file is not set, see -ssh_config")
+	ErrHostAliasNotFound = errors.New("host alias is not found")
+
+	// SSHHosts is the OpenSSH config file of the host aliases, nil disables the aliases
+	SSHHosts *config.OpenSSHConfig
+)
+
+// aliasHostConfig resolves the Host alias of the ssh config file into the end host config, the jump hosts are added to ctx.Config
+func (ctx *Context) aliasHostConfig(alias string) (*config.HostConfig, error) {
+	if SSHHosts == nil {
+		return nil, ErrSSHConfigDisabled
+	}
+	// only the hosts named by a Host block may be used, not any host with the "Host *" options
+	if _, found := SSHHosts.Lookup(alias); !found {
+		return nil, ErrHostAliasNotFound
+	}
+	sshConfig, err := SSHHosts.SSHConfig(alias, ctx.Conn, ctx.Conn, func(host *config.OpenSSHHost, account *config.AccountConfig) error {
+		if err := ctx.Authorize(host.HostName, host.Port, ""); err != nil {
+			return err
+		}
+		if len(account.User) == 0 && ctx.Ticket != nil {
+			account.User = ctx.Ticket.User
+		}
+		if charset := ParamGet(ctx, "charset"); len(charset) > 0 {
+			account.Charset = charset
+		}
+		account.Prompter = ctx.Prompter(host.HostName)
+		return ctx.issueCertificate(account, host.HostName, host.Port)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx.Config.AddJump(sshConfig.Jumps...)
+	return sshConfig.End, nil
+}
@@ -3,20 +3,67 @@ package handler
 import (
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/asciicast"
 	"github.com/admpub/web-terminal/library/telnet"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
 )
 
+// TerminalTypeMessage reports the terminal type sent to the telnet server, the last one is the negotiated type
+type TerminalTypeMessage struct {
+	Type string `json:"type"` // terminal_type
+	Name string `json:"name"`
+}
+
+// terminalTypes returns the terminal types of the ticket, the "ttype" parameter or -telnet_ttype
+func (ctx *Context) terminalTypes() []string {
+	if t := ctx.Ticket; t != nil && len(t.TerminalTypes) > 0 {
+		return config.SplitList(t.TerminalTypes)
+	}
+	if v := ParamGet(ctx, "ttype"); len(v) > 0 {
+		return config.SplitList(v)
+	}
+	return config.SplitList(config.Default.TelnetTerminalTypes)
+}
+
+// telnetEnviron returns the NEW-ENVIRON variables: -telnet_env, the env and the user of the ticket.
+// The ticket can not add other variables than DISPLAY, e.g. LD_PRELOAD which some servers pass to login.
+func (ctx *Context) telnetEnviron() map[string]string {
+	env := map[string]string{}
+	for _, v := range config.SplitList(config.Default.TelnetEnviron) {
+		if name, value, ok := strings.Cut(v, "="); ok && len(name) > 0 {
+			env[strings.TrimSpace(name)] = value
+		}
+	}
+	if t := ctx.Ticket; t != nil {
+		for name, value := range t.Env {
+			if _, ok := env[name]; ok || name == "DISPLAY" {
+				env[name] = value
+			}
+		}
+		if len(t.User) > 0 {
+			env["USER"] = t.User
+		}
+	}
+	return env
+}
+
 func TelnetShell(ctx *Context) error {
 	defer ctx.Close()
-	hostname := ParamGet(ctx, "hostname")
-	port := ParamGet(ctx, "port")
-	if 0 == len(port) {
-		port = "23"
+	// the target is only read from the ticket, which is bound to the principal and the protocol
+	if ctx.Ticket == nil {
+		return ErrTicketRequired
+	}
+	hostname := ctx.Ticket.Hostname
+	portN := ctx.Ticket.Port
+	if portN <= 0 {
+		portN = 23
 	}
 	charset := fixCharset(ParamGet(ctx, "charset"))
 	//columns := toInt(ParamGet(ctx,"columns"), 80)
@@ -24,8 +71,12 @@ func TelnetShell(ctx *Context) error {
 
 	var dumpOut io.WriteCloser
 	var dumpIn io.WriteCloser
+	var recorder *asciicast.Recorder
 	ws := ctx.Conn
-	client, err := net.Dial("tcp", hostname+":"+port)
+	if err := ctx.Authorize(hostname, portN, ""); err != nil {
+		return err
+	}
+	client, err := net.Dial("tcp", net.JoinHostPort(hostname, strconv.Itoa(portN)))
 	if nil != err {
 		return fmt.Errorf("Failed to dial: %w", err)
 	}
@@ -37,6 +88,9 @@ func TelnetShell(ctx *Context) error {
 		if nil != dumpIn {
 			dumpIn.Close()
 		}
+		if nil != recorder {
+			recorder.Close()
+		}
 	}()
 
 	debug := config.Default.Debug
@@ -62,17 +116,66 @@ func TelnetShell(ctx *Context) error {
 	}
 	columns := toInt(ParamGet(ctx, "columns"), 80)
 	rows := toInt(ParamGet(ctx, "rows"), 40)
-	conn.SetWindowSize(byte(rows), byte(columns))
+	conn.SetWindowSize(windowSize(rows), windowSize(columns))
+	conn.SetTerminalTypes(ctx.terminalTypes()...)
+	conn.SetEnviron(ctx.telnetEnviron())
+	conn.SetLineMode(config.Default.TelnetLineMode)
+	// {"type":"signal","signal":"BRK|INT|AO"} is sent as BRK, IP or AO
+	ws.OnMessage = func(msg *websocketx.Message) {
+		if msg.Type != websocketx.MessageTypeSignal {
+			return
+		}
+		if err := conn.Signal(msg.Signal); err != nil {
+			ws.Send(&websocketx.Message{Type: websocketx.MessageTypeStderr, Data: []byte(err.Error() + "\r\n")})
+		}
+	}
+	conn.OnTerminalType(func(name string) {
+		ws.WriteJSON(&TerminalTypeMessage{Type: "terminal_type", Name: name})
+	})
+
+	recorder, err = newRecorder(ctx, &asciicast.Session{
+		Protocol: "telnet",
+		Host:     hostname,
+		Port:     portN,
+		Charset:  charset,
+	}, net.JoinHostPort(hostname, strconv.Itoa(portN)), columns, rows)
+	if err != nil {
+		return err
+	}
+
+	// the resize messages are sent to the server by NAWS and recorded
+	resizer := newResizer(columns, rows, func(cols, rows int) error {
+		if err := conn.SetWindowSize(windowSize(rows), windowSize(cols)); err != nil {
+			return err
+		}
+		if recorder != nil {
+			return recorder.Resize(cols, rows)
+		}
+		return nil
+	})
+	defer resizer.Stop()
+	ws.OnResize = resizer.Resize
 
 	go func() {
-		_, err := io.Copy(decodeBy(charset, client), warp(ws, dumpOut))
+		_, err := io.Copy(decodeBy(charset, client), warp(ws, multiWriter(dumpOut, recordInput(recorder))))
 		if nil != err {
 			logString(nil, "copy of stdin failed:"+err.Error())
 		}
 	}()
 
-	if _, err := io.Copy(decodeBy(charset, ws), conn); err != nil {
+	if _, err := io.Copy(decodeBy(charset, recordOutput(recorder, ws)), conn); err != nil {
 		return fmt.Errorf("copy of stdout failed: %w", err)
 	}
 	return err
 }
+
+// windowSize limits the size to the 16 bits of NAWS
+func windowSize(n int) uint16 {
+	if n > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	if n < 0 {
+		return 0
+	}
+	return uint16(n)
+}
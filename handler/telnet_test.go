@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/admpub/websocket"
+)
+
+func TestTelnetEnviron(t *testing.T) {
+	defer func(env string) { config.Default.TelnetEnviron = env }(config.Default.TelnetEnviron)
+	config.Default.TelnetEnviron = "LANG=C, ORGANIZATION=ops"
+
+	ctx := &Context{Ticket: &Ticket{User: "admin", Env: map[string]string{"LANG": "zh_CN.UTF-8", "DISPLAY": ":0", "LD_PRELOAD": "/tmp/x.so"}}}
+	env := ctx.telnetEnviron()
+	want := map[string]string{"LANG": "zh_CN.UTF-8", "ORGANIZATION": "ops", "DISPLAY": ":0", "USER": "admin"}
+	if len(env) != len(want) {
+		t.Fatalf("env %v, want %v", env, want)
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Fatalf("env %v, want %v", env, want)
+		}
+	}
+}
+
+func TestTelnetShellRequiresTicket(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	dialed := make(chan struct{}, 1)
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			dialed <- struct{}{}
+			c.Close()
+		}
+	}()
+	server := httptest.NewServer(BuidHandler(TelnetShell, Protocol("telnet"), ResolveTicket))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	dialer := websocket.Dialer{Subprotocols: []string{websocketx.Subprotocol}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?hostname="+host+"&port="+port, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// the handler ends without dialing
+	for err == nil {
+		_, _, err = ws.ReadMessage()
+	}
+	select {
+	case <-dialed:
+		t.Fatal("the target of the query is dialed without a ticket")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
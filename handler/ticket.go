@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/ticket"
+)
+
+var (
+	ErrTicketRequired = errors.New("the connection ticket is required, POST the connection parameters to the ticket endpoint first")
+	ErrInvalidTicket  = errors.New("the connection ticket is invalid or expired")
+
+	// Tickets 一次性连接票据
+	Tickets = ticket.NewStore(ticket.DefaultTTL)
+)
+
+// maxTicketBody limits the request body of the ticket endpoint, private keys are small
+const maxTicketBody = 64 * 1024
+
+// Ticket holds the connection parameters, so that the credentials never appear in the websocket URL
+type Ticket struct {
+	Protocol   string `json:"protocol,omitempty"` // if set, the ticket can only be used by this protocol
+	Hostname   string `json:"hostname"`
+	Port       int    `json:"port,omitempty"`
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	// Certificate of the private key in authorized_keys format
+	Certificate string `json:"certificate,omitempty"`
+	Charset     string `json:"charset,omitempty"`
+	// Algorithms is the algorithm preset: modern, compatible or legacy
+	Algorithms string `json:"algorithms,omitempty"`
+	// TerminalTypes are the comma separated terminal types of telnet, see -telnet_ttype
+	TerminalTypes string `json:"terminalTypes,omitempty"`
+	// Env are the NEW-ENVIRON variables of telnet, only DISPLAY and the variables of -telnet_env may be set
+	Env map[string]string `json:"env,omitempty"`
+
+	principal string // the principal who requested the ticket
+}
+
+// TicketResponse is the response of the ticket endpoint
+type TicketResponse struct {
+	Ticket  string    `json:"ticket"`
+	Expires time.Time `json:"expires"`
+}
+
+// IssueTicket takes the connection parameters in a POST body (JSON or form) and returns a single-use ticket
+func IssueTicket(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	writeError := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketBody)
+	t := &Ticket{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(t); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+		t.Protocol = r.PostForm.Get("protocol")
+		t.Hostname = r.PostForm.Get("hostname")
+		t.Port, _ = strconv.Atoi(r.PostForm.Get("port"))
+		t.User = r.PostForm.Get("user")
+		t.Password = r.PostForm.Get("password")
+		t.PrivateKey = r.PostForm.Get("privateKey")
+		t.Passphrase = r.PostForm.Get("passphrase")
+		t.Certificate = r.PostForm.Get("certificate")
+		t.Charset = r.PostForm.Get("charset")
+		t.Algorithms = r.PostForm.Get("algorithms")
+		t.TerminalTypes = r.PostForm.Get("terminalTypes")
+	}
+	if len(t.Hostname) == 0 {
+		writeError(http.StatusBadRequest, errors.New("hostname is empty"))
+		return
+	}
+	if len(t.Algorithms) > 0 {
+		if _, err := config.AlgorithmPreset(t.Algorithms); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+	}
+	t.principal = principalName(GetPrincipal(r))
+	id, expires, err := Tickets.Issue(t)
+	if err != nil {
+		writeError(http.StatusInternalServerError, err)
+		return
+	}
+	json.NewEncoder(w).Encode(&TicketResponse{Ticket: id, Expires: expires})
+}
+
+// ResolveTicket 中间件：兑换 "ticket" 参数，必须在 Authenticate 和 Protocol 之后执行
+func ResolveTicket(ctx *Context) error {
+	id := ParamGet(ctx, "ticket")
+	if len(id) == 0 {
+		return nil
+	}
+	v, ok := Tickets.Redeem(id)
+	if !ok {
+		return ErrInvalidTicket
+	}
+	t := v.(*Ticket)
+	if t.principal != principalName(ctx.Principal) {
+		return ErrInvalidTicket
+	}
+	if len(t.Protocol) > 0 && len(ctx.Protocol) > 0 && t.Protocol != ctx.Protocol {
+		return ErrInvalidTicket
+	}
+	ctx.Ticket = t
+	return nil
+}
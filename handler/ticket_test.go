@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/library/ticket"
+)
+
+func TestIssueTicket(t *testing.T) {
+	old := Tickets
+	Tickets = ticket.NewStore(time.Minute)
+	defer func() { Tickets = old }()
+
+	body := `{"protocol":"ssh","hostname":"10.0.0.1","port":2222,"user":"root","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/ticket", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	IssueTicket(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	resp := TicketResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Ticket) == 0 {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Fatal("the response must not contain the credentials")
+	}
+
+	v, ok := Tickets.Redeem(resp.Ticket)
+	if !ok {
+		t.Fatal("the ticket must be redeemable")
+	}
+	if tk := v.(*Ticket); tk.Hostname != "10.0.0.1" || tk.Port != 2222 || tk.Password != "secret" {
+		t.Fatalf("unexpected ticket %+v", tk)
+	}
+	if _, ok = Tickets.Redeem(resp.Ticket); ok {
+		t.Fatal("the ticket must be single-use")
+	}
+
+	w = httptest.NewRecorder()
+	IssueTicket(w, httptest.NewRequest(http.MethodGet, "/ticket?hostname=10.0.0.1&password=secret", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET must be refused, got %d", w.Code)
+	}
+}
+
+func TestTicketExpired(t *testing.T) {
+	store := ticket.NewStore(time.Millisecond)
+	id, _, err := store.Issue(&Ticket{Hostname: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := store.Redeem(id); ok {
+		t.Fatal("the expired ticket must be refused")
+	}
+}
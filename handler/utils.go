@@ -7,9 +7,9 @@ import (
 	"strings"
 
 	"github.com/admpub/web-terminal/library/utils"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
 
 	"github.com/admpub/web-terminal/config"
-	"golang.org/x/net/websocket"
 )
 
 var (
@@ -116,35 +116,59 @@ func fillCommands(executableFolder string) {
 	}
 }
 
-func Register(appRoot string, routeRegister func(string, http.Handler)) {
+// Register mounts the endpoints, the origin check, the authentication, the Protocol and the ResolveTicket middlewares run before the middlewares.
+// The websockets are pinged, see websocketx.Conn
+func Register(appRoot string, routeRegister func(string, http.Handler), middlewares ...func(*Context) error) {
 	if len(appRoot) == 0 {
 		appRoot = `/`
 	} else if !strings.HasSuffix(appRoot, `/`) {
 		appRoot += `/`
 	}
-	routeRegister(appRoot+"replay", BuidHandler(Replay))
-	routeRegister(appRoot+"ssh", BuidHandler(SSHShell))
-	routeRegister(appRoot+"telnet", BuidHandler(TelnetShell))
-	routeRegister(appRoot+"cmd", BuidHandler(ExecShell))
-	routeRegister(appRoot+"cmd2", BuidHandler(ExecShell2))
-	routeRegister(appRoot+"ssh_exec", BuidHandler(SSHExec))
+	route := func(name string, protocol string, handler func(*Context) error) {
+		routeRegister(appRoot+name, BuidHandler(handler, append([]func(*Context) error{CheckOrigin, Authenticate, Protocol(protocol), ResolveTicket}, middlewares...)...))
+	}
+	route("replay", "replay", Replay)
+	routeRegister(appRoot+"recordings", AuthHandler(http.HandlerFunc(Recordings)))
+	routeRegister(appRoot+"ticket", AuthHandler(http.HandlerFunc(IssueTicket)))
+	routeRegister(appRoot+"profiles", AuthHandler(http.HandlerFunc(Profiles)))
+	route("local", "local", LocalShell)
+	route("ssh", "ssh", SSHShell)
+	route("telnet", "telnet", TelnetShell)
+	route("cmd", "cmd", ExecShell)
+	route("cmd2", "cmd", ExecShell2)
+	route("ssh_exec", "ssh_exec", SSHExec)
+	route("sftp", "sftp", SFTP)
+	route("forward", "forward", Forward)
 }
 
-func BuidHandler(handler func(*Context) error, middlewares ...func(*Context) error) websocket.Handler {
-	return websocket.Handler(func(ws *websocket.Conn) {
+// BuidHandler upgrades the request to the websocket, see websocketx.Conn for the messages
+func BuidHandler(handler func(*Context) error, middlewares ...func(*Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			logString(nil, err.Error())
+			return
+		}
+		defer ws.Close()
 		ctx := NewContext(ws)
-		var err error
 		for _, f := range middlewares {
 			if err = f(ctx); err != nil {
-				logString(ctx, err.Error())
-				ws.Write([]byte(err.Error()))
+				ctx.sendError(err)
 				return
 			}
 		}
-		err = handler(ctx)
-		if err != nil {
-			logString(ctx, err.Error())
-			ws.Write([]byte(err.Error()))
+		if err = handler(ctx); err != nil {
+			ctx.sendError(err)
 		}
 	})
 }
+
+// sendError sends the error as the stderr message, the legacy clients get the text marked by "%tpt%" too
+func (ctx *Context) sendError(err error) {
+	if ctx.Legacy() {
+		logString(ctx, err.Error())
+	} else {
+		logString(nil, err.Error())
+	}
+	ctx.Send(&websocketx.Message{Type: websocketx.MessageTypeStderr, Data: []byte(err.Error())})
+}
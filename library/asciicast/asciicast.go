@@ -0,0 +1,97 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version of the asciicast file format
+// https://docs.asciinema.org/manual/asciicast/v2/
+const Version = 2
+
+type EventType string
+
+const (
+	EventOutput EventType = "o"
+	EventInput  EventType = "i"
+	EventResize EventType = "r"
+	EventMarker EventType = "m"
+)
+
+// Header is the first line of the asciicast v2 file
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	// Session is not defined by asciicast, players ignore it
+	Session *Session `json:"web_terminal,omitempty"`
+}
+
+// Session describes the recorded web-terminal session
+type Session struct {
+	Protocol string `json:"protocol,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	User     string `json:"user,omitempty"`
+	Charset  string `json:"charset,omitempty"`
+	Owner    string `json:"owner,omitempty"` // the principal who opened the session, empty without authentication
+}
+
+// Event is a line after the header: [time, code, data]
+type Event struct {
+	Time float64
+	Type EventType
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("invalid asciicast event: %s", b)
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return err
+	}
+	var typ string
+	if err := json.Unmarshal(raw[1], &typ); err != nil {
+		return err
+	}
+	e.Type = EventType(typ)
+	return json.Unmarshal(raw[2], &e.Data)
+}
+
+// Size parses the data of the resize event ("COLSxROWS")
+func (e Event) Size() (cols int, rows int, err error) {
+	if e.Type != EventResize {
+		return 0, 0, errors.New("not a resize event")
+	}
+	parts := strings.SplitN(e.Data, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid resize event: %q", e.Data)
+	}
+	if cols, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	rows, err = strconv.Atoi(parts[1])
+	return
+}
+
+func ResizeData(cols, rows int) string {
+	return strconv.Itoa(cols) + "x" + strconv.Itoa(rows)
+}
@@ -0,0 +1,330 @@
+package asciicast
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 16
+
+	// resetTerminal (RIS) clears the screen before rendering the frames up to the seek position
+	resetTerminal = "\x1bc"
+)
+
+// NewPlayer plays the output events to w in real time
+func NewPlayer(header *Header, events []Event, w io.Writer) *Player {
+	p := &Player{
+		header: header,
+		out:    w,
+		speed:  1,
+		seekTo: -1,
+		wake:   make(chan struct{}, 1),
+	}
+	for _, e := range events {
+		if e.Type == EventOutput || e.Type == EventResize {
+			p.events = append(p.events, e)
+		}
+	}
+	if header != nil && header.IdleTimeLimit > 0 {
+		p.idleLimit = header.IdleTimeLimit
+	}
+	p.buildTimeline()
+	p.buildIndex()
+	return p
+}
+
+// Player 按时间轴回放录像，支持暂停、变速、跳转和搜索
+type Player struct {
+	header    *Header
+	events    []Event
+	times     []float64 // the time of every event after capping idle gaps
+	out       io.Writer
+	onResize  func(cols, rows int)
+	idleLimit float64
+
+	mu       sync.Mutex
+	speed    float64
+	paused   bool
+	index    int
+	position float64
+	seekTo   float64
+	wake     chan struct{}
+
+	text    string // all the output, for searching, it is built by NewPlayer and never changed
+	offsets []int  // the offset of every event in text
+}
+
+func (p *Player) buildIndex() {
+	var b strings.Builder
+	p.offsets = make([]int, len(p.events))
+	for i, e := range p.events {
+		p.offsets[i] = b.Len()
+		if e.Type == EventOutput {
+			b.WriteString(e.Data)
+		}
+	}
+	p.text = b.String()
+}
+
+func (p *Player) buildTimeline() {
+	p.times = make([]float64, len(p.events))
+	var last, position float64
+	for i, e := range p.events {
+		gap := e.Time - last
+		if gap < 0 {
+			gap = 0
+		}
+		if p.idleLimit > 0 && gap > p.idleLimit {
+			gap = p.idleLimit
+		}
+		position += gap
+		p.times[i] = position
+		last = e.Time
+	}
+}
+
+func (p *Player) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Player) Header() *Header {
+	return p.header
+}
+
+// OnResize is called with the terminal size of the resize events
+func (p *Player) OnResize(fn func(cols, rows int)) *Player {
+	p.onResize = fn
+	return p
+}
+
+// SetIdleTimeLimit caps the idle gaps between events, it must be called before Play
+func (p *Player) SetIdleTimeLimit(limit time.Duration) *Player {
+	p.idleLimit = limit.Seconds()
+	p.buildTimeline()
+	return p
+}
+
+// SetSpeed changes the playback speed, it is limited to MinSpeed - MaxSpeed
+func (p *Player) SetSpeed(speed float64) {
+	if speed < MinSpeed {
+		speed = MinSpeed
+	} else if speed > MaxSpeed {
+		speed = MaxSpeed
+	}
+	p.mu.Lock()
+	p.speed = speed
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *Player) Speed() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed
+}
+
+func (p *Player) Pause() {
+	p.mu.Lock()
+	p.paused = true
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *Player) Resume() {
+	p.mu.Lock()
+	p.paused = false
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *Player) TogglePause() {
+	p.mu.Lock()
+	p.paused = !p.paused
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *Player) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Duration of the playback after capping idle gaps
+func (p *Player) Duration() time.Duration {
+	if len(p.times) == 0 {
+		return 0
+	}
+	return seconds(p.times[len(p.times)-1])
+}
+
+// Position of the playback
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seekTo >= 0 {
+		return seconds(p.seekTo)
+	}
+	return seconds(p.position)
+}
+
+// Seek jumps to the offset of the playback
+func (p *Player) Seek(offset time.Duration) {
+	position := offset.Seconds()
+	if position < 0 {
+		position = 0
+	}
+	p.mu.Lock()
+	p.seekTo = position
+	p.mu.Unlock()
+	p.notify()
+}
+
+// Search jumps to the first output event containing the text, it may be called while playing
+func (p *Player) Search(text string) (time.Duration, bool) {
+	if len(text) == 0 {
+		return 0, false
+	}
+	pos := strings.Index(p.text, text)
+	if pos < 0 {
+		return 0, false
+	}
+	// the last event starting at or before the match
+	index := sort.Search(len(p.offsets), func(i int) bool { return p.offsets[i] > pos }) - 1
+	if index < 0 {
+		index = 0
+	}
+	// stop right after the event of the last character of the match
+	end := sort.Search(len(p.offsets), func(i int) bool { return p.offsets[i] >= pos+len(text) }) - 1
+	if end < index {
+		end = index
+	}
+	offset := seconds(p.times[end])
+	p.Seek(offset)
+	return offset, true
+}
+
+func (p *Player) write(e Event) error {
+	switch e.Type {
+	case EventOutput:
+		_, err := io.WriteString(p.out, e.Data)
+		return err
+	case EventResize:
+		if p.onResize != nil {
+			if cols, rows, err := e.Size(); err == nil {
+				p.onResize(cols, rows)
+			}
+		}
+	}
+	return nil
+}
+
+// render redraws the terminal with all the events up to the position
+func (p *Player) render(position float64) error {
+	index := sort.Search(len(p.times), func(i int) bool { return p.times[i] > position })
+	var b strings.Builder
+	b.WriteString(resetTerminal)
+	for _, e := range p.events[:index] {
+		if e.Type == EventOutput {
+			b.WriteString(e.Data)
+			continue
+		}
+		if b.Len() > 0 {
+			if _, err := io.WriteString(p.out, b.String()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+		if err := p.write(e); err != nil {
+			return err
+		}
+	}
+	if b.Len() > 0 {
+		if _, err := io.WriteString(p.out, b.String()); err != nil {
+			return err
+		}
+	}
+	p.index = index
+	p.position = position
+	return nil
+}
+
+// Play blocks until all the events are played or ctx is done
+func (p *Player) Play(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if p.seekTo >= 0 {
+			err := p.render(p.seekTo)
+			p.seekTo = -1
+			if err != nil {
+				p.mu.Unlock()
+				return err
+			}
+		}
+		if p.index >= len(p.events) {
+			p.mu.Unlock()
+			return nil
+		}
+		if p.paused {
+			p.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.wake:
+			}
+			continue
+		}
+		index := p.index
+		speed := p.speed
+		wait := seconds((p.times[index] - p.position) / speed)
+		p.mu.Unlock()
+
+		if wait > 0 {
+			start := time.Now()
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-p.wake:
+				timer.Stop()
+				p.mu.Lock()
+				if p.index == index {
+					p.position += time.Since(start).Seconds() * speed
+					if p.position > p.times[index] {
+						p.position = p.times[index]
+					}
+				}
+				p.mu.Unlock()
+				continue
+			case <-timer.C:
+			}
+		}
+
+		p.mu.Lock()
+		if p.index != index || p.seekTo >= 0 {
+			p.mu.Unlock()
+			continue
+		}
+		p.position = p.times[index]
+		p.index++
+		err := p.write(p.events[index])
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
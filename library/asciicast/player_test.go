@@ -0,0 +1,63 @@
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayerSearch(t *testing.T) {
+	events := []Event{
+		{Time: 0, Type: EventOutput, Data: "$ "},
+		{Time: 1, Type: EventInput, Data: "ls\r"},
+		{Time: 30, Type: EventOutput, Data: "foo"},
+		{Time: 60, Type: EventOutput, Data: "bar\r\n"},
+		{Time: 90, Type: EventOutput, Data: "baz"},
+	}
+	out := &bytes.Buffer{}
+	player := NewPlayer(&Header{Version: Version}, events, out)
+	player.SetIdleTimeLimit(time.Second)
+	if d := player.Duration(); d != 3*time.Second {
+		t.Fatalf(`idle gaps must be capped, got duration %v`, d)
+	}
+	offset, ok := player.Search("obar")
+	if !ok || offset != 2*time.Second {
+		t.Fatalf(`unexpected search result: %v %v`, offset, ok)
+	}
+	player.SetSpeed(100) // limited to MaxSpeed
+	if player.Speed() != MaxSpeed {
+		t.Fatalf(`speed must be limited to %v`, MaxSpeed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := player.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), resetTerminal+"$ foobar\r\n") || !strings.HasSuffix(out.String(), "baz") {
+		t.Fatalf(`unexpected output: %q`, out.String())
+	}
+}
+
+func TestPlayerSearchWhilePlaying(t *testing.T) {
+	events := []Event{
+		{Time: 0, Type: EventOutput, Data: "foo"},
+		{Time: 0.01, Type: EventOutput, Data: "bar"},
+	}
+	player := NewPlayer(&Header{Version: Version}, events, &bytes.Buffer{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- player.Play(ctx) }()
+	// the searches of the control messages run beside Play, see "go test -race"
+	for i := 0; i < 10; i++ {
+		go player.Search("bar")
+	}
+	if _, ok := player.Search("obar"); !ok {
+		t.Fatal("obar is not found")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNotAsciicast = errors.New("not an asciicast v2 file")
+
+// ReadHeader reads the first line of the recording
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return nil, ErrNotAsciicast
+	}
+	header := &Header{}
+	if err = json.Unmarshal(line, header); err != nil || header.Version != Version {
+		return nil, ErrNotAsciicast
+	}
+	return header, nil
+}
+
+// Decode reads the whole recording
+func Decode(r io.Reader) (*Header, []Event, error) {
+	reader := bufio.NewReader(r)
+	header, err := ReadHeader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	var events []Event
+	for lineNum := 2; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Event
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				// the last line may be incomplete if the recording was interrupted
+				if err == io.EOF {
+					break
+				}
+				return header, events, fmt.Errorf("line %d: %w", lineNum, jerr)
+			}
+			events = append(events, e)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return header, events, err
+		}
+	}
+	return header, events, nil
+}
+
+// tailSize is the size read from the end of the recording to find the last event
+const tailSize = 64 * 1024
+
+// Summary reads the header and the time of the last event, which is the duration of the recording
+func Summary(r io.ReadSeeker) (*Header, float64, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	header, err := ReadHeader(bufio.NewReader(r))
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.Duration > 0 {
+		return header, header.Duration, nil
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return header, 0, err
+	}
+	offset := size - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return header, 0, err
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return header, 0, err
+	}
+	lines := bytes.Split(bytes.TrimRight(tail, "\n"), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e Event
+		if json.Unmarshal(lines[i], &e) == nil {
+			return header, e.Time, nil
+		}
+	}
+	return header, 0, nil
+}
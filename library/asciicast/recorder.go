@@ -0,0 +1,156 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// NewRecorder writes the header and returns a recorder appending events to w.
+// Closing the recorder closes w.
+func NewRecorder(w io.WriteCloser, header *Header) (*Recorder, error) {
+	if header.Version == 0 {
+		header.Version = Version
+	}
+	start := time.Now()
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	r := &Recorder{
+		closer: w,
+		w:      bufio.NewWriter(w),
+		start:  start,
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.writeLine(b); err != nil {
+		return nil, err
+	}
+	return r, r.w.Flush()
+}
+
+// Recorder 以 asciicast v2 格式录制终端会话
+type Recorder struct {
+	mu     sync.Mutex
+	closer io.Closer
+	w      *bufio.Writer
+	start  time.Time
+	err    error
+	closed bool
+}
+
+func (r *Recorder) writeLine(b []byte) error {
+	if _, err := r.w.Write(b); err != nil {
+		return err
+	}
+	return r.w.WriteByte('\n')
+}
+
+// Elapsed returns the time since the recording started
+func (r *Recorder) Elapsed() time.Duration {
+	return time.Since(r.start)
+}
+
+// WriteEvent appends an event, the time is taken from the clock
+func (r *Recorder) WriteEvent(typ EventType, data string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+	if r.err != nil {
+		return r.err
+	}
+	event := Event{Time: float64(time.Since(r.start).Microseconds()) / 1e6, Type: typ, Data: data}
+	b, err := json.Marshal(event)
+	if err == nil {
+		err = r.writeLine(b)
+	}
+	if err == nil {
+		err = r.w.Flush()
+	}
+	r.err = err
+	return err
+}
+
+func (r *Recorder) Resize(cols, rows int) error {
+	return r.WriteEvent(EventResize, ResizeData(cols, rows))
+}
+
+func (r *Recorder) Marker(label string) error {
+	return r.WriteEvent(EventMarker, label)
+}
+
+// Output returns a writer recording output events
+func (r *Recorder) Output() io.Writer {
+	return &eventWriter{r: r, typ: EventOutput}
+}
+
+// Input returns a writer recording input events
+func (r *Recorder) Input() io.Writer {
+	return &eventWriter{r: r, typ: EventInput}
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.w.Flush()
+	if closeErr := r.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// eventWriter keeps the incomplete UTF-8 sequence at the end of a write
+// until the next write, so that a character is never split into two events.
+type eventWriter struct {
+	r       *Recorder
+	typ     EventType
+	pending []byte
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	data := p
+	if len(w.pending) > 0 {
+		data = append(w.pending, p...)
+		w.pending = nil
+	}
+	cut := incompleteSuffix(data)
+	if cut > 0 {
+		w.pending = append([]byte{}, data[len(data)-cut:]...)
+		data = data[:len(data)-cut]
+	}
+	if len(data) == 0 {
+		return len(p), nil
+	}
+	if err := w.r.WriteEvent(w.typ, string(data)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// incompleteSuffix returns the length of an incomplete UTF-8 sequence at the end of b
+func incompleteSuffix(b []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if c < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(b[len(b)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r, err := NewRecorder(nopCloser{buf}, &Header{Width: 80, Height: 24, Session: &Session{Protocol: `ssh`, Host: `example.com`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.Output()
+	word := []byte(`中文`)
+	out.Write(word[:2]) // split inside the first character
+	out.Write(word[2:])
+	r.Input().Write([]byte("ls\r"))
+	r.Resize(100, 30)
+	r.Close()
+
+	scanner := bufio.NewScanner(buf)
+	if !scanner.Scan() {
+		t.Fatal(`missing header`)
+	}
+	header := &Header{}
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != Version || header.Width != 80 || header.Session.Host != `example.com` {
+		t.Fatalf(`unexpected header: %s`, scanner.Bytes())
+	}
+	var events []Event
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 {
+		t.Fatalf(`expected 3 events, got %d`, len(events))
+	}
+	if events[0].Type != EventOutput || events[0].Data != `中文` {
+		t.Errorf(`unexpected output event: %+v`, events[0])
+	}
+	if events[1].Type != EventInput || events[1].Data != "ls\r" {
+		t.Errorf(`unexpected input event: %+v`, events[1])
+	}
+	if cols, rows, err := events[2].Size(); err != nil || cols != 100 || rows != 30 {
+		t.Errorf(`unexpected resize event: %+v`, events[2])
+	}
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event 审计事件，每个事件写为一行 JSON
+type Event struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"` // access
+	Principal string    `json:"principal,omitempty"`
+	Remote    string    `json:"remote,omitempty"`
+	Protocol  string    `json:"protocol,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	Port      int       `json:"port,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Allowed   bool      `json:"allowed"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func New(file string) *Logger {
+	return &Logger{file: file}
+}
+
+// Logger appends the events to the file, the file is opened on the first event
+type Logger struct {
+	file string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+func (l *Logger) File() string {
+	return l.file
+}
+
+func (l *Logger) Log(e *Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		if err = os.MkdirAll(filepath.Dir(l.file), 0700); err != nil {
+			return err
+		}
+		l.f, err = os.OpenFile(l.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = l.f.Write(b)
+	return err
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
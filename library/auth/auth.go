@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrInvalidCredential = errors.New("invalid credential")
+	ErrExpired           = errors.New("credential is expired")
+)
+
+const (
+	// TokenParam is the query parameter of the token, the browser can not set headers for websocket
+	TokenParam = "token"
+	// TokenCookie is the cookie name of the token
+	TokenCookie = "web_terminal_token"
+)
+
+// Principal is the authenticated web user
+type Principal struct {
+	Name      string    `json:"name"`
+	Roles     []string  `json:"roles,omitempty"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator 认证器。
+// Authenticate returns nil principal and nil error if the request carries no credential it understands,
+// so that the next authenticator of the Chain can try.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Chain tries the authenticators in order
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, ErrUnauthorized
+}
+
+// BearerToken returns the token from the "Authorization: Bearer" header, the "token" query parameter or the cookie
+func BearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	if token := r.URL.Query().Get(TokenParam); len(token) > 0 {
+		return token
+	}
+	if cookie, err := r.Cookie(TokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// SetTokenCookie stores the token of the "token" query parameter in TokenCookie after it is authenticated,
+// so that the page loaded by "?token=" can load its scripts and styles without the parameter.
+// The cookie expires with the principal if it has an expiry.
+func SetTokenCookie(w http.ResponseWriter, r *http.Request, principal *Principal, path string) {
+	token := r.URL.Query().Get(TokenParam)
+	if len(token) == 0 {
+		return
+	}
+	if cookie, err := r.Cookie(TokenCookie); err == nil && cookie.Value == token {
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     TokenCookie,
+		Value:    token,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if principal != nil && !principal.ExpiresAt.IsZero() {
+		cookie.Expires = principal.ExpiresAt
+	}
+	http.SetCookie(w, cookie)
+}
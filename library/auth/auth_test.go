@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestStaticTokens(t *testing.T) {
+	tokens, err := ParseStaticTokens(strings.NewReader("# comment\nsecret1 alice admin,ops\nsecret2 bob\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/ssh?token=secret1", nil)
+	p, err := tokens.Authenticate(req)
+	if err != nil || p == nil || p.Name != "alice" || !p.HasRole("ops") {
+		t.Fatalf("unexpected principal %+v, %v", p, err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/ssh", nil)
+	req.Header.Set("Authorization", "Bearer secret2")
+	if p, _ = tokens.Authenticate(req); p == nil || p.Name != "bob" {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/ssh?token=wrong", nil)
+	if _, err = (Chain{tokens}).Authenticate(req); err != ErrUnauthorized {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass1"), bcrypt.MinCost)
+	h, err := ParseHtpasswd(strings.NewReader("alice:" + string(hash) + ":admin, ops\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		user, password string
+		ok             bool
+	}{
+		{"alice", "pass1", true},
+		{"alice", "pass2", false},
+		{"bob", "password", true},
+		{"bob", "pass1", false},
+		{"carol", "pass1", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ssh", nil)
+		req.SetBasicAuth(c.user, c.password)
+		p, err := h.Authenticate(req)
+		if c.ok != (err == nil && p != nil && p.Name == c.user) {
+			t.Errorf("%s:%s expected %v, got %+v, %v", c.user, c.password, c.ok, p, err)
+		}
+	}
+
+	// the roles are the third field
+	req := httptest.NewRequest(http.MethodGet, "/ssh", nil)
+	req.SetBasicAuth("alice", "pass1")
+	if p, _ := h.Authenticate(req); p == nil || len(p.Roles) != 2 || !p.HasRole("admin") || !p.HasRole("ops") {
+		t.Fatalf("unexpected roles %+v", p)
+	}
+	req.SetBasicAuth("bob", "password")
+	if p, _ := h.Authenticate(req); p == nil || len(p.Roles) != 0 {
+		t.Fatalf("unexpected roles %+v", p)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	j := NewJWT([]byte("secret"))
+	token, err := j.Sign("alice", []string{"admin"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/ssh?token="+token, nil)
+	p, err := j.Authenticate(req)
+	if err != nil || p.Name != "alice" || !p.HasRole("admin") {
+		t.Fatalf("unexpected principal %+v, %v", p, err)
+	}
+
+	if _, err = NewJWT([]byte("other")).Parse(token); err != ErrInvalidCredential {
+		t.Fatalf("expected ErrInvalidCredential, got %v", err)
+	}
+	parts := strings.Split(token, ".")
+	if _, err = j.Parse(parts[0] + "." + parts[1] + "x." + parts[2]); err != ErrInvalidCredential {
+		t.Fatalf("expected ErrInvalidCredential for tampered payload, got %v", err)
+	}
+
+	expired, _ := j.SignClaims(&Claims{Subject: "alice", ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+	if _, err = j.Parse(expired); err != ErrExpired {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	noExpiry, _ := j.SignClaims(&Claims{Subject: "alice"})
+	if _, err = j.Parse(noExpiry); err != ErrExpired {
+		t.Fatalf("expected ErrExpired without exp, got %v", err)
+	}
+}
+
+func TestSetTokenCookie(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/static/terminal.html?token=secret1", nil)
+	SetTokenCookie(rec, req, &Principal{Name: "alice", ExpiresAt: expires}, "/")
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != TokenCookie || cookies[0].Value != "secret1" || !cookies[0].HttpOnly || !cookies[0].Expires.Equal(expires) {
+		t.Fatalf("unexpected cookies %+v", cookies)
+	}
+
+	// the scripts of the page are authenticated by the cookie
+	req = httptest.NewRequest(http.MethodGet, "/static/main.js", nil)
+	req.AddCookie(cookies[0])
+	if token := BearerToken(req); token != "secret1" {
+		t.Fatalf("unexpected token %q", token)
+	}
+	rec = httptest.NewRecorder()
+	SetTokenCookie(rec, req, &Principal{Name: "alice"}, "/")
+	if cookies = rec.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("the cookie is set without the token parameter: %+v", cookies)
+	}
+}
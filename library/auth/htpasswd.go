@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LoadHtpasswd loads the htpasswd file, bcrypt ($2y$), {SHA} and plain text passwords are supported.
+// Each line is "user:password" or "user:password:role1,role2", the plain text passwords can not contain ":".
+func LoadHtpasswd(file string) (*Htpasswd, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: map[string]string{}, Roles: map[string][]string{}}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 2 || len(fields[0]) == 0 {
+			return nil, fmt.Errorf("line %d: expected \"user:password[:roles]\"", lineNum)
+		}
+		user := fields[0]
+		h.users[user] = fields[1]
+		if len(fields) == 3 {
+			for _, role := range strings.Split(fields[2], ",") {
+				if role = strings.TrimSpace(role); len(role) > 0 {
+					h.Roles[user] = append(h.Roles[user], role)
+				}
+			}
+		}
+	}
+	return h, scanner.Err()
+}
+
+// Htpasswd authenticates by HTTP Basic
+type Htpasswd struct {
+	users map[string]string
+	Roles map[string][]string // user => roles, the third field of the lines
+}
+
+func (h *Htpasswd) Authenticate(r *http.Request) (*Principal, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	hash, found := h.users[user]
+	if !found || !checkPassword(hash, password) {
+		return nil, ErrInvalidCredential
+	}
+	return &Principal{Name: user, Roles: h.Roles[user], Method: "basic"}, nil
+}
+
+func checkPassword(hash string, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hash[5:]), []byte(base64.StdEncoding.EncodeToString(sum[:]))) == 1
+	case strings.HasPrefix(hash, "$"): // apr1, crypt(3) ... are not supported
+		return false
+	default:
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Claims of the JWT, "exp" is required
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Roles     []string `json:"roles,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+var jwtEncoding = base64.RawURLEncoding
+
+func NewJWT(secret []byte) *JWT {
+	return &JWT{secret: secret, Leeway: 30 * time.Second}
+}
+
+// JWT authenticates by HS256 signed JSON Web Tokens
+type JWT struct {
+	secret []byte
+	Leeway time.Duration // clock skew allowed
+}
+
+// Sign issues a token for the subject which expires after ttl
+func (j *JWT) Sign(subject string, roles []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return j.SignClaims(&Claims{
+		Subject:   subject,
+		Roles:     roles,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+}
+
+func (j *JWT) SignClaims(claims *Claims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := jwtEncoding.EncodeToString(header) + "." + jwtEncoding.EncodeToString(payload)
+	return signing + "." + jwtEncoding.EncodeToString(j.sign(signing)), nil
+}
+
+func (j *JWT) sign(signing string) []byte {
+	mac := hmac.New(sha256.New, j.secret)
+	mac.Write([]byte(signing))
+	return mac.Sum(nil)
+}
+
+// Parse verifies the signature and the expiry of the token
+func (j *JWT) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredential
+	}
+	b, err := jwtEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	header := jwtHeader{}
+	if err = json.Unmarshal(b, &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidCredential
+	}
+	signature, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, j.sign(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidCredential
+	}
+	b, err = jwtEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	claims := &Claims{}
+	if err = json.Unmarshal(b, claims); err != nil || len(claims.Subject) == 0 {
+		return nil, ErrInvalidCredential
+	}
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.Add(-j.Leeway).After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpired
+	}
+	if claims.NotBefore > 0 && now.Add(j.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("credential is not valid yet")
+	}
+	return claims, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	token := BearerToken(r)
+	if strings.Count(token, ".") != 2 {
+		return nil, nil
+	}
+	claims, err := j.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Name:      claims.Subject,
+		Roles:     claims.Roles,
+		Method:    "jwt",
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// LoadStaticTokens loads the token file, each line is "token name [role1,role2]", "#" starts a comment
+func LoadStaticTokens(file string) (*StaticTokens, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseStaticTokens(f)
+}
+
+func ParseStaticTokens(r io.Reader) (*StaticTokens, error) {
+	t := NewStaticTokens()
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected \"token name [roles]\"", lineNum)
+		}
+		var roles []string
+		if len(fields) > 2 {
+			roles = strings.Split(fields[2], ",")
+		}
+		t.Add(fields[0], &Principal{Name: fields[1], Roles: roles})
+	}
+	return t, scanner.Err()
+}
+
+func NewStaticTokens() *StaticTokens {
+	return &StaticTokens{}
+}
+
+type staticToken struct {
+	token     []byte
+	principal *Principal
+}
+
+// StaticTokens authenticates by pre-shared tokens
+type StaticTokens struct {
+	tokens []staticToken
+}
+
+func (t *StaticTokens) Add(token string, principal *Principal) *StaticTokens {
+	principal.Method = "token"
+	t.tokens = append(t.tokens, staticToken{token: []byte(token), principal: principal})
+	return t
+}
+
+func (t *StaticTokens) Authenticate(r *http.Request) (*Principal, error) {
+	token := BearerToken(r)
+	if len(token) == 0 {
+		return nil, nil
+	}
+	var found *Principal
+	for _, v := range t.tokens {
+		if subtle.ConstantTimeCompare(v.token, []byte(token)) == 1 {
+			found = v.principal
+		}
+	}
+	if found == nil {
+		return nil, nil // may be a JWT
+	}
+	principal := *found
+	return &principal, nil
+}
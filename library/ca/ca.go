@@ -0,0 +1,169 @@
+package ca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultValidity = 5 * time.Minute
+
+	// PrincipalPlaceholder is replaced by the name of the web user.
+	// There is no placeholder of the ssh login user: it is chosen by the browser, so it would let every web user log in as root.
+	PrincipalPlaceholder = "{principal}"
+
+	// clockSkew is subtracted from ValidAfter
+	clockSkew = time.Minute
+)
+
+var (
+	ErrNoPrincipal = errors.New("the certificate can only be issued to an authenticated user")
+	// ErrUserPlaceholder is returned for the principals with the removed {user} placeholder
+	ErrUserPlaceholder = errors.New("the {user} principal is not supported: the ssh user is chosen by the browser, list the login names instead")
+
+	// DefaultExtensions are the permissions of the certificates if Options.Extensions is nil
+	DefaultExtensions = map[string]string{
+		"permit-pty":              "",
+		"permit-port-forwarding":  "",
+		"permit-agent-forwarding": "",
+		"permit-user-rc":          "",
+	}
+)
+
+// Options of the certificates
+type Options struct {
+	Principals      []string          // the valid principals, see PrincipalPlaceholder
+	Validity        time.Duration     // defaults to DefaultValidity
+	CriticalOptions map[string]string // e.g. force-command, source-address
+	Extensions      map[string]string // defaults to DefaultExtensions
+}
+
+// LoadSigner reads the CA private key
+func LoadSigner(file string, passphrase []byte, options Options) (*Signer, error) {
+	for _, p := range options.Principals {
+		if strings.Contains(p, "{user}") {
+			return nil, ErrUserPlaceholder
+		}
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var caKey ssh.Signer
+	if len(passphrase) > 0 {
+		caKey, err = ssh.ParsePrivateKeyWithPassphrase(b, passphrase)
+	} else {
+		caKey, err = ssh.ParsePrivateKey(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(caKey, options), nil
+}
+
+func NewSigner(caKey ssh.Signer, options Options) *Signer {
+	if options.Validity <= 0 {
+		options.Validity = DefaultValidity
+	}
+	if len(options.Principals) == 0 {
+		options.Principals = []string{PrincipalPlaceholder}
+	}
+	if options.Extensions == nil {
+		options.Extensions = DefaultExtensions
+	}
+	return &Signer{ca: caKey, options: options}
+}
+
+// Signer 证书签发：为每个连接签发短期有效的用户证书
+type Signer struct {
+	ca      ssh.Signer
+	options Options
+}
+
+func (s *Signer) PublicKey() ssh.PublicKey {
+	return s.ca.PublicKey()
+}
+
+func (s *Signer) Options() Options {
+	return s.options
+}
+
+// Mint signs a certificate for a new ephemeral key, principal is the web user
+func (s *Signer) Mint(principal string) (ssh.Signer, *ssh.Certificate, error) {
+	if len(principal) == 0 {
+		return nil, nil, ErrNoPrincipal
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial := make([]byte, 8)
+	if _, err = rand.Read(serial); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	replacer := strings.NewReplacer(PrincipalPlaceholder, principal)
+	principals := make([]string, 0, len(s.options.Principals))
+	for _, p := range s.options.Principals {
+		if p = replacer.Replace(p); len(p) > 0 {
+			principals = append(principals, p)
+		}
+	}
+	cert := &ssh.Certificate{
+		Key:             key.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		KeyId:           "web-terminal:" + principal,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(s.options.Validity).Unix()),
+		Permissions: ssh.Permissions{
+			CriticalOptions: copyMap(s.options.CriticalOptions),
+			Extensions:      copyMap(s.options.Extensions),
+		},
+	}
+	if err = cert.SignCert(rand.Reader, s.ca); err != nil {
+		return nil, nil, err
+	}
+	signer, err := ssh.NewCertSigner(cert, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, cert, nil
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// ParseOptions parses "key=value" pairs separated by ";", e.g. "source-address=10.0.0.0/8,192.168.0.0/16;force-command=/bin/date"
+func ParseOptions(s string) map[string]string {
+	r := map[string]string{}
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		r[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return r
+}
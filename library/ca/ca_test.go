@@ -0,0 +1,68 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMint(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSigner(caKey, Options{
+		Principals:      []string{PrincipalPlaceholder, "ops-" + PrincipalPlaceholder},
+		Validity:        time.Minute,
+		CriticalOptions: ParseOptions("source-address=10.0.0.0/8,192.168.0.0/16"),
+	})
+	if _, _, err = s.Mint(""); err != ErrNoPrincipal {
+		t.Fatalf("expected ErrNoPrincipal, got %v", err)
+	}
+	signer, cert, err := s.Mint("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signer.PublicKey().Marshal(), cert.Marshal()) {
+		t.Fatal("the signer must present the certificate")
+	}
+	if cert.CriticalOptions["source-address"] != "10.0.0.0/8,192.168.0.0/16" {
+		t.Fatalf("unexpected critical options %v", cert.CriticalOptions)
+	}
+	if _, ok := cert.Extensions["permit-pty"]; !ok {
+		t.Fatalf("unexpected extensions %v", cert.Extensions)
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caKey.PublicKey().Marshal())
+		},
+	}
+	for _, principal := range []string{"alice", "ops-alice"} {
+		if err = checker.CheckCert(principal, cert); err != nil {
+			t.Errorf("%s: %v", principal, err)
+		}
+	}
+	if err = checker.CheckCert("root", cert); err == nil {
+		t.Error("root must not be a valid principal")
+	}
+	checker.Clock = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if err = checker.CheckCert("alice", cert); err == nil {
+		t.Error("the certificate must expire")
+	}
+}
+
+func TestLoadSignerUserPlaceholder(t *testing.T) {
+	// the ssh user comes from the browser, the principals may not depend on it
+	if _, err := LoadSigner("missing", nil, Options{Principals: []string{"{user}"}}); err != ErrUserPlaceholder {
+		t.Fatalf("expected ErrUserPlaceholder, got %v", err)
+	}
+}
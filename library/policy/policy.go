@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/web-terminal/library/auth"
+)
+
+const RolePrefix = "role:"
+
+// LookupIP resolves the host names for the CIDR rules
+var LookupIP = net.LookupIP
+
+// Load reads the policy file:
+//
+//	{
+//	  "roles": {"alice": ["admin"]},
+//	  "allow": [
+//	    {"principals": ["role:admin"]},
+//	    {"principals": ["*"], "protocols": ["ssh", "sftp"], "hosts": ["10.0.0.0/8:22", "*.internal"]},
+//	    {"principals": ["bob"], "protocols": ["cmd"], "commands": ["ping", "traceroute"]}
+//	  ],
+//	  "deny": [
+//	    {"hosts": ["10.0.0.1"]}
+//	  ]
+//	}
+func Load(file string) (*Policy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("parse policy file %q: %w", file, err)
+	}
+	return p, nil
+}
+
+// Policy 访问策略。Deny wins, anything not allowed is denied.
+type Policy struct {
+	Roles map[string][]string `json:"roles,omitempty"` // principal name => roles, merged with the roles of the authenticator
+	Allow []*Rule             `json:"allow"`
+	Deny  []*Rule             `json:"deny,omitempty"`
+}
+
+// Rule matches a request if all its non-empty lists match.
+// A rule with hosts never matches a request without host (cmd, replay), the same for commands.
+type Rule struct {
+	Name       string   `json:"name,omitempty"`
+	Principals []string `json:"principals,omitempty"` // "*", name or "role:name"
+	Protocols  []string `json:"protocols,omitempty"`  // ssh, ssh_exec, sftp, forward, telnet, cmd, local, replay
+	Hosts      []string `json:"hosts,omitempty"`      // see config.MatchAddress
+	Commands   []string `json:"commands,omitempty"`   // glob of the command name, patterns with "/" match the full path
+}
+
+// Request to be checked
+type Request struct {
+	Principal *auth.Principal
+	Protocol  string
+	Host      string
+	Port      int
+	Command   string
+}
+
+func (r *Request) String() string {
+	switch {
+	case len(r.Command) > 0:
+		return r.Protocol + " " + r.Command
+	case len(r.Host) > 0:
+		return fmt.Sprintf("%s %s", r.Protocol, net.JoinHostPort(r.Host, fmt.Sprint(r.Port)))
+	default:
+		return r.Protocol
+	}
+}
+
+// DeniedError is returned if the request is denied
+type DeniedError struct {
+	Request *Request
+	Rule    string // the name of the deny rule, empty if no allow rule matches
+}
+
+func (e *DeniedError) Error() string {
+	name := "anonymous"
+	if e.Request.Principal != nil {
+		name = e.Request.Principal.Name
+	}
+	msg := fmt.Sprintf("access denied: %s is not allowed to use %s", name, e.Request)
+	if len(e.Rule) > 0 {
+		msg += " (rule " + e.Rule + ")"
+	}
+	return msg
+}
+
+// Check returns a *DeniedError if the request is not allowed
+func (p *Policy) Check(req *Request) error {
+	roles := p.PrincipalRoles(req.Principal)
+	for i, rule := range p.Deny {
+		if rule.match(req, roles, false) {
+			name := rule.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("deny#%d", i+1)
+			}
+			return &DeniedError{Request: req, Rule: name}
+		}
+	}
+	for _, rule := range p.Allow {
+		if rule.match(req, roles, true) {
+			return nil
+		}
+	}
+	return &DeniedError{Request: req}
+}
+
+// PrincipalRoles returns the roles of the principal, including the roles of the policy file
+func (p *Policy) PrincipalRoles(principal *auth.Principal) []string {
+	if principal == nil {
+		return nil
+	}
+	if p == nil {
+		return principal.Roles
+	}
+	return append(append([]string{}, principal.Roles...), p.Roles[principal.Name]...)
+}
+
+func (r *Rule) match(req *Request, roles []string, strict bool) bool {
+	if len(r.Principals) > 0 && !MatchPrincipal(r.Principals, req.Principal, roles) {
+		return false
+	}
+	if len(r.Protocols) > 0 && !matchAny(r.Protocols, func(v string) bool {
+		return v == "*" || strings.EqualFold(v, req.Protocol)
+	}) {
+		return false
+	}
+	if len(r.Hosts) > 0 && (len(req.Host) == 0 || !matchAny(r.Hosts, func(v string) bool {
+		return matchAddress(v, req.Host, req.Port, strict)
+	})) {
+		return false
+	}
+	if len(r.Commands) > 0 && (len(req.Command) == 0 || !matchAny(r.Commands, func(v string) bool {
+		return MatchCommand(v, req.Command)
+	})) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, fn func(string) bool) bool {
+	for _, v := range patterns {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPrincipal matches the principal by "*", name or "role:name"
+func MatchPrincipal(patterns []string, principal *auth.Principal, roles []string) bool {
+	return matchAny(patterns, func(v string) bool {
+		if v == "*" {
+			return true
+		}
+		if principal == nil {
+			return false
+		}
+		if strings.HasPrefix(v, RolePrefix) {
+			role := strings.TrimPrefix(v, RolePrefix)
+			for _, r := range roles {
+				if r == role {
+					return true
+				}
+			}
+			return false
+		}
+		return v == principal.Name
+	})
+}
+
+// matchAddress resolves the host name for CIDR rules.
+// For allow rules (strict) all the addresses must match, for deny rules any address.
+func matchAddress(rule string, host string, port int, strict bool) bool {
+	if config.MatchAddress(rule, host, port) {
+		return true
+	}
+	if !strings.Contains(rule, "/") || net.ParseIP(host) != nil {
+		return false
+	}
+	ips, err := LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if config.MatchAddress(rule, ip.String(), port) != strict {
+			return !strict
+		}
+	}
+	return strict
+}
+
+// MatchCommand matches the command by glob, patterns without "/" only match bare command names
+func MatchCommand(pattern string, command string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.ContainsAny(pattern, `/\`) && strings.ContainsAny(command, `/\`) {
+		return false
+	}
+	if ok, err := path.Match(pattern, command); err == nil && ok {
+		return true
+	}
+	return pattern == command
+}
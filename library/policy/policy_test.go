@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/admpub/web-terminal/library/auth"
+)
+
+const testPolicy = `{
+  "roles": {"carol": ["ops"]},
+  "allow": [
+    {"principals": ["role:admin"]},
+    {"principals": ["role:ops"], "protocols": ["ssh", "sftp"], "hosts": ["10.0.0.0/8:22", "*.internal"]},
+    {"principals": ["bob"], "protocols": ["cmd"], "commands": ["ping", "/usr/bin/trace*"]}
+  ],
+  "deny": [
+    {"name": "db", "hosts": ["10.0.0.5", "db.internal"]}
+  ]
+}`
+
+func TestPolicy(t *testing.T) {
+	p := &Policy{}
+	if err := json.Unmarshal([]byte(testPolicy), p); err != nil {
+		t.Fatal(err)
+	}
+	LookupIP = func(host string) ([]net.IP, error) {
+		if host == "web.example.com" {
+			return []net.IP{net.ParseIP("10.1.1.1")}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	defer func() { LookupIP = net.LookupIP }()
+
+	alice := &auth.Principal{Name: "alice", Roles: []string{"admin"}}
+	bob := &auth.Principal{Name: "bob"}
+	carol := &auth.Principal{Name: "carol"}
+	for i, c := range []struct {
+		req     Request
+		allowed bool
+	}{
+		{Request{Principal: alice, Protocol: "cmd", Command: "/bin/sh"}, true},
+		{Request{Principal: alice, Protocol: "ssh", Host: "10.0.0.5", Port: 22}, false},
+		{Request{Principal: carol, Protocol: "ssh", Host: "10.2.3.4", Port: 22}, true},
+		{Request{Principal: carol, Protocol: "ssh", Host: "10.2.3.4", Port: 2222}, false},
+		{Request{Principal: carol, Protocol: "ssh", Host: "web.example.com", Port: 22}, true},
+		{Request{Principal: carol, Protocol: "sftp", Host: "git.internal", Port: 22}, true},
+		{Request{Principal: carol, Protocol: "sftp", Host: "db.internal", Port: 22}, false},
+		{Request{Principal: carol, Protocol: "telnet", Host: "git.internal", Port: 23}, false},
+		{Request{Principal: carol, Protocol: "cmd", Command: "ping"}, false},
+		{Request{Principal: bob, Protocol: "cmd", Command: "ping"}, true},
+		{Request{Principal: bob, Protocol: "cmd", Command: "/tmp/ping"}, false},
+		{Request{Principal: bob, Protocol: "cmd", Command: "/usr/bin/traceroute"}, true},
+		{Request{Principal: bob, Protocol: "replay"}, false},
+		{Request{Protocol: "replay"}, false},
+	} {
+		err := p.Check(&c.req)
+		if c.allowed != (err == nil) {
+			t.Errorf("#%d %s: expected allowed=%v, got %v", i, c.req.String(), c.allowed, err)
+		}
+		if err != nil {
+			var denied *DeniedError
+			if !errors.As(err, &denied) {
+				t.Errorf("#%d: expected *DeniedError, got %T", i, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package pty
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/admpub/errors"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+)
+
+var (
+	ErrUnsupported   = errors.New("the pseudo-terminal is not supported on this platform")
+	ErrUnknownSignal = errors.New("unknown signal")
+	ErrNotStarted    = errors.New("the program is not started")
+
+	// DefaultKillTimeout is the time the process group has to exit after SIGHUP before it is killed
+	DefaultKillTimeout = 2 * time.Second
+)
+
+// Config is the program run under the pseudo-terminal
+type Config struct {
+	Command string
+	Args    []string
+	Dir     string
+	Env     []string // added to the environment of the server, TERM is xterm-256color by default
+	Rows    int
+	Cols    int
+
+	KillTimeout time.Duration // defaults to DefaultKillTimeout
+}
+
+func (c *Config) killTimeout() time.Duration {
+	if c.KillTimeout > 0 {
+		return c.KillTimeout
+	}
+	return DefaultKillTimeout
+}
+
+func New(cfg *Config) *PTY {
+	return &PTY{
+		Config:  cfg,
+		exited:  make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+// PTY runs a local program under a pseudo-terminal, it talks the websocketx.Message frames:
+// stdin and the binary frames are written to the terminal, resize changes the window size,
+// signal is sent to the foreground process group, and the output is sent as stdout.
+type PTY struct {
+	Config *Config
+
+	cmd      *exec.Cmd
+	tty      *os.File
+	exitErr  error
+	exited   chan struct{}
+	drained  chan struct{}
+	closeOne sync.Once
+}
+
+// Serve runs the program for the websocket until the program exits or the websocket is closed
+func (p *PTY) Serve(conn *websocketx.Conn) error {
+	defer p.Close()
+	if err := p.Start(conn); err != nil {
+		return err
+	}
+	for {
+		msg, err := conn.Receive()
+		if err != nil {
+			// the websocket is closed by the client, or by Start after the program exits
+			return nil
+		}
+		if err = p.HandleMessage(conn, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Start runs the program and sends its output to conn, conn is closed after the program exits
+func (p *PTY) Start(conn websocketx.Sender) error {
+	cmd := exec.Command(p.Config.Command, p.Config.Args...)
+	cmd.Dir = p.Config.Dir
+	cmd.Env = append(append(os.Environ(), "TERM=xterm-256color"), p.Config.Env...)
+	rows, cols := p.Config.Rows, p.Config.Cols
+	if rows <= 0 {
+		rows = 40
+	}
+	if cols <= 0 {
+		cols = 80
+	}
+	tty, err := start(cmd, rows, cols)
+	if err != nil {
+		conn.Send(&websocketx.Message{Type: websocketx.MessageTypeStderr, Data: []byte(err.Error() + "\r\n")})
+		return err
+	}
+	p.cmd = cmd
+	p.tty = tty
+	go func() {
+		p.exitErr = cmd.Wait()
+		close(p.exited)
+		// the output still buffered in the terminal is sent before it is closed
+		select {
+		case <-p.drained:
+		case <-time.After(p.Config.killTimeout()):
+		}
+		p.Close()
+	}()
+	go func() {
+		buf := make([]byte, 8192)
+		for {
+			n, err := tty.Read(buf)
+			if n > 0 {
+				if werr := conn.Send(&websocketx.Message{Type: websocketx.MessageTypeStdout, Data: buf[:n]}); werr != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(p.drained)
+		<-p.exited
+		status := "exit status 0"
+		if p.exitErr != nil {
+			status = p.exitErr.Error()
+		}
+		conn.Send(&websocketx.Message{Type: websocketx.MessageTypeExit, Data: []byte(status)})
+		if c, ok := conn.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	return nil
+}
+
+// HandleMessage writes stdin to the terminal, resizes it or sends the signal
+func (p *PTY) HandleMessage(conn websocketx.Sender, msg *websocketx.Message) error {
+	if p.tty == nil {
+		return ErrNotStarted
+	}
+	var err error
+	switch msg.Type {
+	case websocketx.MessageTypeStdin:
+		_, err = p.tty.Write(msg.Data)
+	case websocketx.MessageTypeResize:
+		if err = setSize(p.tty, msg.Rows, msg.Cols); err != nil {
+			conn.Send(&websocketx.Message{Type: websocketx.MessageTypeStderr, Data: []byte("resize error\r\n")})
+			err = nil
+		}
+	case websocketx.MessageTypeSignal:
+		if err = p.Signal(msg.Signal); err != nil {
+			conn.Send(&websocketx.Message{Type: websocketx.MessageTypeStderr, Data: []byte(err.Error() + "\r\n")})
+			err = nil
+		}
+	}
+	return err
+}
+
+// Signal sends the signal to the foreground process group of the terminal, e.g. the program run by the shell
+func (p *PTY) Signal(name string) error {
+	if p.cmd == nil {
+		return ErrNotStarted
+	}
+	return signalForeground(p.cmd, p.tty, name)
+}
+
+// Close hangs up the process group and kills it if it does not exit in Config.KillTimeout
+func (p *PTY) Close() error {
+	p.closeOne.Do(func() {
+		if p.cmd == nil {
+			return
+		}
+		select {
+		case <-p.exited:
+		default:
+			signalGroup(p.cmd, "HUP")
+			select {
+			case <-p.exited:
+			case <-time.After(p.Config.killTimeout()):
+			}
+		}
+		// the background processes left in the group
+		signalGroup(p.cmd, "KILL")
+		p.tty.Close()
+	})
+	return nil
+}
@@ -0,0 +1,111 @@
+//go:build !windows
+
+package pty
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/library/ssh"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/admpub/websocket"
+)
+
+func dialPTY(t *testing.T, cfg *Config) (*websocket.Conn, func()) {
+	t.Helper()
+	if _, err := exec.LookPath(cfg.Command); err != nil {
+		t.Skip(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		New(cfg).Serve(conn)
+	}))
+	ws, _, err := (&websocket.Dialer{Subprotocols: []string{websocketx.Subprotocol}}).Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+	return ws, func() {
+		ws.Close()
+		server.Close()
+	}
+}
+
+// readUntil returns the output until it contains s, or the exit status if the program exits first
+func readUntil(t *testing.T, ws *websocket.Conn, s string) (output string, exit string) {
+	t.Helper()
+	for !strings.Contains(output, s) {
+		var msg ssh.Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for %q: %v, output %q", s, err, output)
+		}
+		switch msg.Type {
+		case ssh.MessageTypeStdout:
+			output += string(msg.Data)
+		case ssh.MessageTypeExit:
+			return output, string(msg.Data)
+		}
+	}
+	return output, ""
+}
+
+func TestResizeAndSignal(t *testing.T) {
+	ws, closeAll := dialPTY(t, &Config{Command: "sh", Rows: 24, Cols: 80})
+	defer closeAll()
+
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeStdin, Data: []byte("stty size; echo do''ne\n")})
+	if out, _ := readUntil(t, ws, "done"); !strings.Contains(out, "24 80") {
+		t.Fatalf("initial size: %q", out)
+	}
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeResize, Rows: 30, Cols: 100})
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeStdin, Data: []byte("stty size; echo re''sized\n")})
+	if out, _ := readUntil(t, ws, "resized"); !strings.Contains(out, "30 100") {
+		t.Fatalf("size after resize: %q", out)
+	}
+
+	// INT interrupts the foreground program like Ctrl-C, the shell is back before the sleep ends
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeStdin, Data: []byte("sleep 30\n")})
+	time.Sleep(300 * time.Millisecond)
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeSignal, Signal: "SIGINT"})
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeStdin, Data: []byte("echo interr''upted\n")})
+	readUntil(t, ws, "interrupted")
+
+	ws.WriteJSON(&ssh.Message{Type: ssh.MessageTypeStdin, Data: []byte("exit 3\n")})
+	if _, exit := readUntil(t, ws, "\x00"); exit != "exit status 3" {
+		t.Fatalf("exit = %q", exit)
+	}
+}
+
+func TestProcessGroupCleanup(t *testing.T) {
+	// the background sleep ignores SIGHUP, so that it is left after the hang up
+	ws, closeAll := dialPTY(t, &Config{Command: "sh", Args: []string{"-c", "trap '' HUP; sleep 60 & echo pid=$!; wait"}, KillTimeout: 200 * time.Millisecond})
+	out, _ := readUntil(t, ws, "\n")
+	closeAll()
+	i := strings.Index(out, "pid=")
+	if i < 0 {
+		t.Fatalf("no pid in %q", out)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(out[i+4:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("the background process is left after the websocket is closed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build !windows
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/admpub/errors"
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+var signals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"CONT":  syscall.SIGCONT,
+	"WINCH": syscall.SIGWINCH,
+}
+
+func lookupSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, errors.Wrap(ErrUnknownSignal, name)
+	}
+	return sig, nil
+}
+
+// start runs the program as the leader of a new session, the process group id is its pid
+func start(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	return pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+func setSize(tty *os.File, rows, cols int) error {
+	return pty.Setsize(tty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// signalForeground signals the foreground process group of the terminal, the program group if it is unknown
+func signalForeground(cmd *exec.Cmd, tty *os.File, name string) error {
+	sig, err := lookupSignal(name)
+	if err != nil {
+		return err
+	}
+	pgid, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil || pgid <= 0 {
+		pgid = cmd.Process.Pid
+	}
+	return syscall.Kill(-pgid, sig)
+}
+
+func signalGroup(cmd *exec.Cmd, name string) error {
+	sig, err := lookupSignal(name)
+	if err != nil {
+		return err
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+)
+
+func start(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	return nil, ErrUnsupported
+}
+
+func setSize(tty *os.File, rows, cols int) error {
+	return ErrUnsupported
+}
+
+func signalForeground(cmd *exec.Cmd, tty *os.File, name string) error {
+	return ErrUnsupported
+}
+
+func signalGroup(cmd *exec.Cmd, name string) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,77 @@
+package session
+
+import "sync"
+
+// Ring keeps the last bytes written to it, the offsets count all bytes ever written
+type Ring struct {
+	mu    sync.Mutex
+	buf   []byte
+	next  int   // the position of the next byte in buf
+	full  bool  // buf has wrapped around
+	total int64 // the offset of the next byte
+}
+
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Ring{buf: make([]byte, size)}
+}
+
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(p)
+	r.total += int64(n)
+	if n >= len(r.buf) {
+		copy(r.buf, p[n-len(r.buf):])
+		r.next = 0
+		r.full = true
+		return n, nil
+	}
+	c := copy(r.buf[r.next:], p)
+	if c < n {
+		copy(r.buf, p[c:])
+		r.full = true
+	}
+	r.next = (r.next + n) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+	return n, nil
+}
+
+// Total returns the offset of the next byte
+func (r *Ring) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// Since returns the bytes from offset to Total.
+// start is greater than offset if the bytes at offset have been overwritten.
+func (r *Ring) Since(offset int64) (data []byte, start int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	size := int64(r.next)
+	if r.full {
+		size = int64(len(r.buf))
+	}
+	oldest := r.total - size
+	if offset < oldest {
+		offset = oldest
+	}
+	if offset >= r.total {
+		return nil, r.total
+	}
+	n := int(r.total - offset)
+	data = make([]byte, n)
+	from := r.next - n
+	if from < 0 {
+		c := copy(data, r.buf[len(r.buf)+from:])
+		copy(data[c:], r.buf[:r.next])
+	} else {
+		copy(data, r.buf[from:r.next])
+	}
+	return data, offset
+}
@@ -0,0 +1,344 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultGracePeriod = time.Minute
+	DefaultBufferSize  = 256 * 1024
+)
+
+var (
+	ErrSessionNotFound = errors.New("the session is not found or expired")
+	ErrSessionClosed   = errors.New("the session is closed")
+	ErrGraceExpired    = errors.New("the client did not reconnect within the grace period")
+)
+
+func NewManager(gracePeriod time.Duration, bufferSize int) *Manager {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Manager{
+		gracePeriod: gracePeriod,
+		bufferSize:  bufferSize,
+		sessions:    map[string]*Session{},
+		shares:      map[string]*Session{},
+	}
+}
+
+// Manager 保存断开连接后等待恢复的会话和共享链接
+type Manager struct {
+	gracePeriod time.Duration // zero closes the sessions as soon as the owner is detached
+	bufferSize  int
+	mu          sync.Mutex
+	sessions    map[string]*Session // by the resume token
+	shares      map[string]*Session // by the share token
+}
+
+func (m *Manager) GracePeriod() time.Duration {
+	return m.gracePeriod
+}
+
+// Resumable reports whether the sessions survive the client disconnects
+func (m *Manager) Resumable() bool {
+	return m.gracePeriod > 0
+}
+
+// New creates a session of the owner, the output written before a client is attached is buffered
+func (m *Manager) New(owner string) (*Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Owner:   owner,
+		manager: m,
+		token:   token,
+		ring:    NewRing(m.bufferSize),
+		shares:  map[string]*shareLink{},
+		done:    make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.sessions[token] = s
+	m.mu.Unlock()
+	return s, nil
+}
+
+// Resume returns the session of the token and replaces the token, a token can only be used once
+func (m *Manager) Resume(token string, owner string) (*Session, error) {
+	newToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[token]
+	if !ok || s.Owner != owner {
+		return nil, ErrSessionNotFound
+	}
+	delete(m.sessions, token)
+	s.mu.Lock()
+	s.token = newToken
+	s.mu.Unlock()
+	m.sessions[newToken] = s
+	return s, nil
+}
+
+// Shared returns the session of the share token, the expired token is removed
+func (m *Manager) Shared(shareToken string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.shares[shareToken]
+	if !ok {
+		return nil, ErrShareNotFound
+	}
+	s.mu.Lock()
+	_, ok = s.shareRoleLocked(shareToken)
+	if !ok {
+		delete(s.shares, shareToken)
+	}
+	s.mu.Unlock()
+	if !ok {
+		delete(m.shares, shareToken)
+		return nil, ErrShareNotFound
+	}
+	return s, nil
+}
+
+// Len returns the number of the sessions
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func (m *Manager) remove(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for token, v := range m.sessions {
+		if v == s {
+			delete(m.sessions, token)
+		}
+	}
+	for token, v := range m.shares {
+		if v == s {
+			delete(m.shares, token)
+		}
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newParticipantID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Session is a terminal which outlives the client connection.
+// The output is written to the owner and the viewers of the share links,
+// and kept in a ring buffer for the resumed owner and the new viewers.
+type Session struct {
+	Owner string      // the principal name, only the owner can resume the session
+	Input io.Writer   // the input of the terminal, see WriteInput
+	Value interface{} // the state of the handler
+
+	manager *Manager
+	ring    *Ring
+
+	writeMu    sync.Mutex // serializes Write, the output reaches the owner in the order of the buffer
+	mu         sync.Mutex
+	token      string
+	owner      *Participant
+	viewers    []*Participant
+	shares     map[string]*shareLink // by the share token
+	controller *Participant          // the only participant whose input reaches the terminal
+	lastInput  time.Time
+	timer      *time.Timer
+	closed     bool
+	err        error
+	onClose    []func()
+	done       chan struct{}
+}
+
+// Token returns the current resume token
+func (s *Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// Write writes the output of the terminal to all participants, the participants failing to write are detached.
+// It waits for the owner's client if it falls behind, the viewers falling behind are dropped instead.
+func (s *Session) Write(p []byte) (int, error) {
+	data := append([]byte(nil), p...) // p may be reused by the caller before the data is written
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	s.ring.Write(data)
+	owner := s.owner
+	s.sendViewersLocked(data)
+	s.mu.Unlock()
+	if owner != nil {
+		owner.sendWait(data)
+	}
+	return len(p), nil
+}
+
+// Attach replays the output since offset to the owner's client and then sends it the new output.
+// resumed is called with the offset of the first replayed byte, it is greater than offset if the output at offset
+// was dropped from the buffer. The message returned by resumed, if any, is sent before the replay.
+// The client already attached as the owner is closed. Leave starts the grace period.
+func (s *Session) Attach(client io.WriteCloser, offset int64, resumed func(start int64) interface{}) (*Participant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	p := newParticipant(client, s.Owner, RoleOwner)
+	data, start := s.ring.Since(offset)
+	if resumed != nil {
+		if msg := resumed(start); msg != nil {
+			p.send(msg)
+		}
+	}
+	if len(data) > 0 {
+		p.send(data)
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if old := s.owner; old != nil {
+		old.drop()
+		if s.controller == old {
+			s.controller = p
+		}
+	}
+	s.owner = p
+	if s.controller == nil {
+		s.controller = p
+	}
+	go p.run(s)
+	s.notifyLocked()
+	return p, nil
+}
+
+// Leave detaches the participant after the writes queued to it, at most FlushTimeout.
+// The session is closed after the grace period if the owner is not attached again.
+func (s *Session) Leave(p *Participant) {
+	s.mu.Lock()
+	s.leaveLocked(p)
+	s.mu.Unlock()
+	p.flush(FlushTimeout)
+	p.detach()
+}
+
+func (s *Session) leaveLocked(p *Participant) {
+	if p == s.owner {
+		s.owner = nil
+		s.startGraceLocked()
+	} else if !s.removeViewerLocked(p) {
+		return
+	}
+	if s.controller == p {
+		s.controller = nil
+	}
+	s.notifyLocked()
+}
+
+func (s *Session) removeViewerLocked(p *Participant) bool {
+	for i, v := range s.viewers {
+		if v == p {
+			s.viewers = append(s.viewers[:i], s.viewers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) startGraceLocked() {
+	if s.closed || s.timer != nil {
+		return
+	}
+	grace := s.manager.gracePeriod
+	if grace <= 0 {
+		go s.Close()
+		return
+	}
+	s.timer = time.AfterFunc(grace, func() {
+		s.CloseWithError(ErrGraceExpired)
+	})
+}
+
+// Attached reports whether the owner is attached
+func (s *Session) Attached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owner != nil
+}
+
+// OnClose adds a function called when the session is closed
+func (s *Session) OnClose(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onClose = append(s.onClose, f)
+}
+
+func (s *Session) Close() error {
+	return s.CloseWithError(nil)
+}
+
+// CloseWithError closes the session, err is the reason returned by Err.
+// The viewers are closed, the owner's client is left to the handler to report the reason.
+func (s *Session) CloseWithError(err error) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.err = err
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	for _, v := range s.viewers {
+		v.drop()
+	}
+	s.viewers = nil
+	onClose := s.onClose
+	s.onClose = nil
+	s.mu.Unlock()
+	s.manager.remove(s)
+	for i := len(onClose) - 1; i >= 0; i-- {
+		onClose[i]()
+	}
+	close(s.done)
+	return nil
+}
+
+// Done is closed when the session is closed
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason of the close
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,171 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(8)
+	r.Write([]byte("abcde"))
+	data, start := r.Since(0)
+	if string(data) != "abcde" || start != 0 {
+		t.Fatalf("Since(0) = %q, %d", data, start)
+	}
+	r.Write([]byte("fghij"))
+	data, start = r.Since(0)
+	if string(data) != "cdefghij" || start != 2 {
+		t.Fatalf("Since(0) after wrap = %q, %d", data, start)
+	}
+	data, start = r.Since(7)
+	if string(data) != "hij" || start != 7 {
+		t.Fatalf("Since(7) = %q, %d", data, start)
+	}
+	if data, start = r.Since(10); len(data) != 0 || start != 10 {
+		t.Fatalf("Since(10) = %q, %d", data, start)
+	}
+	r.Write([]byte("0123456789"))
+	data, start = r.Since(0)
+	if string(data) != "23456789" || start != 12 || r.Total() != 20 {
+		t.Fatalf("Since(0) after overwrite = %q, %d, total %d", data, start, r.Total())
+	}
+}
+
+// testClient records the writes of its participant's goroutine
+type testClient struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (c *testClient) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *testClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *testClient) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+func (c *testClient) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Len()
+}
+
+func (c *testClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Reset()
+}
+
+// waitClosed waits for the client closed by drop
+func (c *testClient) waitClosed() bool {
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestResume(t *testing.T) {
+	m := NewManager(time.Hour, 1024)
+	s, err := m.New("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &testClient{}
+	owner, err := s.Attach(first, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner.flush(time.Second)
+	first.Reset() // the presence message
+	s.Write([]byte("hello "))
+	s.Leave(owner)
+	s.Write([]byte("world"))
+	if first.String() != "hello " {
+		t.Fatalf("the detached client received %q", first.String())
+	}
+
+	token := s.Token()
+	if _, err := m.Resume(token, "bob"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("resumed by another principal: %v", err)
+	}
+	resumed, err := m.Resume(token, "alice")
+	if err != nil || resumed != s {
+		t.Fatalf("Resume = %v, %v", resumed, err)
+	}
+	if _, err := m.Resume(token, "alice"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("the token is reused: %v", err)
+	}
+
+	second := &testClient{}
+	var from int64 = -1
+	owner, err = s.Attach(second, int64(first.Len()), func(start int64) interface{} {
+		from = start
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("!"))
+	owner.flush(time.Second)
+	if !strings.HasPrefix(second.String(), "world") || !strings.HasSuffix(second.String(), "!") || from != 6 {
+		t.Fatalf("the resumed client received %q from %d", second.String(), from)
+	}
+
+	third := &testClient{}
+	if owner, err = s.Attach(third, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	owner.flush(time.Second)
+	if !second.waitClosed() || !strings.HasPrefix(third.String(), "hello world!") {
+		t.Fatalf("take over: closed %v, received %q", second.closed, third.String())
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	m := NewManager(50*time.Millisecond, 0)
+	s, err := m.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan struct{})
+	s.OnClose(func() { close(closed) })
+	owner, err := s.Attach(&testClient{}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Leave(owner)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session is not closed after the grace period")
+	}
+	if !errors.Is(s.Err(), ErrGraceExpired) || m.Len() != 0 {
+		t.Fatalf("Err = %v, Len = %d", s.Err(), m.Len())
+	}
+	if _, err := s.Attach(&testClient{}, 0, nil); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("attached to a closed session: %v", err)
+	}
+}
@@ -0,0 +1,425 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Role is the permission of a participant
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleReadWrite Role = "readwrite" // co-pilot, may type when it holds the control
+	RoleReadOnly  Role = "readonly"
+)
+
+var (
+	ErrShareNotFound      = errors.New("the share link is not found, revoked or expired")
+	ErrInvalidRole        = errors.New("the role must be readonly or readwrite")
+	ErrParticipantUnknown = errors.New("the participant is not attached")
+	ErrReadOnly           = errors.New("the participant is read-only")
+	ErrControlBusy        = errors.New("another participant is typing")
+)
+
+var (
+	// ControlIdle is the time the controller must be idle before a co-pilot takes the control by typing
+	ControlIdle = 2 * time.Second
+	// SendQueueSize is the number of the writes queued to a participant, the viewers falling further behind are dropped
+	SendQueueSize = 256
+	// FlushTimeout is the time Leave waits for the queued writes of the participant
+	FlushTimeout = 5 * time.Second
+)
+
+// DefaultShareTTL is the lifetime of the share links created without a TTL
+const DefaultShareTTL = time.Hour
+
+// shareLink is a share token of the session
+type shareLink struct {
+	role    Role
+	expires time.Time
+}
+
+func (l *shareLink) expired(now time.Time) bool {
+	return !now.Before(l.expires)
+}
+
+// Participant is a client attached to the session.
+// The client is written by its own goroutine from a queue, a stalled client never blocks the session.
+type Participant struct {
+	ID    string
+	Name  string
+	Role  Role
+	share string // the share token of the viewer
+	since time.Time
+
+	client  io.WriteCloser
+	queue   chan interface{} // the output, the JSON messages and the flush markers
+	stopped chan struct{}    // closed when the participant is detached, it stops the writer
+	stop    sync.Once
+}
+
+// ParticipantInfo is the participant in the presence message
+type ParticipantInfo struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Role    Role      `json:"role"`
+	Control bool      `json:"control,omitempty"` // the participant's input reaches the terminal
+	Since   time.Time `json:"since"`
+}
+
+// PresenceMessage is sent to all participants whenever a participant joins, leaves or takes the control
+type PresenceMessage struct {
+	Type         string             `json:"type"` // presence
+	Participants []*ParticipantInfo `json:"participants"`
+}
+
+// Share creates a share link of the role, it is valid for ttl (DefaultShareTTL if ttl is not positive)
+// until it is revoked or the session is closed. The viewers joined before the expiry are kept.
+func (s *Session) Share(role Role, ttl time.Duration) (token string, expires time.Time, err error) {
+	if role != RoleReadOnly && role != RoleReadWrite {
+		return "", expires, ErrInvalidRole
+	}
+	if ttl <= 0 {
+		ttl = DefaultShareTTL
+	}
+	token, err = newToken()
+	if err != nil {
+		return "", expires, err
+	}
+	now := time.Now()
+	expires = now.Add(ttl)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return "", time.Time{}, ErrSessionClosed
+	}
+	var expired []string
+	for t, link := range s.shares {
+		if link.expired(now) {
+			delete(s.shares, t)
+			expired = append(expired, t)
+		}
+	}
+	s.shares[token] = &shareLink{role: role, expires: expires}
+	s.mu.Unlock()
+	s.manager.mu.Lock()
+	for _, t := range expired {
+		delete(s.manager.shares, t)
+	}
+	s.manager.shares[token] = s
+	s.manager.mu.Unlock()
+	return token, expires, nil
+}
+
+// shareRoleLocked returns the role of the share link, ok is false if it is not found or expired
+func (s *Session) shareRoleLocked(shareToken string) (role Role, ok bool) {
+	link, ok := s.shares[shareToken]
+	if !ok || link.expired(time.Now()) {
+		return "", false
+	}
+	return link.role, true
+}
+
+// Unshare revokes the share link, the viewers joined by it are closed
+func (s *Session) Unshare(shareToken string) {
+	s.mu.Lock()
+	delete(s.shares, shareToken)
+	for _, v := range append([]*Participant{}, s.viewers...) {
+		if v.share == shareToken {
+			v.drop()
+			s.leaveLocked(v)
+		}
+	}
+	s.mu.Unlock()
+	s.manager.mu.Lock()
+	delete(s.manager.shares, shareToken)
+	s.manager.mu.Unlock()
+}
+
+// Revoke closes the viewer and revokes the share link it joined by, so that it can not join again
+func (s *Session) Revoke(id string) error {
+	s.mu.Lock()
+	var share string
+	for _, v := range s.viewers {
+		if v.ID == id {
+			share = v.share
+			break
+		}
+	}
+	s.mu.Unlock()
+	if len(share) == 0 {
+		return ErrParticipantUnknown
+	}
+	s.Unshare(share)
+	return nil
+}
+
+// Join attaches the client by the share link, the buffered output is replayed.
+// The message returned by joined, if any, is sent before the replay.
+func (s *Session) Join(client io.WriteCloser, name string, shareToken string, joined func(*Participant) interface{}) (*Participant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	role, ok := s.shareRoleLocked(shareToken)
+	if !ok {
+		return nil, ErrShareNotFound
+	}
+	p := newParticipant(client, name, role)
+	p.share = shareToken
+	if joined != nil {
+		if msg := joined(p); msg != nil {
+			p.send(msg)
+		}
+	}
+	if data, _ := s.ring.Since(0); len(data) > 0 {
+		p.send(data)
+	}
+	s.viewers = append(s.viewers, p)
+	go p.run(s)
+	s.notifyLocked()
+	return p, nil
+}
+
+// WriteInput writes the input of the participant to the terminal.
+// Only the controller's input is written, a co-pilot takes the control by typing when the controller is idle for ControlIdle.
+func (s *Session) WriteInput(p *Participant, data []byte) error {
+	s.mu.Lock()
+	if p.Role == RoleReadOnly {
+		s.mu.Unlock()
+		return ErrReadOnly
+	}
+	if s.controller != p {
+		if err := s.takeControlLocked(p, false); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.lastInput = time.Now()
+	input := s.Input
+	s.mu.Unlock()
+	if input == nil {
+		return nil
+	}
+	_, err := input.Write(data)
+	return err
+}
+
+// TakeControl makes the participant the controller, the owner always gets the control
+func (s *Session) TakeControl(p *Participant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.takeControlLocked(p, true)
+}
+
+func (s *Session) takeControlLocked(p *Participant, force bool) error {
+	switch {
+	case p.Role == RoleReadOnly:
+		return ErrReadOnly
+	case p != s.owner && !s.hasViewerLocked(p):
+		return ErrParticipantUnknown
+	case s.controller == p:
+		return nil
+	case s.controller == nil, p.Role == RoleOwner && force, time.Since(s.lastInput) >= ControlIdle:
+		s.controller = p
+		s.notifyLocked()
+		return nil
+	default:
+		return ErrControlBusy
+	}
+}
+
+// ReleaseControl gives up the control, the next participant typing takes it
+func (s *Session) ReleaseControl(p *Participant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.controller == p {
+		s.controller = nil
+		s.notifyLocked()
+	}
+}
+
+// Controller returns the name of the controller, it is empty if nobody holds the control
+func (s *Session) Controller() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.controller == nil {
+		return ""
+	}
+	return s.controller.Name
+}
+
+func (s *Session) hasViewerLocked(p *Participant) bool {
+	for _, v := range s.viewers {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Participants returns the owner and the viewers
+func (s *Session) Participants() []*ParticipantInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.participantsLocked()
+}
+
+func (s *Session) participantsLocked() []*ParticipantInfo {
+	r := make([]*ParticipantInfo, 0, len(s.viewers)+1)
+	all := s.viewers
+	if s.owner != nil {
+		all = append([]*Participant{s.owner}, all...)
+	}
+	for _, p := range all {
+		r = append(r, &ParticipantInfo{ID: p.ID, Name: p.Name, Role: p.Role, Control: p == s.controller, Since: p.since})
+	}
+	return r
+}
+
+// sendLocked queues v to all participants, the viewers falling behind are dropped.
+// The owner misses v if its queue is full, the output is queued to it by Write.
+func (s *Session) sendLocked(v interface{}) {
+	if s.owner != nil {
+		s.owner.send(v)
+	}
+	s.sendViewersLocked(v)
+}
+
+func (s *Session) sendViewersLocked(v interface{}) {
+	var slow []*Participant
+	for _, p := range s.viewers {
+		if !p.send(v) {
+			slow = append(slow, p)
+		}
+	}
+	for _, p := range slow {
+		p.drop()
+		s.leaveLocked(p)
+	}
+}
+
+// jsonWriter is implemented by the clients sending the JSON messages apart from the output, e.g. the websocket
+type jsonWriter interface {
+	WriteJSON(v interface{}) error
+}
+
+// notifyLocked sends the presence message to all participants
+func (s *Session) notifyLocked() {
+	if s.closed {
+		return
+	}
+	s.sendLocked(&PresenceMessage{Type: "presence", Participants: s.participantsLocked()})
+}
+
+func newParticipant(client io.WriteCloser, name string, role Role) *Participant {
+	size := SendQueueSize
+	if size <= 0 {
+		size = 1
+	}
+	return &Participant{
+		ID:      newParticipantID(),
+		Name:    name,
+		Role:    role,
+		since:   time.Now(),
+		client:  client,
+		queue:   make(chan interface{}, size),
+		stopped: make(chan struct{}),
+	}
+}
+
+// run writes the queue to the client until the participant is detached, the client failing to write is dropped
+func (p *Participant) run(s *Session) {
+	for {
+		select {
+		case <-p.stopped:
+			return
+		case v := <-p.queue:
+			if err := p.write(v); err != nil {
+				p.drop()
+				s.Leave(p)
+				return
+			}
+		}
+	}
+}
+
+func (p *Participant) write(v interface{}) error {
+	switch v := v.(type) {
+	case []byte:
+		_, err := p.client.Write(v)
+		return err
+	case chan struct{}:
+		close(v)
+		return nil
+	}
+	if w, ok := p.client.(jsonWriter); ok {
+		return w.WriteJSON(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Write(data)
+	return err
+}
+
+// send queues v without waiting, it reports false if the queue is full or the participant is detached
+func (p *Participant) send(v interface{}) bool {
+	select {
+	case <-p.stopped:
+		return false
+	default:
+	}
+	select {
+	case p.queue <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// sendWait queues v, it waits for the writer if the queue is full
+func (p *Participant) sendWait(v interface{}) bool {
+	select {
+	case <-p.stopped:
+		return false
+	case p.queue <- v:
+		return true
+	}
+}
+
+// flush waits for the writes queued before, at most timeout
+func (p *Participant) flush(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	done := make(chan struct{})
+	select {
+	case p.queue <- done:
+	case <-p.stopped:
+		return
+	case <-timer.C:
+		return
+	}
+	select {
+	case <-done:
+	case <-p.stopped:
+	case <-timer.C:
+	}
+}
+
+// detach stops the writer, the queued writes are dropped
+func (p *Participant) detach() {
+	p.stop.Do(func() { close(p.stopped) })
+}
+
+// drop stops the writer and closes the client, the close may block on the network so it is not waited for
+func (p *Participant) drop() {
+	p.detach()
+	go p.client.Close()
+}
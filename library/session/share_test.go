@@ -0,0 +1,228 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func lastPresence(t *testing.T, c *testClient) *PresenceMessage {
+	t.Helper()
+	i := strings.LastIndex(c.String(), `{"type":"presence"`)
+	if i < 0 {
+		t.Fatalf("no presence message in %q", c.String())
+	}
+	msg := &PresenceMessage{}
+	if err := json.NewDecoder(strings.NewReader(c.String()[i:])).Decode(msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestShare(t *testing.T) {
+	defer func(idle time.Duration) { ControlIdle = idle }(ControlIdle)
+	ControlIdle = time.Hour
+
+	m := NewManager(time.Hour, 1024)
+	s, err := m.New("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := &bytes.Buffer{}
+	s.Input = input
+	ownerClient := &testClient{}
+	owner, err := s.Attach(ownerClient, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("$ "))
+	owner.flush(time.Second)
+
+	if _, _, err = s.Share(RoleOwner, 0); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("shared as the owner: %v", err)
+	}
+	readOnly, _, err := s.Share(RoleReadOnly, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readWrite, _, err := s.Share(RoleReadWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared, err := m.Shared(readOnly); err != nil || shared != s {
+		t.Fatalf("Shared = %v, %v", shared, err)
+	}
+
+	watcherClient := &testClient{}
+	watcher, err := s.Join(watcherClient, "bob", readOnly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.flush(time.Second)
+	if !strings.HasPrefix(watcherClient.String(), "$ ") {
+		t.Fatalf("the output is not replayed to the viewer: %q", watcherClient.String())
+	}
+	copilotClient := &testClient{}
+	copilot, err := s.Join(copilotClient, "carol", readWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner.flush(time.Second)
+	if presence := lastPresence(t, ownerClient); len(presence.Participants) != 3 || !presence.Participants[0].Control {
+		t.Fatalf("presence = %+v", presence.Participants)
+	}
+
+	if err = s.WriteInput(watcher, []byte("rm")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("the read-only viewer typed: %v", err)
+	}
+	if err = s.WriteInput(owner, []byte("ls")); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.WriteInput(copilot, []byte("pwd")); !errors.Is(err, ErrControlBusy) {
+		t.Fatalf("the co-pilot interleaved the owner's input: %v", err)
+	}
+	s.ReleaseControl(owner)
+	if err = s.WriteInput(copilot, []byte("pwd")); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.WriteInput(owner, []byte("x")); !errors.Is(err, ErrControlBusy) {
+		t.Fatalf("the owner interleaved the co-pilot's input: %v", err)
+	}
+	if err = s.TakeControl(owner); err != nil {
+		t.Fatal(err)
+	}
+	if input.String() != "lspwd" {
+		t.Fatalf("input = %q", input.String())
+	}
+
+	if err = s.Revoke(watcher.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !watcherClient.waitClosed() {
+		t.Fatal("the revoked viewer is not closed")
+	}
+	if _, err = s.Join(&testClient{}, "bob", readOnly, nil); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("joined by a revoked link: %v", err)
+	}
+	if _, err = m.Shared(readOnly); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("the revoked link is found: %v", err)
+	}
+	copilot.flush(time.Second)
+	if presence := lastPresence(t, copilotClient); len(presence.Participants) != 2 {
+		t.Fatalf("presence after revoke = %+v", presence.Participants)
+	}
+
+	s.Close()
+	if !copilotClient.waitClosed() {
+		t.Fatal("the viewer is not closed with the session")
+	}
+	if _, err = m.Shared(readWrite); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("the link outlives the session: %v", err)
+	}
+}
+
+// stalledClient blocks the writes until it is closed, like a viewer which stopped reading
+type stalledClient struct {
+	closing sync.Once
+	closed  chan struct{}
+}
+
+func (c *stalledClient) Write(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (c *stalledClient) Close() error {
+	c.closing.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestStalledViewer(t *testing.T) {
+	defer func(size int) { SendQueueSize = size }(SendQueueSize)
+	SendQueueSize = 4
+
+	m := NewManager(time.Hour, 1024)
+	s, err := m.New("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ownerClient := &testClient{}
+	owner, err := s.Attach(ownerClient, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.Share(RoleReadOnly, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stalled := &stalledClient{closed: make(chan struct{})}
+	viewer, err := s.Join(stalled, "bob", token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*SendQueueSize; i++ {
+			s.Write([]byte("x"))
+		}
+		s.Leave(viewer)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stalled viewer blocks the output")
+	}
+	select {
+	case <-stalled.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stalled viewer is not dropped")
+	}
+	if participants := s.Participants(); len(participants) != 1 || participants[0].ID != owner.ID {
+		t.Fatalf("participants = %+v", participants)
+	}
+	owner.flush(time.Second)
+	if strings.Count(ownerClient.String(), "x") != 2*SendQueueSize {
+		t.Fatalf("the owner received %q", ownerClient.String())
+	}
+}
+
+func TestShareExpires(t *testing.T) {
+	m := NewManager(time.Hour, 1024)
+	s, err := m.New("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	token, expires, err := s.Share(RoleReadOnly, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d <= 0 || d > 50*time.Millisecond {
+		t.Fatalf("expires in %v", d)
+	}
+	viewer, err := s.Join(&testClient{}, "bob", token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err = s.Join(&testClient{}, "carol", token, nil); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("joined by an expired link: %v", err)
+	}
+	if _, err = m.Shared(token); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("the expired link is found: %v", err)
+	}
+	if participants := s.Participants(); len(participants) != 1 || participants[0].ID != viewer.ID {
+		t.Fatalf("the viewer joined before the expiry is dropped: %+v", participants)
+	}
+	if _, _, err = s.Share(RoleReadOnly, 0); err != nil || len(m.shares) != 1 || len(s.shares) != 1 {
+		t.Fatalf("the expired links are kept: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package ssh
+
+import (
+	"github.com/admpub/errors"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ForwardAgent forwards the agent of the end host account to the session,
+// it does nothing unless AccountConfig.ForwardAgent is enabled
+func (s *SSH) ForwardAgent() error {
+	end := s.Config.End
+	if end == nil || end.Account == nil || !end.Account.ForwardAgent || end.Account.Agent == nil {
+		return nil
+	}
+	if err := agent.ForwardToAgent(s.Client, end.Account.Agent); err != nil {
+		return errors.Wrap(err, "failed to forward the agent")
+	}
+	if err := agent.RequestAgentForwarding(s.Session); err != nil {
+		return errors.Wrap(err, "the agent forwarding is refused")
+	}
+	return nil
+}
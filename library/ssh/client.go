@@ -2,8 +2,10 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/admpub/web-terminal/config"
@@ -11,58 +13,155 @@ import (
 )
 
 func NewClient(ctx context.Context, cfg *config.SSHConfig, timeout time.Duration) (*ssh.Client, error) {
-	return buildClient(ctx, cfg, timeout)
+	client, _, err := buildClient(ctx, cfg, timeout, nil)
+	return client, err
 }
 
-// buildClient builds the *ssh.Client connection via the jump
-// host to the end host. ATM only one jump host is supported
-func buildClient(ctx context.Context, cfg *config.SSHConfig, timeout time.Duration) (*ssh.Client, error) {
+func hostAddress(hostConfig *config.HostConfig) string {
 	port := defaultPort
-	if cfg.End.Port > 0 {
-		port = cfg.End.Port
+	if hostConfig.Port > 0 {
+		port = hostConfig.Port
 	}
-	endHostAddress := fmt.Sprintf(`%s:%d`, cfg.End.Host, port)
-	if len(cfg.Jumps) > 0 { //TODO atm only one jump host is supported
-		jumpConfg := cfg.Jumps[0]
-		port := defaultPort
-		if jumpConfg.Port > 0 {
-			port = jumpConfg.Port
+	return net.JoinHostPort(hostConfig.Host, fmt.Sprint(port))
+}
+
+// hopTimeout returns the dial timeout of the hop, ClientConfig.Timeout takes precedence
+func hopTimeout(hostConfig *config.HostConfig, timeout time.Duration) time.Duration {
+	if hostConfig.ClientConfig != nil && hostConfig.ClientConfig.Timeout > 0 {
+		return hostConfig.ClientConfig.Timeout
+	}
+	if timeout > 0 {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// buildClient builds the *ssh.Client connection via every jump
+// host in cfg.Jumps (in order) to the end host.
+// Closing the returned client also closes all the intermediate clients.
+// onLost is called if any hop stops answering the keepalive requests, it may be nil.
+// The algorithms negotiated with the end host are nil if the key exchange could not be sniffed.
+func buildClient(ctx context.Context, cfg *config.SSHConfig, timeout time.Duration, onLost func(error)) (*ssh.Client, *config.NegotiatedAlgorithms, error) {
+	hops := make([]*config.HostConfig, 0, len(cfg.Jumps)+1)
+	hops = append(hops, cfg.Jumps...)
+	hops = append(hops, cfg.End)
+
+	clients := make([]*ssh.Client, 0, len(hops))
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
 		}
-		jumpHostAddress := fmt.Sprintf(`%s:%d`, jumpConfg.Host, port)
-		jumpHostClient, err := ssh.Dial("tcp", jumpHostAddress, jumpConfg.ClientConfig)
-		if err != nil {
-			return nil, fmt.Errorf("ssh.Dial to jump host failed: %w", err)
+	}
+	var (
+		prevAddress string
+		negotiated  *config.NegotiatedAlgorithms
+	)
+	for index, hop := range hops {
+		address := hostAddress(hop)
+		hopName := hopName(index, len(hops), address)
+		hopCtx, cancel := context.WithTimeout(ctx, hopTimeout(hop, timeout))
+		var (
+			conn net.Conn
+			err  error
+		)
+		if len(hop.ProxyCommand) > 0 {
+			if index > 0 {
+				cancel()
+				closeAll()
+				return nil, nil, fmt.Errorf("ProxyCommand of %s is not supported behind jump hosts", hopName)
+			}
+			conn, err = dialCommand(hop.ProxyCommand)
+		} else if index == 0 {
+			var dialer net.Dialer
+			conn, err = dialer.DialContext(hopCtx, "tcp", address)
+		} else {
+			conn, err = clients[index-1].DialContext(hopCtx, "tcp", address)
 		}
-		jumpHostConn, err := dialNextJump(ctx, jumpHostClient, endHostAddress, timeout)
 		if err != nil {
-			return nil, fmt.Errorf("ssh.Dial from jump to jump host(%q) failed: %w", endHostAddress, err)
+			cancel()
+			closeAll()
+			if index == 0 {
+				return nil, nil, fmt.Errorf("dial to %s failed: %w", hopName, err)
+			}
+			return nil, nil, fmt.Errorf("dial from jump host(%q) to %s failed: %w", prevAddress, hopName, err)
+		}
+		cancel()
+		sniffer := newKexSniffer(conn)
+		clientConfig, deadline := withHandshakeDeadline(conn, hop.ClientConfig, hopTimeout(hop, timeout))
+		ncc, chans, reqs, err := ssh.NewClientConn(sniffer, address, clientConfig)
+		if deadline.stop() {
+			// the connection is closed by the deadline
+			err = errHandshakeTimeout
 		}
-
-		ncc, chans, reqs, err := ssh.NewClientConn(jumpHostConn, endHostAddress, cfg.End.ClientConfig)
 		if err != nil {
-			jumpHostConn.Close()
-			return nil, fmt.Errorf("failed to create ssh client to end host(%q): %w", endHostAddress, err)
+			conn.Close()
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to create ssh client to %s: %w", hopName, err)
 		}
+		if index == len(hops)-1 {
+			negotiated = sniffer.Negotiated()
+		}
+		client := ssh.NewClient(ncc, chans, reqs)
+		if interval, countMax := keepAliveSettings(hop); interval > 0 {
+			go keepAlive(client, address, interval, countMax, onLost)
+		}
+		clients = append(clients, client)
+		prevAddress = address
+	}
 
-		return ssh.NewClient(ncc, chans, reqs), nil
+	endHostClient := clients[len(clients)-1]
+	if len(clients) > 1 {
+		go func() {
+			endHostClient.Wait()
+			closeAll()
+		}()
 	}
+	return endHostClient, negotiated, nil
+}
+
+var errHandshakeTimeout = errors.New("ssh handshake timed out")
 
-	endHostClient, err := ssh.Dial("tcp", endHostAddress, cfg.End.ClientConfig)
-	if err != nil {
-		return nil, fmt.Errorf("ssh.Dial directly to end host(%q) failed: %w", endHostAddress, err)
+// handshakeDeadline closes the connection if the version and key exchanges are not done
+// in time, channels of the jump hosts and ProxyCommand pipes do not support SetDeadline
+type handshakeDeadline struct {
+	timeout time.Duration
+	timer   *time.Timer
+	fired   atomic.Bool
+}
+
+// withHandshakeDeadline returns a copy of clientConfig whose host key callback pauses the
+// deadline while the user is asked to confirm the fingerprint
+func withHandshakeDeadline(conn net.Conn, clientConfig *ssh.ClientConfig, timeout time.Duration) (*ssh.ClientConfig, *handshakeDeadline) {
+	d := &handshakeDeadline{timeout: timeout}
+	d.timer = time.AfterFunc(timeout, func() {
+		d.fired.Store(true)
+		conn.Close()
+	})
+	if clientConfig == nil || clientConfig.HostKeyCallback == nil {
+		return clientConfig, d
 	}
+	copied := *clientConfig
+	callback := clientConfig.HostKeyCallback
+	copied.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !d.timer.Stop() {
+			return errHandshakeTimeout
+		}
+		err := callback(hostname, remote, key)
+		d.timer.Reset(d.timeout)
+		return err
+	}
+	return &copied, d
+}
 
-	return endHostClient, nil
+// stop stops the deadline and reports whether it has expired
+func (d *handshakeDeadline) stop() bool {
+	d.timer.Stop()
+	return d.fired.Load()
 }
 
-// dialNextJump dials the next jump host in the chain
-func dialNextJump(ctx context.Context, jumpHostClient *ssh.Client, nextJumpAddress string, timeout time.Duration) (net.Conn, error) {
-	connectionTimeout := timeout
-	if connectionTimeout <= 0 {
-		connectionTimeout = defaultTimeout
+func hopName(index int, total int, address string) string {
+	if index == total-1 {
+		return fmt.Sprintf("end host(%q)", address)
 	}
-	ctx, cancel := context.WithTimeout(ctx, connectionTimeout)
-	defer cancel()
-	jumpHostConn, err := jumpHostClient.DialContext(ctx, "tcp", nextJumpAddress)
-	return jumpHostConn, err
+	return fmt.Sprintf("jump host #%d(%q)", index+1, address)
 }
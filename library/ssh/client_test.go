@@ -0,0 +1,114 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// closed waits for the test server connection to end
+func closed(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s is not closed", what)
+	}
+}
+
+func TestBuildClientJump(t *testing.T) {
+	jump, jumpDone := startForwardTestServer(t, &ssh.ServerConfig{NoClientAuth: true})
+	end, endDone := startForwardTestServer(t, &ssh.ServerConfig{NoClientAuth: true, ServerVersion: "SSH-2.0-end"})
+	client, _, err := buildClient(context.Background(), config.NewSSHConfig(jump, end), time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := string(client.ServerVersion()); version != "SSH-2.0-end" {
+		t.Fatalf("connected to %s, want the end host", version)
+	}
+	select {
+	case <-jumpDone:
+		t.Fatal("the jump host is closed while the end host is connected")
+	default:
+	}
+	// the jump host is closed after the end host client exits
+	client.Close()
+	closed(t, endDone, "the end host")
+	closed(t, jumpDone, "the jump host")
+}
+
+func TestBuildClientJumpFailure(t *testing.T) {
+	// the end host refuses the client without credentials
+	jump, jumpDone := startForwardTestServer(t, &ssh.ServerConfig{NoClientAuth: true})
+	end := startTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil },
+	}, true)
+	_, _, err := buildClient(context.Background(), config.NewSSHConfig(jump, end), time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), hopName(1, 2, hostAddress(end))) {
+		t.Fatalf("the error must name the end host: %v", err)
+	}
+	closed(t, jumpDone, "the jump host")
+
+	// the end host can not be dialed from the jump host
+	jump, jumpDone = startForwardTestServer(t, &ssh.ServerConfig{NoClientAuth: true})
+	unreachable := config.NewHostConfig(&ssh.ClientConfig{User: "root", HostKeyCallback: ssh.InsecureIgnoreHostKey()}, "127.0.0.1", 1)
+	_, _, err = buildClient(context.Background(), config.NewSSHConfig(jump, unreachable), time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), `dial from jump host("`+hostAddress(jump)+`") to end host("127.0.0.1:1")`) {
+		t.Fatalf("the error must name the hops: %v", err)
+	}
+	closed(t, jumpDone, "the jump host")
+
+	// the jump host fails
+	_, _, err = buildClient(context.Background(), config.NewSSHConfig(unreachable, end), time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), `dial to jump host #1("127.0.0.1:1")`) {
+		t.Fatalf("the error must name the jump host: %v", err)
+	}
+}
+
+func TestBuildClientHandshakeTimeout(t *testing.T) {
+	// the host accepts the connection but never speaks ssh
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(io.Discard, c)
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	silent := config.NewHostConfig(&ssh.ClientConfig{User: "root", HostKeyCallback: ssh.InsecureIgnoreHostKey()}, host, portN)
+	start := time.Now()
+	_, _, err = buildClient(context.Background(), config.NewSSHConfig(silent), 100*time.Millisecond, nil)
+	if !errors.Is(err, errHandshakeTimeout) {
+		t.Fatalf("the handshake must time out: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the handshake timed out after %v", elapsed)
+	}
+
+	// the deadline is paused while the host key is confirmed
+	hostConfig := startTestServer(t, &ssh.ServerConfig{NoClientAuth: true}, true)
+	hostConfig.SetHostKeyCallback(func(string, net.Addr, ssh.PublicKey) error {
+		time.Sleep(300 * time.Millisecond)
+		return nil
+	})
+	client, _, err := buildClient(context.Background(), config.NewSSHConfig(hostConfig), 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
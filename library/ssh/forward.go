@@ -0,0 +1,325 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/admpub/errors"
+)
+
+type ForwardType string
+
+const (
+	// ForwardLocal like `ssh -L`, the streams are multiplexed over the websocket
+	ForwardLocal ForwardType = "local"
+	// ForwardRemote like `ssh -R`, listens on the ssh host and dials from web-terminal server
+	ForwardRemote ForwardType = "remote"
+	// ForwardDynamic like `ssh -D`, a SOCKS5 listener on web-terminal server
+	ForwardDynamic ForwardType = "dynamic"
+)
+
+var ErrForwardClosed = errors.New("forward is closed")
+
+func newForwardID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newForward(typ ForwardType, listen string, target string) *Forward {
+	return &Forward{
+		ID:        newForwardID(),
+		Type:      typ,
+		Listen:    listen,
+		Target:    target,
+		CreatedAt: time.Now(),
+		conns:     map[net.Conn]struct{}{},
+		done:      make(chan struct{}),
+	}
+}
+
+// Forward 端口转发会话
+type Forward struct {
+	ID        string
+	Type      ForwardType
+	Listen    string
+	Target    string
+	CreatedAt time.Time
+
+	bytesIn   int64 // target -> client
+	bytesOut  int64 // client -> target
+	total     int64
+	listener  net.Listener
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+type ForwardStats struct {
+	ID          string      `json:"id"`
+	Type        ForwardType `json:"type"`
+	Listen      string      `json:"listen,omitempty"`
+	Target      string      `json:"target,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	BytesIn     int64       `json:"bytesIn"`
+	BytesOut    int64       `json:"bytesOut"`
+	Connections int         `json:"connections"`
+	Total       int64       `json:"total"`
+}
+
+func (f *Forward) Stats() ForwardStats {
+	f.mu.Lock()
+	active := len(f.conns)
+	f.mu.Unlock()
+	return ForwardStats{
+		ID:          f.ID,
+		Type:        f.Type,
+		Listen:      f.Listen,
+		Target:      f.Target,
+		CreatedAt:   f.CreatedAt,
+		BytesIn:     atomic.LoadInt64(&f.bytesIn),
+		BytesOut:    atomic.LoadInt64(&f.bytesOut),
+		Connections: active,
+		Total:       atomic.LoadInt64(&f.total),
+	}
+}
+
+func (f *Forward) BytesIn() int64 {
+	return atomic.LoadInt64(&f.bytesIn)
+}
+
+func (f *Forward) BytesOut() int64 {
+	return atomic.LoadInt64(&f.bytesOut)
+}
+
+// Done is closed when the forward is closed
+func (f *Forward) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Forward) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.done)
+		if f.listener != nil {
+			err = f.listener.Close()
+		}
+		f.mu.Lock()
+		for conn := range f.conns {
+			conn.Close()
+		}
+		f.mu.Unlock()
+		if f.onClose != nil {
+			f.onClose()
+		}
+	})
+	return err
+}
+
+func (f *Forward) track(conn net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return false
+	default:
+	}
+	f.conns[conn] = struct{}{}
+	atomic.AddInt64(&f.total, 1)
+	return true
+}
+
+func (f *Forward) untrack(conn net.Conn) {
+	f.mu.Lock()
+	delete(f.conns, conn)
+	f.mu.Unlock()
+	conn.Close()
+}
+
+// pipe copies data between the client side and the target side until one of them is closed
+func (f *Forward) pipe(client net.Conn, target net.Conn) {
+	if !f.track(client) {
+		client.Close()
+		target.Close()
+		return
+	}
+	if !f.track(target) {
+		f.untrack(client)
+		target.Close()
+		return
+	}
+	defer func() {
+		f.untrack(client)
+		f.untrack(target)
+	}()
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(target, &countReader{r: client, n: &f.bytesOut})
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(client, &countReader{r: target, n: &f.bytesIn})
+		errc <- err
+	}()
+	<-errc
+}
+
+func (f *Forward) serve(dial func(client net.Conn)) {
+	defer f.Close()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go dial(conn)
+	}
+}
+
+type countReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		atomic.AddInt64(c.n, int64(n))
+	}
+	return n, err
+}
+
+func (s *SSH) addForward(f *Forward) {
+	s.forwardMu.Lock()
+	if s.forwards == nil {
+		s.forwards = map[string]*Forward{}
+	}
+	s.forwards[f.ID] = f
+	s.forwardMu.Unlock()
+	f.onClose = func() {
+		s.forwardMu.Lock()
+		delete(s.forwards, f.ID)
+		s.forwardMu.Unlock()
+	}
+}
+
+// Forwards returns all the active forwards
+func (s *SSH) Forwards() []*Forward {
+	s.forwardMu.Lock()
+	defer s.forwardMu.Unlock()
+	r := make([]*Forward, 0, len(s.forwards))
+	for _, f := range s.forwards {
+		r = append(r, f)
+	}
+	return r
+}
+
+func (s *SSH) Forward(id string) (*Forward, bool) {
+	s.forwardMu.Lock()
+	f, ok := s.forwards[id]
+	s.forwardMu.Unlock()
+	return f, ok
+}
+
+func (s *SSH) CloseForward(id string) error {
+	f, ok := s.Forward(id)
+	if !ok {
+		return fmt.Errorf("forward %q is not found", id)
+	}
+	return f.Close()
+}
+
+func (s *SSH) closeForwards() {
+	for _, f := range s.Forwards() {
+		f.Close()
+	}
+}
+
+// CheckForward checks the destination by the forward policy of config and CheckTarget
+func (s *SSH) CheckForward(address string) error {
+	if err := s.Config.ForwardPolicy().Check(address); err != nil {
+		return err
+	}
+	if s.CheckTarget != nil {
+		return s.CheckTarget(address)
+	}
+	return nil
+}
+
+// DialForward dials the destination from the ssh host after checking the forward policy
+func (s *SSH) DialForward(address string) (net.Conn, error) {
+	if err := s.CheckForward(address); err != nil {
+		return nil, err
+	}
+	if s.Client == nil {
+		return nil, errors.New("ssh client is not connected")
+	}
+	return s.Client.Dial("tcp", address)
+}
+
+// RemoteForward listens on the ssh host (remoteListen) and forwards every
+// connection to target, which is dialed from web-terminal server
+func (s *SSH) RemoteForward(remoteListen string, target string) (*Forward, error) {
+	if err := s.CheckForward(target); err != nil {
+		return nil, err
+	}
+	if s.Client == nil {
+		return nil, errors.New("ssh client is not connected")
+	}
+	listener, err := s.Client.Listen("tcp", remoteListen)
+	if err != nil {
+		return nil, fmt.Errorf("remote listen on %q failed: %w", remoteListen, err)
+	}
+	f := newForward(ForwardRemote, listener.Addr().String(), target)
+	f.listener = listener
+	s.addForward(f)
+	go f.serve(func(conn net.Conn) {
+		targetConn, err := net.DialTimeout("tcp", target, defaultTimeout)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		f.pipe(conn, targetConn)
+	})
+	return f, nil
+}
+
+// DynamicForward starts a SOCKS5 listener on web-terminal server (listen),
+// the destinations are dialed from the ssh host and checked by the forward policy.
+// The listener has no authentication, it must be on a loopback address unless the policy allows PublicListen.
+func (s *SSH) DynamicForward(listen string) (*Forward, error) {
+	if s.Client == nil {
+		return nil, errors.New("ssh client is not connected")
+	}
+	if len(listen) == 0 {
+		listen = "127.0.0.1:0"
+	}
+	if err := s.Config.ForwardPolicy().CheckListen(listen); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %q failed: %w", listen, err)
+	}
+	f := newForward(ForwardDynamic, listener.Addr().String(), ``)
+	f.listener = listener
+	s.addForward(f)
+	go f.serve(func(conn net.Conn) {
+		target, err := socks5Handshake(conn, s.CheckForward, func(address string) (net.Conn, error) {
+			return s.Client.Dial("tcp", address)
+		})
+		if err != nil {
+			conn.Close()
+			return
+		}
+		f.pipe(conn, target)
+	})
+	return f, nil
+}
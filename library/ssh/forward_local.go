@@ -0,0 +1,237 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/admpub/errors"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/admpub/websocket"
+)
+
+// ForwardMessage is the control message of the forward multiplexer, see ServeForward.
+// The data of every local stream is sent in binary frames: 4 bytes stream id (big endian) + payload.
+type ForwardMessage struct {
+	Version  int                `json:"v,omitempty"`
+	Type     ForwardMessageType `json:"type"`
+	Stream   uint32             `json:"stream,omitempty"`
+	ID       string             `json:"id,omitempty"`     // the forward of remote, dynamic, started and stop
+	Listen   string             `json:"listen,omitempty"` // remote: on the ssh host, dynamic: on web-terminal server
+	Target   string             `json:"target,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Stats    *ForwardStats      `json:"stats,omitempty"`
+	Forwards []ForwardStats     `json:"forwards,omitempty"`
+}
+
+type ForwardMessageType string
+
+const (
+	ForwardMessageOpen   ForwardMessageType = "open"
+	ForwardMessageOpened ForwardMessageType = "opened"
+	ForwardMessageClose  ForwardMessageType = "close"
+	ForwardMessageError  ForwardMessageType = "error"
+	// ForwardMessageStats is answered with the stats of the local forward and the list of all the forwards
+	ForwardMessageStats ForwardMessageType = "stats"
+	// ForwardMessageRemote and ForwardMessageDynamic start RemoteForward and DynamicForward, they are answered by ForwardMessageStarted
+	ForwardMessageRemote  ForwardMessageType = "remote"
+	ForwardMessageDynamic ForwardMessageType = "dynamic"
+	ForwardMessageStarted ForwardMessageType = "started"
+	// ForwardMessageStop closes the forward of the id, it is answered by ForwardMessageStopped
+	ForwardMessageStop    ForwardMessageType = "stop"
+	ForwardMessageStopped ForwardMessageType = "stopped"
+)
+
+// LocalForward creates a local forward (-L), the streams are opened by ForwardMessageOpen messages
+func (s *SSH) LocalForward() *Forward {
+	f := newForward(ForwardLocal, ``, ``)
+	s.addForward(f)
+	return f
+}
+
+// ServeLocalForward upgrades the request and serves the forwards over the websocket, see ServeForward
+func (s *SSH) ServeLocalForward(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocketx.Upgrade(w, req)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	if err = s.ServeForward(conn); err != nil {
+		log.Println(err)
+	}
+}
+
+// ServeForward multiplexes the streams of a local forward over the websocket and starts or stops
+// the remote and dynamic forwards asked by the messages. The local forward is closed when the websocket ends,
+// the remote and dynamic forwards are closed by ForwardMessageStop or with the ssh client.
+func (s *SSH) ServeForward(conn *websocketx.Conn) error {
+	f := s.LocalForward()
+	defer f.Close()
+	mux := &forwardMux{ssh: s, forward: f, conn: conn, streams: map[uint32]net.Conn{}}
+	for {
+		msg, err := conn.Receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err = mux.recv(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type forwardMux struct {
+	ssh     *SSH
+	forward *Forward
+	conn    *websocketx.Conn
+	mu      sync.Mutex
+	streams map[uint32]net.Conn
+}
+
+func (m *forwardMux) writeJSON(msg *ForwardMessage) error {
+	msg.Version = websocketx.ProtocolVersion
+	return m.conn.WriteJSON(msg)
+}
+
+func (m *forwardMux) writeData(stream uint32, data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, stream)
+	copy(frame[4:], data)
+	return m.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (m *forwardMux) stream(id uint32) (net.Conn, bool) {
+	m.mu.Lock()
+	conn, ok := m.streams[id]
+	m.mu.Unlock()
+	return conn, ok
+}
+
+// recv handles a message, the binary frames are the stdin messages
+func (m *forwardMux) recv(msg *websocketx.Message) error {
+	select {
+	case <-m.forward.Done():
+		return ErrForwardClosed
+	default:
+	}
+	if msg.Type == websocketx.MessageTypeStdin {
+		data := msg.Data
+		if len(data) < 4 {
+			return errors.New("invalid forward data frame")
+		}
+		id := binary.BigEndian.Uint32(data)
+		conn, ok := m.stream(id)
+		if !ok {
+			return m.writeJSON(&ForwardMessage{Type: ForwardMessageClose, Stream: id, Error: "stream is not opened"})
+		}
+		n, err := conn.Write(data[4:])
+		atomic.AddInt64(&m.forward.bytesOut, int64(n))
+		if err != nil {
+			m.closeStream(id)
+			return m.writeJSON(&ForwardMessage{Type: ForwardMessageClose, Stream: id, Error: err.Error()})
+		}
+		return nil
+	}
+	var req ForwardMessage
+	if err := json.Unmarshal(msg.Raw, &req); err != nil {
+		return errors.Wrap(err, "error format forward message")
+	}
+	switch req.Type {
+	case ForwardMessageOpen:
+		go m.open(req.Stream, req.Target)
+	case ForwardMessageClose:
+		m.closeStream(req.Stream)
+	case ForwardMessageStats:
+		stats := m.forward.Stats()
+		forwards := m.ssh.Forwards()
+		list := make([]ForwardStats, 0, len(forwards))
+		for _, f := range forwards {
+			list = append(list, f.Stats())
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+		return m.writeJSON(&ForwardMessage{Type: ForwardMessageStats, Stats: &stats, Forwards: list})
+	case ForwardMessageRemote, ForwardMessageDynamic:
+		var (
+			f   *Forward
+			err error
+		)
+		if req.Type == ForwardMessageRemote {
+			f, err = m.ssh.RemoteForward(req.Listen, req.Target)
+		} else {
+			f, err = m.ssh.DynamicForward(req.Listen)
+		}
+		if err != nil {
+			return m.writeJSON(&ForwardMessage{Type: ForwardMessageError, Listen: req.Listen, Target: req.Target, Error: err.Error()})
+		}
+		return m.writeJSON(&ForwardMessage{Type: ForwardMessageStarted, ID: f.ID, Listen: f.Listen, Target: f.Target})
+	case ForwardMessageStop:
+		f, ok := m.ssh.Forward(req.ID)
+		if !ok || f.Type == ForwardLocal {
+			return m.writeJSON(&ForwardMessage{Type: ForwardMessageError, ID: req.ID, Error: "forward is not found"})
+		}
+		f.Close()
+		return m.writeJSON(&ForwardMessage{Type: ForwardMessageStopped, ID: req.ID})
+	}
+	return nil
+}
+
+func (m *forwardMux) open(id uint32, target string) {
+	if _, ok := m.stream(id); ok {
+		m.writeJSON(&ForwardMessage{Type: ForwardMessageError, Stream: id, Target: target, Error: "stream is already opened"})
+		return
+	}
+	conn, err := m.ssh.DialForward(target)
+	if err != nil {
+		m.writeJSON(&ForwardMessage{Type: ForwardMessageError, Stream: id, Target: target, Error: err.Error()})
+		return
+	}
+	if !m.forward.track(conn) {
+		conn.Close()
+		return
+	}
+	m.mu.Lock()
+	m.streams[id] = conn
+	m.mu.Unlock()
+	if err = m.writeJSON(&ForwardMessage{Type: ForwardMessageOpened, Stream: id, Target: target}); err != nil {
+		m.closeStream(id)
+		return
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			atomic.AddInt64(&m.forward.bytesIn, int64(n))
+			if werr := m.writeData(id, buf[:n]); werr != nil {
+				m.closeStream(id)
+				return
+			}
+		}
+		if err != nil {
+			if m.closeStream(id) {
+				m.writeJSON(&ForwardMessage{Type: ForwardMessageClose, Stream: id})
+			}
+			return
+		}
+	}
+}
+
+// closeStream returns false if the stream was already closed
+func (m *forwardMux) closeStream(id uint32) bool {
+	m.mu.Lock()
+	conn, ok := m.streams[id]
+	delete(m.streams, id)
+	m.mu.Unlock()
+	if ok {
+		m.forward.untrack(conn)
+	}
+	return ok
+}
@@ -0,0 +1,544 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"github.com/admpub/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+// startForwardTestServer accepts one connection like startTestServer, the direct-tcpip channels are
+// dialed and the tcpip-forward requests are served. The returned channel is closed when the connection ends.
+func startForwardTestServer(t *testing.T, serverConfig *ssh.ServerConfig) (*config.HostConfig, <-chan struct{}) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go serveTestRequests(conn, reqs)
+		for ch := range chans {
+			if ch.ChannelType() != "direct-tcpip" {
+				ch.Reject(ssh.Prohibited, "")
+				continue
+			}
+			go serveTestDirectTCPIP(ch)
+		}
+		conn.Wait()
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return config.NewHostConfig(&ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}, host, portN), done
+}
+
+// serveTestDirectTCPIP dials the destination of the direct-tcpip channel (ssh -W, ssh -L)
+func serveTestDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(ch, target)
+		ch.Close()
+	}()
+	io.Copy(target, ch)
+	target.Close()
+}
+
+// serveTestRequests answers the global requests, tcpip-forward (ssh -R) listens on the server
+func serveTestRequests(conn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	var mu sync.Mutex
+	listeners := map[string]net.Listener{}
+	defer func() {
+		mu.Lock()
+		for _, l := range listeners {
+			l.Close()
+		}
+		mu.Unlock()
+	}()
+	for req := range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		switch req.Type {
+		case "tcpip-forward":
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			l, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			port := uint32(l.Addr().(*net.TCPAddr).Port)
+			mu.Lock()
+			listeners[net.JoinHostPort(payload.Addr, strconv.Itoa(int(port)))] = l
+			mu.Unlock()
+			req.Reply(true, ssh.Marshal(&struct{ Port uint32 }{port}))
+			go func(addr string) {
+				for {
+					c, err := l.Accept()
+					if err != nil {
+						return
+					}
+					origin := c.RemoteAddr().(*net.TCPAddr)
+					ch, reqs, err := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(&struct {
+						Addr       string
+						Port       uint32
+						OriginAddr string
+						OriginPort uint32
+					}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+					if err != nil {
+						c.Close()
+						continue
+					}
+					go ssh.DiscardRequests(reqs)
+					go func() {
+						io.Copy(ch, c)
+						ch.CloseWrite()
+					}()
+					go func() {
+						io.Copy(c, ch)
+						c.Close()
+					}()
+				}
+			}(payload.Addr)
+		case "cancel-tcpip-forward":
+			ssh.Unmarshal(req.Payload, &payload)
+			key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+			mu.Lock()
+			l, ok := listeners[key]
+			delete(listeners, key)
+			mu.Unlock()
+			if ok {
+				l.Close()
+			}
+			req.Reply(ok, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// startEchoServer echoes every connection
+func startEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// connectTestSSH connects to a test server, the forwards to the policy rules are allowed
+func connectTestSSH(t *testing.T, allow ...string) *SSH {
+	hostConfig, _ := startForwardTestServer(t, &ssh.ServerConfig{NoClientAuth: true})
+	s := New(config.NewSSHConfig(hostConfig).SetForwardPolicy(config.NewForwardPolicy(allow...)))
+	client, _, err := buildClient(context.Background(), s.Config, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = client
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// echo writes the message to conn and expects it back
+func echo(t *testing.T, conn net.Conn, message string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, message); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(message))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != message {
+		t.Fatalf("got %q, want %q", got, message)
+	}
+}
+
+// waitFor polls the condition for a while
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// tcpPipe returns the both ends of a loopback connection, net.Pipe is unbuffered
+func tcpPipe(t *testing.T) (client net.Conn, server net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if client, err = net.Dial("tcp", l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if server, err = l.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// socks5Connect sends the greeting and the CONNECT request of the domain and returns the reply code
+func socks5Connect(t *testing.T, conn net.Conn, host string, port int) byte {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request := []byte{socks5Version, 1, socks5AuthNone, socks5Version, socks5CmdConnect, 0, socks5AddrDomain, byte(len(host))}
+	request = append(request, host...)
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 2+10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reply[:2], []byte{socks5Version, socks5AuthNone}) {
+		t.Fatalf("unexpected method selection %v", reply[:2])
+	}
+	conn.SetDeadline(time.Time{})
+	return reply[3]
+}
+
+func TestSocks5Handshake(t *testing.T) {
+	target, _ := net.Pipe()
+	check := func(address string) error {
+		if address != "allowed.example:80" {
+			return ErrForwardClosed
+		}
+		return nil
+	}
+	var dialed string
+	dial := func(address string) (net.Conn, error) {
+		dialed = address
+		return target, nil
+	}
+	for _, c := range []struct {
+		host  string
+		reply byte
+	}{
+		{"allowed.example", socks5ReplySucceeded},
+		{"denied.example", socks5ReplyNotAllowed},
+	} {
+		client, server := tcpPipe(t)
+		errc := make(chan error, 1)
+		go func() {
+			_, err := socks5Handshake(server, check, dial)
+			errc <- err
+		}()
+		if reply := socks5Connect(t, client, c.host, 80); reply != c.reply {
+			t.Fatalf("%s: reply %d, want %d", c.host, reply, c.reply)
+		}
+		if err := <-errc; (err == nil) != (c.reply == socks5ReplySucceeded) {
+			t.Fatalf("%s: unexpected error %v", c.host, err)
+		}
+		client.Close()
+	}
+	if dialed != "allowed.example:80" {
+		t.Fatalf("the denied destination is dialed: %q", dialed)
+	}
+
+	// only the username/password method is offered
+	client, server := tcpPipe(t)
+	client.Write([]byte{socks5Version, 1, 2})
+	if _, err := socks5Handshake(server, check, dial); err == nil {
+		t.Fatal("no acceptable method must fail")
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(client, reply); err != nil || reply[1] != socks5AuthNoAcceptable {
+		t.Fatalf("unexpected method selection %v, %v", reply, err)
+	}
+}
+
+func TestDynamicForward(t *testing.T) {
+	echoAddress := startEchoServer(t)
+	echoHost, echoPort, _ := net.SplitHostPort(echoAddress)
+	port, _ := strconv.Atoi(echoPort)
+	s := connectTestSSH(t, echoAddress)
+
+	if _, err := s.DynamicForward("0.0.0.0:0"); err == nil {
+		t.Fatal("the SOCKS5 proxy must not listen on a public address")
+	}
+	f, err := s.DynamicForward("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", f.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if reply := socks5Connect(t, conn, echoHost, port); reply != socks5ReplySucceeded {
+		t.Fatalf("reply %d", reply)
+	}
+	echo(t, conn, "hello")
+	waitFor(t, "the byte counters", func() bool { return f.BytesIn() == 5 && f.BytesOut() == 5 })
+	if stats := f.Stats(); stats.Connections != 2 || stats.Total != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	denied, err := net.Dial("tcp", f.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer denied.Close()
+	if reply := socks5Connect(t, denied, echoHost, port+1); reply != socks5ReplyNotAllowed {
+		t.Fatalf("reply %d", reply)
+	}
+
+	// closing the forward closes the listener and the connections
+	if _, ok := s.Forward(f.ID); !ok {
+		t.Fatal("the forward is not listed")
+	}
+	f.Close()
+	<-f.Done()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("the connection is not closed")
+	}
+	if _, err = net.Dial("tcp", f.Listen); err == nil {
+		t.Fatal("the listener is not closed")
+	}
+	if _, ok := s.Forward(f.ID); ok {
+		t.Fatal("the closed forward is listed")
+	}
+}
+
+func TestRemoteForward(t *testing.T) {
+	echoAddress := startEchoServer(t)
+	s := connectTestSSH(t, echoAddress)
+
+	if _, err := s.RemoteForward("127.0.0.1:0", "127.0.0.1:1"); err == nil {
+		t.Fatal("the target must be checked by the policy")
+	}
+	f, err := s.RemoteForward("127.0.0.1:0", echoAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", f.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "ping")
+	waitFor(t, "the byte counters", func() bool { return f.BytesIn() == 4 && f.BytesOut() == 4 })
+
+	if err = s.CloseForward(f.ID); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("the connection is not closed")
+	}
+	if len(s.Forwards()) != 0 {
+		t.Fatal("the closed forward is listed")
+	}
+}
+
+func TestLocalForwardMux(t *testing.T) {
+	echoAddress := startEchoServer(t)
+	s := connectTestSSH(t, echoAddress)
+	server := httptest.NewServer(http.HandlerFunc(s.ServeLocalForward))
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	expect := func(want ForwardMessage) {
+		t.Helper()
+		var msg ForwardMessage
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != want.Type || msg.Stream != want.Stream || (len(want.Error) > 0) != (len(msg.Error) > 0) {
+			t.Fatalf("got %+v, want %+v", msg, want)
+		}
+	}
+	frame := func(stream uint32, data string) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, stream), data...)
+	}
+
+	ws.WriteJSON(&ForwardMessage{Type: ForwardMessageOpen, Stream: 1, Target: "127.0.0.1:1"})
+	expect(ForwardMessage{Type: ForwardMessageError, Stream: 1, Error: "not allowed"})
+
+	ws.WriteJSON(&ForwardMessage{Type: ForwardMessageOpen, Stream: 7, Target: echoAddress})
+	expect(ForwardMessage{Type: ForwardMessageOpened, Stream: 7})
+	ws.WriteMessage(websocket.BinaryMessage, frame(7, "hello"))
+	messageType, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != websocket.BinaryMessage || !bytes.Equal(data, frame(7, "hello")) {
+		t.Fatalf("unexpected frame %d %q", messageType, data)
+	}
+
+	ws.WriteJSON(&ForwardMessage{Type: ForwardMessageStats})
+	var msg ForwardMessage
+	if err = ws.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Stats == nil || msg.Stats.BytesIn != 5 || msg.Stats.BytesOut != 5 || msg.Stats.Connections != 1 {
+		t.Fatalf("unexpected stats %+v", msg.Stats)
+	}
+
+	// the data of the closed stream is answered by close
+	ws.WriteJSON(&ForwardMessage{Type: ForwardMessageClose, Stream: 7})
+	ws.WriteMessage(websocket.BinaryMessage, frame(7, "late"))
+	expect(ForwardMessage{Type: ForwardMessageClose, Stream: 7, Error: "stream is not opened"})
+
+	// the forward is closed with the websocket
+	ws.Close()
+	waitFor(t, "the forward to close", func() bool { return len(s.Forwards()) == 0 })
+}
+
+func TestForwardMuxRemoteAndDynamic(t *testing.T) {
+	echoAddress := startEchoServer(t)
+	s := connectTestSSH(t, echoAddress, "127.0.0.1:1")
+	s.CheckTarget = func(address string) error {
+		if address == "127.0.0.1:1" {
+			return errors.New("denied by the access policy")
+		}
+		return nil
+	}
+	server := httptest.NewServer(http.HandlerFunc(s.ServeLocalForward))
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	request := func(req *ForwardMessage) *ForwardMessage {
+		t.Helper()
+		ws.WriteJSON(req)
+		msg := &ForwardMessage{}
+		if err := ws.ReadJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	// the targets allowed by the forward policy are checked by CheckTarget too
+	if msg := request(&ForwardMessage{Type: ForwardMessageRemote, Listen: "127.0.0.1:0", Target: "127.0.0.1:1"}); msg.Type != ForwardMessageError || !strings.Contains(msg.Error, "access policy") {
+		t.Fatalf("unexpected answer %+v", msg)
+	}
+	remote := request(&ForwardMessage{Type: ForwardMessageRemote, Listen: "127.0.0.1:0", Target: echoAddress})
+	if remote.Type != ForwardMessageStarted || len(remote.ID) == 0 || remote.Target != echoAddress {
+		t.Fatalf("unexpected answer %+v", remote)
+	}
+	conn, err := net.Dial("tcp", remote.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "ping")
+
+	if msg := request(&ForwardMessage{Type: ForwardMessageDynamic, Listen: "0.0.0.0:0"}); msg.Type != ForwardMessageError {
+		t.Fatalf("the public listener must be refused: %+v", msg)
+	}
+	dynamic := request(&ForwardMessage{Type: ForwardMessageDynamic})
+	if dynamic.Type != ForwardMessageStarted || !strings.HasPrefix(dynamic.Listen, "127.0.0.1:") {
+		t.Fatalf("unexpected answer %+v", dynamic)
+	}
+
+	stats := request(&ForwardMessage{Type: ForwardMessageStats})
+	if stats.Stats == nil || len(stats.Forwards) != 3 || stats.Forwards[1].ID != remote.ID || stats.Forwards[2].ID != dynamic.ID {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	// the local forward of the websocket can not be stopped
+	if msg := request(&ForwardMessage{Type: ForwardMessageStop, ID: stats.Stats.ID}); msg.Type != ForwardMessageError {
+		t.Fatalf("unexpected answer %+v", msg)
+	}
+	if msg := request(&ForwardMessage{Type: ForwardMessageStop, ID: remote.ID}); msg.Type != ForwardMessageStopped || msg.ID != remote.ID {
+		t.Fatalf("unexpected answer %+v", msg)
+	}
+	if _, ok := s.Forward(remote.ID); ok {
+		t.Fatal("the stopped forward is listed")
+	}
+}
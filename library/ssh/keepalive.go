@@ -0,0 +1,84 @@
+package ssh
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionLostError is reported when the host does not answer the keepalive requests
+type ConnectionLostError struct {
+	Host   string
+	Missed int
+}
+
+func (e *ConnectionLostError) Error() string {
+	return fmt.Sprintf("connection to %s lost: no reply to %d keepalive requests", e.Host, e.Missed)
+}
+
+// keepAliveSettings returns the interval and the max missed count of the hop, the zero values use config.Default
+func keepAliveSettings(hop *config.HostConfig) (time.Duration, int) {
+	interval := hop.ServerAliveInterval
+	if interval == 0 {
+		interval = config.Default.SSHKeepAlive
+	}
+	countMax := hop.ServerAliveCountMax
+	if countMax <= 0 {
+		countMax = config.Default.SSHKeepAliveCountMax
+	}
+	if countMax <= 0 {
+		countMax = 3
+	}
+	return interval, countMax
+}
+
+// keepAlive sends keepalive@openssh.com requests until the client is closed.
+// A request without a reply in the interval is missed, the client is closed after countMax missed requests in a row.
+func keepAlive(client *ssh.Client, host string, interval time.Duration, countMax int, onLost func(error)) {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	var reply chan error
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if reply != nil {
+			// the previous request is still waiting for the reply
+			select {
+			case err := <-reply:
+				reply = nil
+				if err != nil {
+					return
+				}
+				missed = 0
+			default:
+				missed++
+			}
+		}
+		if missed >= countMax {
+			if onLost != nil {
+				onLost(&ConnectionLostError{Host: host, Missed: missed})
+			}
+			client.Close()
+			return
+		}
+		if reply == nil {
+			reply = make(chan error, 1)
+			go func(reply chan<- error) {
+				// any reply, even a failure, proves the host is alive
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}(reply)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// startTestServer accepts one connection, the global requests are answered only if answer is true
+func startTestServer(t *testing.T, serverConfig *ssh.ServerConfig, answer bool) *config.HostConfig {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if answer {
+			go ssh.DiscardRequests(reqs)
+		}
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "")
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return config.NewHostConfig(&ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}, host, portN)
+}
+
+func TestKeepAliveConnectionLost(t *testing.T) {
+	hostConfig := startTestServer(t, &ssh.ServerConfig{NoClientAuth: true}, false)
+	hostConfig.ServerAliveInterval = 20 * time.Millisecond
+	hostConfig.ServerAliveCountMax = 2
+	lost := make(chan error, 1)
+	client, _, err := buildClient(context.Background(), config.NewSSHConfig(hostConfig), 0, func(err error) { lost <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	select {
+	case err = <-lost:
+		var lostErr *ConnectionLostError
+		if !errors.As(err, &lostErr) || lostErr.Missed != 2 {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection is not detected as lost")
+	}
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost connection must be closed")
+	}
+}
+
+func TestKeepAliveAnswered(t *testing.T) {
+	hostConfig := startTestServer(t, &ssh.ServerConfig{NoClientAuth: true}, true)
+	hostConfig.ServerAliveInterval = 20 * time.Millisecond
+	hostConfig.ServerAliveCountMax = 2
+	lost := make(chan error, 1)
+	client, _, err := buildClient(context.Background(), config.NewSSHConfig(hostConfig), 0, func(err error) { lost <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	select {
+	case err = <-lost:
+		t.Fatalf("the connection is alive: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,172 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+const (
+	msgKexInit = 20
+	// maxSniffSize stops sniffing if the KEXINIT is not found in the first bytes
+	maxSniffSize = 256 * 1024
+)
+
+// newKexSniffer records the first KEXINIT packet of both sides,
+// golang.org/x/crypto/ssh does not expose the negotiated algorithms
+func newKexSniffer(conn net.Conn) *kexSniffer {
+	return &kexSniffer{Conn: conn}
+}
+
+type kexSniffer struct {
+	net.Conn
+	mu        sync.Mutex
+	in, out   kexInitReader
+	sniffDone bool
+}
+
+func (s *kexSniffer) Read(b []byte) (int, error) {
+	n, err := s.Conn.Read(b)
+	if n > 0 {
+		s.mu.Lock()
+		if !s.sniffDone {
+			s.in.feed(b[:n])
+			s.sniffDone = s.in.done && s.out.done
+		}
+		s.mu.Unlock()
+	}
+	return n, err
+}
+
+func (s *kexSniffer) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	if !s.sniffDone {
+		s.out.feed(b)
+		s.sniffDone = s.in.done && s.out.done
+	}
+	s.mu.Unlock()
+	return s.Conn.Write(b)
+}
+
+// Negotiated returns nil if any KEXINIT is not seen
+func (s *kexSniffer) Negotiated() *config.NegotiatedAlgorithms {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, ok := parseKexInit(s.out.payload)
+	if !ok {
+		return nil
+	}
+	server, ok := parseKexInit(s.in.payload)
+	if !ok {
+		return nil
+	}
+	return negotiate(client, server)
+}
+
+// kexInitReader finds the first binary packet after the version line
+type kexInitReader struct {
+	buf         []byte
+	versionSeen bool
+	payload     []byte
+	done        bool
+}
+
+func (k *kexInitReader) feed(b []byte) {
+	if k.done {
+		return
+	}
+	k.buf = append(k.buf, b...)
+	// the server may send other lines before the version line
+	for !k.versionSeen {
+		i := bytes.IndexByte(k.buf, '\n')
+		if i < 0 {
+			break
+		}
+		k.versionSeen = bytes.HasPrefix(k.buf[:i], []byte("SSH-"))
+		k.buf = k.buf[i+1:]
+	}
+	if k.versionSeen && len(k.buf) >= 5 {
+		length := int(binary.BigEndian.Uint32(k.buf))
+		padding := int(k.buf[4])
+		if length > maxSniffSize || padding+1 > length {
+			k.stop()
+			return
+		}
+		if len(k.buf) >= 4+length {
+			k.payload = append([]byte{}, k.buf[5:4+length-padding]...)
+			k.stop()
+			return
+		}
+	}
+	if len(k.buf) > maxSniffSize {
+		k.stop()
+	}
+}
+
+func (k *kexInitReader) stop() {
+	k.done = true
+	k.buf = nil
+}
+
+// parseKexInit returns the 10 name-lists of the KEXINIT payload:
+// kex, host key, ciphers c2s, ciphers s2c, MACs c2s, MACs s2c, compression and languages
+func parseKexInit(payload []byte) ([][]string, bool) {
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return nil, false
+	}
+	data := payload[17:] // the message type and the cookie
+	lists := make([][]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		if len(data) < 4 {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint32(data))
+		if len(data) < 4+length {
+			return nil, false
+		}
+		var names []string
+		if length > 0 {
+			names = strings.Split(string(data[4:4+length]), ",")
+		}
+		lists = append(lists, names)
+		data = data[4+length:]
+	}
+	return lists, true
+}
+
+// negotiate chooses the first algorithm of the client supported by the server, see RFC 4253 section 7.1
+func negotiate(client, server [][]string) *config.NegotiatedAlgorithms {
+	r := &config.NegotiatedAlgorithms{
+		KeyExchange:  firstCommon(client[0], server[0]),
+		HostKey:      firstCommon(client[1], server[1]),
+		Cipher:       firstCommon(client[2], server[2]),
+		ServerCipher: firstCommon(client[3], server[3]),
+	}
+	if !isAEAD(r.Cipher) {
+		r.MAC = firstCommon(client[4], server[4])
+	}
+	if !isAEAD(r.ServerCipher) {
+		r.ServerMAC = firstCommon(client[5], server[5])
+	}
+	return r
+}
+
+func firstCommon(client, server []string) string {
+	for _, name := range client {
+		for _, v := range server {
+			if name == v {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// isAEAD reports whether the cipher has an implicit MAC
+func isAEAD(cipher string) bool {
+	return strings.Contains(cipher, "-gcm@") || strings.HasPrefix(cipher, "chacha20-poly1305")
+}
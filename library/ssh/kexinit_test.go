@@ -0,0 +1,34 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNegotiatedAlgorithms(t *testing.T) {
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.Ciphers = []string{"aes256-ctr", "aes128-ctr"}
+	serverConfig.MACs = []string{"hmac-sha1", "hmac-sha2-256"}
+	hostConfig := startTestServer(t, serverConfig, true)
+	legacy, _ := config.AlgorithmPreset(config.AlgorithmsLegacy)
+	hostConfig.SetAlgorithms(legacy)
+	client, negotiated, err := buildClient(context.Background(), config.NewSSHConfig(hostConfig), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	expected := config.NegotiatedAlgorithms{
+		KeyExchange:  "curve25519-sha256",
+		HostKey:      ssh.KeyAlgoED25519,
+		Cipher:       "aes128-ctr",
+		MAC:          "hmac-sha2-256",
+		ServerCipher: "aes128-ctr",
+		ServerMAC:    "hmac-sha2-256",
+	}
+	if negotiated == nil || *negotiated != expected {
+		t.Fatalf("expected %+v, got %+v", expected, negotiated)
+	}
+}
@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 (RFC 1928), only the CONNECT command without authentication is supported
+const (
+	socks5Version = 5
+
+	socks5AuthNone         = 0
+	socks5AuthNoAcceptable = 0xff
+
+	socks5CmdConnect = 1
+
+	socks5AddrIPv4   = 1
+	socks5AddrDomain = 3
+	socks5AddrIPv6   = 4
+
+	socks5ReplySucceeded           = 0
+	socks5ReplyGeneralFailure      = 1
+	socks5ReplyNotAllowed          = 2
+	socks5ReplyCmdNotSupported     = 7
+	socks5ReplyAddrTypeUnsupported = 8
+)
+
+func socks5Reply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socks5Version, rep, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// socks5Handshake negotiates with the SOCKS5 client and dials the requested destination
+func socks5Handshake(conn net.Conn, check func(address string) error, dial func(address string) (net.Conn, error)) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(defaultTimeout))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if header[0] != socks5Version {
+		return nil, fmt.Errorf("unsupported socks version: %d", header[0])
+	}
+	methods := make([]byte, int(header[1]))
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	method := byte(socks5AuthNoAcceptable)
+	for _, m := range methods {
+		if m == socks5AuthNone {
+			method = socks5AuthNone
+			break
+		}
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return nil, err
+	}
+	if method == socks5AuthNoAcceptable {
+		return nil, fmt.Errorf("no acceptable socks authentication method")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	if request[1] != socks5CmdConnect {
+		socks5Reply(conn, socks5ReplyCmdNotSupported)
+		return nil, fmt.Errorf("unsupported socks command: %d", request[1])
+	}
+	var host string
+	switch request[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		size := net.IPv4len
+		if request[3] == socks5AddrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return nil, err
+		}
+		domain := make([]byte, int(size[0]))
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, err
+		}
+		host = string(domain)
+	default:
+		socks5Reply(conn, socks5ReplyAddrTypeUnsupported)
+		return nil, fmt.Errorf("unsupported socks address type: %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	if err := check(address); err != nil {
+		socks5Reply(conn, socks5ReplyNotAllowed)
+		return nil, err
+	}
+	target, err := dial(address)
+	if err != nil {
+		socks5Reply(conn, socks5ReplyGeneralFailure)
+		return nil, err
+	}
+	if err = socks5Reply(conn, socks5ReplySucceeded); err != nil {
+		target.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return target, nil
+}
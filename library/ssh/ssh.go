@@ -3,16 +3,15 @@ package ssh
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/admpub/errors"
 	"github.com/admpub/web-terminal/config"
 	websocketx "github.com/admpub/web-terminal/library/websocket"
-	"github.com/admpub/websocket"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -32,25 +31,58 @@ type SSH struct {
 	Client  *ssh.Client
 	Session *ssh.Session
 	Timeout time.Duration
-	stdout  io.Reader
-	stderr  io.Reader
-	stdin   io.WriteCloser
+	// Negotiated are the algorithms negotiated with the end host by Connect
+	Negotiated *config.NegotiatedAlgorithms
+	// CheckTarget checks every forward destination after the forward policy, e.g. by the access policy, it may be nil
+	CheckTarget func(address string) error
+	stdout      io.Reader
+	stderr      io.Reader
+	stdin       io.WriteCloser
+
+	forwardMu sync.Mutex
+	forwards  map[string]*Forward
+
+	lostMu sync.Mutex
+	lost   error
 }
 
 func (s *SSH) Connect() (err error) {
-	s.Client, err = NewClient(context.Background(), s.Config, s.Timeout)
-	if err != nil {
+	if err = s.ConnectClient(); err != nil {
 		return
 	}
 
 	s.Session, err = s.Client.NewSession()
 	if err != nil {
 		err = fmt.Errorf("failed to create session: %w", err)
+		return
 	}
+	err = s.ForwardAgent()
+	return
+}
+
+// ConnectClient connects to the end host without opening a session, e.g. for the forwards
+func (s *SSH) ConnectClient() (err error) {
+	s.Client, s.Negotiated, err = buildClient(context.Background(), s.Config, s.Timeout, s.setLost)
 	return
 }
 
+func (s *SSH) setLost(err error) {
+	s.lostMu.Lock()
+	if s.lost == nil {
+		s.lost = err
+	}
+	s.lostMu.Unlock()
+}
+
+// ConnectionLost returns the *ConnectionLostError if a host stopped answering the keepalive requests
+func (s *SSH) ConnectionLost() error {
+	s.lostMu.Lock()
+	defer s.lostMu.Unlock()
+	return s.lost
+}
+
 func (s *SSH) Close() error {
+	s.closeForwards()
 	if s.Session != nil {
 		s.Session.Close()
 	}
@@ -63,26 +95,55 @@ func (s *SSH) Close() error {
 	return nil
 }
 
-func (s *SSH) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	err := websocketx.Connect(w, req,
-		func(conn websocketx.Writer) error {
-			return s.StartShell(conn, 80, 120)
-		},
-		s.HandleRecv,
-	)
+// Serve runs the shell for the websocket until the shell exits, the shell is closed if the websocket is closed first
+func (s *SSH) Serve(conn *websocketx.Conn) error {
+	if err := s.RequestShell(func() error {
+		return s.WithZModem(conn)
+	}, 80, 120); err != nil {
+		return err
+	}
+	go func() {
+		defer s.Session.Close()
+		for {
+			msg, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			if err = s.HandleMessage(conn, msg); err != nil {
+				return
+			}
+		}
+	}()
+	err := s.Session.Wait()
+	if lost := s.ConnectionLost(); lost != nil {
+		conn.Send(&Message{Type: MessageTypeConnectionLost, Data: []byte(lost.Error())})
+		return lost
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
+		err = fmt.Errorf("unable to execute command: %w", err)
 	}
+	return err
 }
 
-func (s *SSH) StartShell(conn websocketx.Writer, rows, columns int) error {
+func (s *SSH) StartShell(conn websocketx.Sender, rows, columns int) error {
 	return s.StartShellWithCallback(func() error {
 		return s.WithZModem(conn)
 	}, rows, columns)
 }
 
 func (s *SSH) StartShellWithCallback(onInit func() error, rows, columns int) error {
+	err := s.RequestShell(onInit, rows, columns)
+	if err != nil {
+		return err
+	}
+	if err = s.Session.Wait(); nil != err {
+		err = fmt.Errorf("unable to execute command: %w", err)
+	}
+	return err
+}
+
+// RequestShell starts the shell without waiting for it to exit, see Session.Wait
+func (s *SSH) RequestShell(onInit func() error, rows, columns int) error {
 	// Set up terminal modes
 	modes := ssh.TerminalModes{
 		ssh.ECHO:          1,     // enable echoing
@@ -102,13 +163,10 @@ func (s *SSH) StartShellWithCallback(onInit func() error, rows, columns int) err
 	if err = s.Session.Shell(); nil != err {
 		return fmt.Errorf("unable to execute command: %w", err)
 	}
-	if err = s.Session.Wait(); nil != err {
-		err = fmt.Errorf("unable to execute command: %w", err)
-	}
-	return err
+	return nil
 }
 
-func (s *SSH) WithZModem(conn websocketx.Writer) error {
+func (s *SSH) WithZModem(conn websocketx.Sender) error {
 	if s.Config.Transform == nil {
 		return errors.New(`config.Transform can't be nil`)
 	}
@@ -117,33 +175,27 @@ func (s *SSH) WithZModem(conn websocketx.Writer) error {
 	return err
 }
 
-func (s *SSH) HandleRecv(conn websocketx.Writer, msgType int, data []byte) error {
-	// BinaryMessage 是 zmodem 数据流，则直接发送给 ssh 服务端, 可以提高 rz 上传速率
-	if msgType == websocket.BinaryMessage {
-		_, err := s.stdin.Write(data)
-		if err != nil {
-			err = errors.Wrap(err, "write message to ssh error")
-		}
-		return err
-	}
-	var msg Message
-	err := json.Unmarshal(data, &msg)
-	if err != nil {
-		return errors.Wrap(err, "error format input message")
-	}
+// HandleMessage writes stdin to the shell, including the zmodem binary frames, resizes the terminal or sends the signal
+func (s *SSH) HandleMessage(conn websocketx.Sender, msg *Message) error {
+	var err error
 	switch msg.Type {
 	case MessageTypeStdin:
 		_, err = s.stdin.Write(msg.Data)
 		if err != nil {
-			_ = conn.WriteJSON(&Message{Type: MessageTypeStderr, Data: []byte("write to stdin error\r\n")})
+			_ = conn.Send(&Message{Type: MessageTypeStderr, Data: []byte("write to stdin error\r\n")})
 			err = errors.Wrap(err, "write to stdin error")
 		}
 	case MessageTypeResize:
 		err = s.Session.WindowChange(msg.Rows, msg.Cols)
 		if err != nil {
-			_ = conn.WriteJSON(&Message{Type: MessageTypeStderr, Data: []byte("resize error\r\n")})
+			_ = conn.Send(&Message{Type: MessageTypeStderr, Data: []byte("resize error\r\n")})
 			err = errors.Wrap(err, "resize error")
 		}
+	case MessageTypeSignal:
+		// most servers ignore the signal requests, it is not an error
+		if serr := s.Session.Signal(ssh.Signal(strings.TrimPrefix(strings.ToUpper(msg.Signal), "SIG"))); serr != nil {
+			_ = conn.Send(&Message{Type: MessageTypeStderr, Data: []byte("signal error\r\n")})
+		}
 	}
 	return err
 }
@@ -0,0 +1,136 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/web-terminal/config"
+	websocketx "github.com/admpub/web-terminal/library/websocket"
+	"github.com/admpub/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+// startShellTestServer accepts one connection, its shell echoes the lines until "exit"
+func startShellTestServer(t *testing.T) *config.HostConfig {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			if newChannel.ChannelType() != "session" {
+				newChannel.Reject(ssh.Prohibited, "")
+				continue
+			}
+			ch, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for req := range requests {
+					req.Reply(req.Type == "pty-req" || req.Type == "shell", nil)
+				}
+			}()
+			go func() {
+				defer ch.Close()
+				lines := bufio.NewScanner(ch)
+				for lines.Scan() {
+					if lines.Text() == "exit" {
+						break
+					}
+					ch.Write([]byte("echo: " + lines.Text() + "\r\n"))
+				}
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return config.NewHostConfig(&ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}, host, portN)
+}
+
+func TestServe(t *testing.T) {
+	hostConfig := startShellTestServer(t)
+	served := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			served <- err
+			return
+		}
+		defer conn.Close()
+		s := New(config.NewSSHConfig(hostConfig))
+		defer s.Close()
+		if err = s.Connect(); err != nil {
+			served <- err
+			return
+		}
+		served <- s.Serve(conn)
+	}))
+	defer server.Close()
+
+	dialer := &websocket.Dialer{Subprotocols: []string{websocketx.Subprotocol}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+	ws.WriteJSON(&Message{Type: MessageTypeStdin, Data: []byte("hello\n")})
+	var output string
+	for !strings.Contains(output, "echo: hello") {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for the echo: %v, output %q", err, output)
+		}
+		if msg.Version != websocketx.ProtocolVersion {
+			t.Fatalf("the message is not versioned: %+v", msg)
+		}
+		if msg.Type == MessageTypeStdout {
+			output += string(msg.Data)
+		}
+	}
+
+	ws.WriteJSON(&Message{Type: MessageTypeStdin, Data: []byte("exit\n")})
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("the shell exited with %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve does not return after the shell exits")
+	}
+}
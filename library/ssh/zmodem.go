@@ -69,7 +69,7 @@ var (
 )
 
 // 发送 ssh 会话的 stdout 和 stdin 数据到 websocket 连接
-func TransformChannel(session *ssh.Session, conn websocketx.Writer, cfg *config.TransformConfig) (stdout io.Reader, stderr io.Reader, stdin io.WriteCloser, err error) {
+func TransformChannel(session *ssh.Session, conn websocketx.Sender, cfg *config.TransformConfig) (stdout io.Reader, stderr io.Reader, stdin io.WriteCloser, err error) {
 	if cfg == nil {
 		err = errors.New(`config.TransformConfig can't be nil`)
 		return
@@ -110,27 +110,27 @@ func TransformChannel(session *ssh.Session, conn websocketx.Writer, cfg *config.
 	return
 }
 
-func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, conn websocketx.Writer, tcfg *config.TransformConfig) {
+func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, conn websocketx.Sender, tcfg *config.TransformConfig) {
 	cfg := tcfg.ZModemConfig()
 	if cfg.GetZModemSZOO() {
 		cfg.SetZModemSZOO(false)
 		if n < 2 {
-			conn.WriteJSON(&Message{Type: t, Data: buff[:n]})
+			conn.Send(&Message{Type: t, Data: buff[:n]})
 			return
 		}
 		if n == 2 {
 			if buff[0] == ZModemSZEndOO[0] && buff[1] == ZModemSZEndOO[1] {
 				conn.WriteMessage(websocket.BinaryMessage, buff[:n])
 			} else {
-				conn.WriteJSON(&Message{Type: t, Data: buff[:n]})
+				conn.Send(&Message{Type: t, Data: buff[:n]})
 			}
 			return
 		}
 		if buff[0] == ZModemSZEndOO[0] && buff[1] == ZModemSZEndOO[1] {
 			conn.WriteMessage(websocket.BinaryMessage, buff[:2])
-			conn.WriteJSON(&Message{Type: t, Data: buff[2:n]})
+			conn.Send(&Message{Type: t, Data: buff[2:n]})
 		} else {
-			conn.WriteJSON(&Message{Type: t, Data: buff[:n]})
+			conn.Send(&Message{Type: t, Data: buff[:n]})
 		}
 		return
 	}
@@ -146,7 +146,7 @@ func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, con
 			cfg.SetZModemSZOO(true)
 			conn.WriteMessage(websocket.BinaryMessage, ZModemSZEnd)
 			if len(x) != 0 {
-				conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+				conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 			}
 			return
 		}
@@ -163,7 +163,7 @@ func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, con
 			cfg.SetZModemRZ(false)
 			conn.WriteMessage(websocket.BinaryMessage, ZModemRZEnd)
 			if len(x) != 0 {
-				conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+				conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 			}
 			return
 		}
@@ -186,7 +186,7 @@ func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, con
 				conn.WriteMessage(websocket.BinaryMessage, ctrl)
 				info := append(buff[:startIndex], buff[endIndex+len(ZModemRZCtrlEnd1):n]...)
 				if len(info) != 0 {
-					conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: info})
+					conn.Send(&Message{Type: MessageTypeConsole, Data: info})
 				}
 				return
 			}
@@ -197,29 +197,29 @@ func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, con
 				conn.WriteMessage(websocket.BinaryMessage, ctrl)
 				info := append(buff[:startIndex], buff[endIndex+len(ZModemRZCtrlEnd2):n]...)
 				if len(info) != 0 {
-					conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: info})
+					conn.Send(&Message{Type: MessageTypeConsole, Data: info})
 				}
 				return
 			}
-			conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: buff[:n]})
+			conn.Send(&Message{Type: MessageTypeConsole, Data: buff[:n]})
 			return
 		}
-		conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: buff[:n]})
+		conn.Send(&Message{Type: MessageTypeConsole, Data: buff[:n]})
 		return
 	}
 	if x, ok := checkByteCommand(buff[:n], ZModemSZStart); ok {
 		if cfg.GetDisableZModemSZ() {
-			conn.WriteJSON(&Message{Type: MessageTypeAlert, Data: msgSZDisabled})
+			conn.Send(&Message{Type: MessageTypeAlert, Data: msgSZDisabled})
 			w.Write(ZModemCancel)
 			return
 		}
 		if y, ok := checkByteCommand(x, ZModemCancel); ok {
 			// 下载不存在的文件以及文件夹(zmodem 不支持下载文件夹)时
-			conn.WriteJSON(&Message{Type: t, Data: y})
+			conn.Send(&Message{Type: t, Data: y})
 		} else {
 			cfg.SetZModemSZ(true)
 			if len(x) != 0 {
-				conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+				conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 			}
 			conn.WriteMessage(websocket.BinaryMessage, ZModemSZStart)
 		}
@@ -227,57 +227,57 @@ func operateZModemBytes(n int, buff []byte, w io.WriteCloser, t MessageType, con
 	}
 	if x, ok := checkByteCommand(buff[:n], ZModemRZStart); ok {
 		if cfg.GetDisableZModemRZ() {
-			conn.WriteJSON(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
+			conn.Send(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
 			w.Write(ZModemCancel)
 			return
 		}
 		cfg.SetZModemRZ(true)
 		if len(x) != 0 {
-			conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+			conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 		}
 		conn.WriteMessage(websocket.BinaryMessage, ZModemRZStart)
 		return
 	}
 	if x, ok := checkByteCommand(buff[:n], ZModemRZEStart); ok {
 		if cfg.GetDisableZModemRZ() {
-			conn.WriteJSON(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
+			conn.Send(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
 			w.Write(ZModemCancel)
 			return
 		}
 		cfg.SetZModemRZ(true)
 		if len(x) != 0 {
-			conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+			conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 		}
 		conn.WriteMessage(websocket.BinaryMessage, ZModemRZEStart)
 		return
 	}
 	if x, ok := checkByteCommand(buff[:n], ZModemRZSStart); ok {
 		if cfg.GetDisableZModemRZ() {
-			conn.WriteJSON(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
+			conn.Send(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
 			w.Write(ZModemCancel)
 			return
 		}
 		cfg.SetZModemRZ(true)
 		if len(x) != 0 {
-			conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+			conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 		}
 		conn.WriteMessage(websocket.BinaryMessage, ZModemRZSStart)
 		return
 	}
 	if x, ok := checkByteCommand(buff[:n], ZModemRZESStart); ok {
 		if cfg.GetDisableZModemRZ() {
-			conn.WriteJSON(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
+			conn.Send(&Message{Type: MessageTypeAlert, Data: msgRZDisabled})
 			w.Write(ZModemCancel)
 			return
 		}
 		cfg.SetZModemRZ(true)
 		if len(x) != 0 {
-			conn.WriteJSON(&Message{Type: MessageTypeConsole, Data: x})
+			conn.Send(&Message{Type: MessageTypeConsole, Data: x})
 		}
 		conn.WriteMessage(websocket.BinaryMessage, ZModemRZESStart)
 		return
 	}
-	conn.WriteJSON(&Message{Type: t, Data: buff[:n]})
+	conn.Send(&Message{Type: t, Data: buff[:n]})
 }
 
 // checkByteCommand ...
@@ -291,20 +291,20 @@ func checkByteCommand(x, y []byte) (n []byte, contain bool) {
 	return n, true
 }
 
-type MessageType string
-
-type Message struct {
-	Type MessageType `json:"type"`
-	Data []byte      `json:"data"`
-	Cols int         `json:"cols,omitempty"`
-	Rows int         `json:"rows,omitempty"`
-}
+// the messages are defined by library/websocket, so that all handlers share them
+type (
+	MessageType = websocketx.MessageType
+	Message     = websocketx.Message
+)
 
 const (
-	MessageTypeStdin   MessageType = "stdin"
-	MessageTypeStdout  MessageType = "stdout"
-	MessageTypeStderr  MessageType = "stderr"
-	MessageTypeResize  MessageType = "resize"
-	MessageTypeConsole MessageType = "console"
-	MessageTypeAlert   MessageType = "alert"
+	MessageTypeStdin          = websocketx.MessageTypeStdin
+	MessageTypeStdout         = websocketx.MessageTypeStdout
+	MessageTypeStderr         = websocketx.MessageTypeStderr
+	MessageTypeResize         = websocketx.MessageTypeResize
+	MessageTypeConsole        = websocketx.MessageTypeConsole
+	MessageTypeAlert          = websocketx.MessageTypeAlert
+	MessageTypeConnectionLost = websocketx.MessageTypeConnectionLost
+	MessageTypeSignal         = websocketx.MessageTypeSignal
+	MessageTypeExit           = websocketx.MessageTypeExit
 )
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unicode"
@@ -86,12 +89,49 @@ const (
 	optWndSize = 31
 	// 32(0x20)   终端速率
 	optRate = 32
+	// 33(0x21)   远程流量控制
+	// 34(0x22)   行方式
+	optLinemode = 34
+	// 36(0x24)   环境变量
+	// 39(0x27)   新环境变量
+	optNewEnviron = 39
+)
+
+const (
+	// NEW-ENVIRON 子协商命令和类型 (RFC 1572)
+	envIS      = 0
+	envSend    = 1
+	envVar     = 0
+	envValue   = 1
+	envEsc     = 2
+	envUserVar = 3
+)
 
-// 33(0x21)   远程流量控制
-// 34(0x22)   行方式
-// 36(0x24)   环境变量
+const (
+	// LINEMODE 子协商命令 (RFC 1184)
+	lmMode        = 1
+	lmForwardMask = 2
+	lmSLC         = 3
+
+	LineModeEdit    = 1
+	LineModeTrapSig = 2
+	lineModeAck     = 4
+	LineModeSoftTab = 8
+	LineModeLitEcho = 16
+
+	// lineModeSupported is the modes the client agrees to, the browser terminal edits nothing locally
+	lineModeSupported = 0
+)
+
+const (
+	// TERMINAL-TYPE 子协商命令 (RFC 1091)
+	ttypeIS   = 0
+	ttypeSend = 1
 )
 
+// DefaultTerminalTypes are answered to TERMINAL-TYPE if SetTerminalTypes is not called
+var DefaultTerminalTypes = []string{"xterm"}
+
 // Conn implements net.Conn interface for Telnet protocol plus some set of
 // Telnet specific methods.
 type Conn struct {
@@ -105,7 +145,23 @@ type Conn struct {
 	cliSuppressGoAhead bool
 	cliEcho            bool
 
-	rows, columns byte
+	sizeMu        sync.Mutex
+	rows, columns uint16
+	nawsOffered   bool // WILL NAWS is sent
+	naws          bool // the server accepted NAWS by DO, the size changes are sent at once
+
+	ttypeMu        sync.Mutex
+	terminalTypes  []string
+	ttypeIndex     int    // the index of the next type to send
+	terminalType   string // the type sent last
+	onTerminalType func(name string)
+
+	optMu           sync.Mutex
+	environ         map[string]string
+	environEnabled  bool // WILL NEW-ENVIRON is sent
+	lineModeAllowed bool
+	lineModeEnabled bool // WILL LINEMODE is sent
+	lineMode        byte
 }
 
 func NewConn(conn net.Conn) (*Conn, error) {
@@ -201,12 +257,279 @@ func (c *Conn) wont(option byte) error {
 	return err
 }
 
-func (c *Conn) SetWindowSize(rows, columns byte) error {
+// SetWindowSize sets the window size reported by NAWS (RFC 1073), it may be called at any time.
+// WILL NAWS is offered by the first call, the later sizes are sent once the server accepts it by DO NAWS.
+func (c *Conn) SetWindowSize(rows, columns uint16) error {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
 	c.rows = rows
 	c.columns = columns
+	if c.naws {
+		return c.sendWindowSizeLocked()
+	}
+	if c.nawsOffered {
+		return nil
+	}
+	c.nawsOffered = true
 	return c.will(optWndSize)
 }
 
+// WindowSize returns the window size set by SetWindowSize
+func (c *Conn) WindowSize() (rows, columns uint16) {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+	return c.rows, c.columns
+}
+
+func (c *Conn) sendWindowSizeLocked() error {
+	_, err := c.Conn.Write(windowSizeCommand(c.rows, c.columns))
+	return err
+}
+
+// windowSizeCommand returns IAC SB NAWS WIDTH[1] WIDTH[0] HEIGHT[1] HEIGHT[0] IAC SE, the 255 bytes are doubled
+func windowSizeCommand(rows, columns uint16) []byte {
+	cmd := []byte{cmdIAC, cmdSB, optWndSize}
+	for _, b := range []byte{byte(columns >> 8), byte(columns), byte(rows >> 8), byte(rows)} {
+		cmd = append(cmd, b)
+		if b == cmdIAC {
+			cmd = append(cmd, cmdIAC)
+		}
+	}
+	return append(cmd, cmdIAC, cmdSE)
+}
+
+// SetTerminalTypes sets the terminal types offered by TERMINAL-TYPE in order of preference, e.g. "IBM-3278-2", "vt100".
+// The server asks for the next type by repeated SEND requests until it gets one it supports.
+func (c *Conn) SetTerminalTypes(types ...string) {
+	c.ttypeMu.Lock()
+	defer c.ttypeMu.Unlock()
+	c.terminalTypes = nil
+	for _, name := range types {
+		if isTerminalType(name) {
+			c.terminalTypes = append(c.terminalTypes, name)
+		}
+	}
+	c.ttypeIndex = 0
+}
+
+// isTerminalType reports whether the name is printable ASCII without spaces, the other names are ignored
+func isTerminalType(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
+// TerminalType returns the terminal type sent last, it is the negotiated type once the server stops asking.
+// It is empty if the server has not asked.
+func (c *Conn) TerminalType() string {
+	c.ttypeMu.Lock()
+	defer c.ttypeMu.Unlock()
+	return c.terminalType
+}
+
+// OnTerminalType sets the function called with every terminal type sent to the server
+func (c *Conn) OnTerminalType(fn func(name string)) {
+	c.ttypeMu.Lock()
+	defer c.ttypeMu.Unlock()
+	c.onTerminalType = fn
+}
+
+// nextTerminalType cycles through the terminal types as RFC 1091 describes:
+// the last type is sent twice to mark the end of the list, and the next SEND starts over from the first one
+func (c *Conn) nextTerminalType() (string, func(string)) {
+	c.ttypeMu.Lock()
+	defer c.ttypeMu.Unlock()
+	types := c.terminalTypes
+	if len(types) == 0 {
+		types = DefaultTerminalTypes
+	}
+	if len(types) == 0 {
+		types = []string{"UNKNOWN"}
+	}
+	i := c.ttypeIndex
+	if i >= len(types) {
+		i = len(types) - 1
+	}
+	c.ttypeIndex++
+	if c.ttypeIndex > len(types) {
+		c.ttypeIndex = 0
+	}
+	c.terminalType = types[i]
+	return c.terminalType, c.onTerminalType
+}
+
+// sendTerminalType answers the SEND request by IAC SB TERMINAL-TYPE IS <type> IAC SE
+func (c *Conn) sendTerminalType() error {
+	name, fn := c.nextTerminalType()
+	cmd := append([]byte{cmdIAC, cmdSB, optWndType, ttypeIS}, name...)
+	if _, err := c.Conn.Write(append(cmd, cmdIAC, cmdSE)); err != nil {
+		return err
+	}
+	if fn != nil {
+		fn(name)
+	}
+	return nil
+}
+
+// SetEnviron sets the variables sent by NEW-ENVIRON (RFC 1572) when the server asks for them.
+// USER, JOB, ACCT, PRINTER, SYSTEMTYPE and DISPLAY are sent as the well-known variables, the others as user variables.
+func (c *Conn) SetEnviron(env map[string]string) {
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	c.environ = make(map[string]string, len(env))
+	for name, value := range env {
+		c.environ[name] = value
+	}
+}
+
+var wellKnownVars = map[string]bool{"USER": true, "JOB": true, "ACCT": true, "PRINTER": true, "SYSTEMTYPE": true, "DISPLAY": true}
+
+// appendEnvString appends s with VAR, VALUE, ESC and USERVAR escaped by ESC and IAC doubled
+func appendEnvString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case envVar, envValue, envEsc, envUserVar:
+			b = append(b, envEsc)
+		case cmdIAC:
+			b = append(b, cmdIAC)
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+// environCommand answers the SEND request. A VAR or USERVAR without a name asks for all variables of the type,
+// an empty request asks for all variables. The requested variables which are not set are sent without a value.
+func (c *Conn) environCommand(request []byte) []byte {
+	type variable struct {
+		typ  byte
+		name string
+	}
+	var requested []variable
+	for i := 0; i < len(request); {
+		typ := request[i]
+		i++
+		var name []byte
+		for ; i < len(request) && request[i] != envVar && request[i] != envUserVar; i++ {
+			if request[i] == envEsc && i+1 < len(request) {
+				i++
+			}
+			name = append(name, request[i])
+		}
+		requested = append(requested, variable{typ: typ, name: string(name)})
+	}
+	if len(requested) == 0 {
+		requested = []variable{{typ: envVar}, {typ: envUserVar}}
+	}
+
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	names := make([]string, 0, len(c.environ))
+	for name := range c.environ {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd := []byte{cmdIAC, cmdSB, optNewEnviron, envIS}
+	for _, v := range requested {
+		if len(v.name) > 0 {
+			cmd = appendEnvString(append(cmd, v.typ), v.name)
+			if value, ok := c.environ[v.name]; ok {
+				cmd = appendEnvString(append(cmd, envValue), value)
+			}
+			continue
+		}
+		for _, name := range names {
+			if wellKnownVars[name] == (v.typ == envVar) {
+				cmd = appendEnvString(append(cmd, v.typ), name)
+				cmd = appendEnvString(append(cmd, envValue), c.environ[name])
+			}
+		}
+	}
+	return append(cmd, cmdIAC, cmdSE)
+}
+
+// SetLineMode allows LINEMODE (RFC 1184), it is refused by default.
+// The browser terminal edits nothing locally, so the client only agrees to the character at a time mode.
+func (c *Conn) SetLineMode(allowed bool) {
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	c.lineModeAllowed = allowed
+}
+
+// LineMode returns whether LINEMODE is accepted and the agreed modes, see LineModeEdit
+func (c *Conn) LineMode() (enabled bool, mode byte) {
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	return c.lineModeEnabled, c.lineMode
+}
+
+// lineModeCommand returns the answer to the LINEMODE subnegotiation, nil if none is needed
+func (c *Conn) lineModeCommand(data []byte) []byte {
+	if len(data) < 2 {
+		return nil
+	}
+	c.optMu.Lock()
+	defer c.optMu.Unlock()
+	switch data[0] {
+	case lmMode:
+		mask := data[1]
+		if mask&lineModeAck != 0 {
+			// the server acknowledged the mode proposed by the client
+			c.lineMode = mask &^ lineModeAck
+			return nil
+		}
+		agreed := mask & lineModeSupported
+		c.lineMode = agreed
+		if agreed == mask {
+			agreed |= lineModeAck
+		}
+		return []byte{cmdIAC, cmdSB, optLinemode, lmMode, agreed, cmdIAC, cmdSE}
+	case cmdDo:
+		if data[1] == lmForwardMask {
+			return []byte{cmdIAC, cmdSB, optLinemode, cmdWont, lmForwardMask, cmdIAC, cmdSE}
+		}
+	}
+	// the special characters (SLC) are left to the server
+	return nil
+}
+
+// Break sends BRK, e.g. to enter the menu of a console server
+func (c *Conn) Break() error {
+	return c.command(cmdBreak)
+}
+
+// InterruptProcess sends IP, the server interrupts the running process
+func (c *Conn) InterruptProcess() error {
+	return c.command(cmdIP)
+}
+
+// AbortOutput sends AO, the server discards the output of the running process
+func (c *Conn) AbortOutput() error {
+	return c.command(cmdAO)
+}
+
+// Signal sends the command of the signal name: BRK (BREAK), IP (INT) or AO
+func (c *Conn) Signal(name string) error {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "BRK", "BREAK":
+		return c.Break()
+	case "IP", "INT":
+		return c.InterruptProcess()
+	case "AO":
+		return c.AbortOutput()
+	default:
+		return fmt.Errorf("unsupported telnet signal: %q", name)
+	}
+}
+
+func (c *Conn) command(cmd byte) error {
+	_, err := c.Conn.Write([]byte{cmdIAC, cmd})
+	return err
+}
+
 func (c *Conn) cmd(cmd byte) error {
 	switch cmd {
 	case cmdGA:
@@ -237,16 +560,25 @@ func (c *Conn) cmd(cmd byte) error {
 			if char == cmdSE {
 				break
 			}
-
-			data = append(data, cmdIAC)
+			if char != cmdIAC {
+				data = append(data, cmdIAC)
+			}
 			data = append(data, char)
 		}
 
 		switch o {
 		case optWndType:
-			if len(data) == 1 && data[0] == 1 {
-				// IAC SB TERMINAL-TYPE IS xterm IAC SE
-				_, err = c.Conn.Write([]byte{cmdIAC, cmdSB, optWndType, 0, 'x', 't', 'e', 'r', 'm', cmdIAC, cmdSE})
+			if len(data) == 1 && data[0] == ttypeSend {
+				return c.sendTerminalType()
+			}
+		case optNewEnviron:
+			if len(data) > 0 && data[0] == envSend {
+				_, err = c.Conn.Write(c.environCommand(data[1:]))
+				return err
+			}
+		case optLinemode:
+			if answer := c.lineModeCommand(data); answer != nil {
+				_, err = c.Conn.Write(answer)
 				return err
 			}
 		}
@@ -299,9 +631,61 @@ func (c *Conn) cmd(cmd byte) error {
 
 		}
 	case optWndSize:
-		if cmd == cmdDo {
-			_, err = c.Conn.Write([]byte{cmdIAC, cmdSB, optWndSize, 0, c.columns, 0, c.rows, cmdIAC, cmdSE})
+		c.sizeMu.Lock()
+		switch cmd {
+		case cmdDo:
+			if !c.nawsOffered {
+				c.nawsOffered = true
+				err = c.will(o)
+			}
+			if err == nil {
+				c.naws = true
+				err = c.sendWindowSizeLocked()
+			}
+		case cmdDont:
+			if c.naws {
+				err = c.wont(o)
+			}
+			c.naws = false
+		}
+		c.sizeMu.Unlock()
+	case optNewEnviron:
+		c.optMu.Lock()
+		switch cmd {
+		case cmdDo:
+			if !c.environEnabled {
+				c.environEnabled = true
+				err = c.will(o)
+			}
+		case cmdDont:
+			if c.environEnabled {
+				c.environEnabled = false
+				err = c.wont(o)
+			}
+		case cmdWill, cmdWont:
+			err = c.dont(o)
+		}
+		c.optMu.Unlock()
+	case optLinemode:
+		c.optMu.Lock()
+		switch cmd {
+		case cmdDo:
+			if !c.lineModeAllowed {
+				err = c.wont(o)
+			} else if !c.lineModeEnabled {
+				c.lineModeEnabled = true
+				err = c.will(o)
+			}
+		case cmdDont:
+			if c.lineModeEnabled {
+				c.lineModeEnabled = false
+				c.lineMode = 0
+				err = c.wont(o)
+			}
+		case cmdWill, cmdWont:
+			err = c.dont(o)
 		}
+		c.optMu.Unlock()
 	case optWndType:
 		// Accept any echo configuration.
 		switch cmd {
@@ -0,0 +1,156 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWindowSizeCommand(t *testing.T) {
+	got := windowSizeCommand(255, 300)
+	want := []byte{cmdIAC, cmdSB, optWndSize, 1, 44, 0, cmdIAC, cmdIAC, cmdIAC, cmdSE}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// dialTest connects a Conn to the returned server side, expect reads the next bytes the Conn sends
+func dialTest(t *testing.T) (conn *Conn, server net.Conn, expect func(want []byte)) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err = NewConn(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Close()
+	})
+	go io.Copy(io.Discard, conn)
+
+	// the NOPs of the keepalive are skipped
+	return conn, server, func(want []byte) {
+		t.Helper()
+		got := make([]byte, 0, len(want))
+		b := make([]byte, 1)
+		for len(got) < len(want) {
+			if _, err := server.Read(b); err != nil {
+				t.Fatalf("got %v, want %v: %v", got, want, err)
+			}
+			if b[0] == cmdNOP && len(got) == 0 {
+				continue
+			}
+			got = append(got, b[0])
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNAWS(t *testing.T) {
+	conn, server, expect := dialTest(t)
+
+	conn.SetWindowSize(40, 120)
+	expect([]byte{cmdIAC, cmdWill, optWndSize})
+	// the size changed before the server accepts NAWS is sent on DO
+	conn.SetWindowSize(50, 132)
+	server.Write([]byte{cmdIAC, cmdDo, optWndSize})
+	expect(windowSizeCommand(50, 132))
+	// the size changes are sent at once after NAWS is accepted
+	conn.SetWindowSize(1000, 255)
+	expect(windowSizeCommand(1000, 255))
+	if rows, columns := conn.WindowSize(); rows != 1000 || columns != 255 {
+		t.Fatalf("WindowSize() = %d, %d", rows, columns)
+	}
+}
+
+func TestTerminalTypeCycling(t *testing.T) {
+	conn, server, expect := dialTest(t)
+	conn.SetTerminalTypes("IBM-3278-2", "bad name", "vt100")
+	var sent []string
+	done := make(chan struct{}, 10)
+	conn.OnTerminalType(func(name string) {
+		sent = append(sent, name)
+		done <- struct{}{}
+	})
+
+	// the last type is repeated to mark the end of the list, then the list starts over
+	for _, name := range []string{"IBM-3278-2", "vt100", "vt100", "IBM-3278-2"} {
+		server.Write([]byte{cmdIAC, cmdSB, optWndType, ttypeSend, cmdIAC, cmdSE})
+		expect(append(append([]byte{cmdIAC, cmdSB, optWndType, ttypeIS}, name...), cmdIAC, cmdSE))
+		<-done
+	}
+	if conn.TerminalType() != "IBM-3278-2" || len(sent) != 4 {
+		t.Fatalf("TerminalType() = %q, sent %v", conn.TerminalType(), sent)
+	}
+}
+
+func TestNewEnviron(t *testing.T) {
+	conn, server, expect := dialTest(t)
+	conn.SetEnviron(map[string]string{"USER": "root", "LANG": "C\x01"})
+
+	server.Write([]byte{cmdIAC, cmdDo, optNewEnviron})
+	expect([]byte{cmdIAC, cmdWill, optNewEnviron})
+	// the value byte 1 is VALUE, it is escaped by ESC
+	server.Write([]byte{cmdIAC, cmdSB, optNewEnviron, envSend, cmdIAC, cmdSE})
+	expect([]byte{cmdIAC, cmdSB, optNewEnviron, envIS,
+		envVar, 'U', 'S', 'E', 'R', envValue, 'r', 'o', 'o', 't',
+		envUserVar, 'L', 'A', 'N', 'G', envValue, 'C', envEsc, 1,
+		cmdIAC, cmdSE})
+	// the variable which is not set is sent without a value
+	server.Write([]byte{cmdIAC, cmdSB, optNewEnviron, envSend, envVar, 'U', 'S', 'E', 'R', envUserVar, 'T', 'Z', cmdIAC, cmdSE})
+	expect([]byte{cmdIAC, cmdSB, optNewEnviron, envIS,
+		envVar, 'U', 'S', 'E', 'R', envValue, 'r', 'o', 'o', 't',
+		envUserVar, 'T', 'Z',
+		cmdIAC, cmdSE})
+}
+
+func TestLineMode(t *testing.T) {
+	conn, server, expect := dialTest(t)
+
+	server.Write([]byte{cmdIAC, cmdDo, optLinemode})
+	expect([]byte{cmdIAC, cmdWont, optLinemode})
+
+	conn.SetLineMode(true)
+	server.Write([]byte{cmdIAC, cmdDo, optLinemode})
+	expect([]byte{cmdIAC, cmdWill, optLinemode})
+	// local editing is refused, the character at a time mode is proposed
+	server.Write([]byte{cmdIAC, cmdSB, optLinemode, lmMode, LineModeEdit | LineModeTrapSig, cmdIAC, cmdSE})
+	expect([]byte{cmdIAC, cmdSB, optLinemode, lmMode, 0, cmdIAC, cmdSE})
+	server.Write([]byte{cmdIAC, cmdSB, optLinemode, cmdDo, lmForwardMask, cmdIAC, cmdSE})
+	expect([]byte{cmdIAC, cmdSB, optLinemode, cmdWont, lmForwardMask, cmdIAC, cmdSE})
+	server.Write([]byte{cmdIAC, cmdSB, optLinemode, lmMode, 0, cmdIAC, cmdSE})
+	expect([]byte{cmdIAC, cmdSB, optLinemode, lmMode, lineModeAck, cmdIAC, cmdSE})
+	if enabled, mode := conn.LineMode(); !enabled || mode != 0 {
+		t.Fatalf("LineMode() = %v, %d", enabled, mode)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	conn, _, expect := dialTest(t)
+	for name, cmd := range map[string]byte{"BRK": cmdBreak, "SIGINT": cmdIP, "ao": cmdAO} {
+		if err := conn.Signal(name); err != nil {
+			t.Fatal(err)
+		}
+		expect([]byte{cmdIAC, cmd})
+	}
+	if err := conn.Signal("KILL"); err == nil {
+		t.Fatal("KILL has no telnet command")
+	}
+}
@@ -0,0 +1,74 @@
+package ticket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+const DefaultTTL = 30 * time.Second
+
+type item struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewStore(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{ttl: ttl, items: map[string]*item{}}
+}
+
+// Store 一次性票据，票据在有效期内只能兑换一次
+type Store struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	items map[string]*item
+}
+
+func (s *Store) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue stores the value and returns a random ticket
+func (s *Store) Issue(value interface{}) (string, time.Time, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	expires := now.Add(s.ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.items {
+		if now.After(v.expires) {
+			delete(s.items, k)
+		}
+	}
+	s.items[id] = &item{value: value, expires: expires}
+	return id, expires, nil
+}
+
+// Redeem returns the value of the ticket and removes it, it returns false if the ticket is unknown or expired
+func (s *Store) Redeem(id string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.items[id]
+	if !ok {
+		return nil, false
+	}
+	delete(s.items, id)
+	if time.Now().After(v.expires) {
+		return nil, false
+	}
+	return v.value, true
+}
+
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
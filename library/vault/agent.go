@@ -0,0 +1,45 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/admpub/web-terminal/config"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Agent returns the agent of the profile, nil if the agent is disabled.
+// The keyring holds the private key of the profile and the keys of the AgentKeys profiles.
+func Agent(b Backend, p *Profile) (agent.Agent, error) {
+	switch p.Agent {
+	case "":
+		return nil, nil
+	case AgentSystem:
+		return config.SystemAgent()
+	case AgentKeyring:
+		var keys []config.KeyringKey
+		if len(p.PrivateKey) > 0 {
+			keys = append(keys, keyringKey(p))
+		}
+		for _, id := range p.AgentKeys {
+			kp, err := b.Get(id)
+			if err != nil {
+				return nil, fmt.Errorf("agent key of profile %q: %w", id, err)
+			}
+			if len(kp.PrivateKey) == 0 {
+				return nil, fmt.Errorf("agent key of profile %q: the profile has no private key", id)
+			}
+			keys = append(keys, keyringKey(kp))
+		}
+		return config.NewKeyring(keys...)
+	default:
+		return nil, fmt.Errorf("profile %q: invalid agent %q", p.ID, p.Agent)
+	}
+}
+
+func keyringKey(p *Profile) config.KeyringKey {
+	return config.KeyringKey{
+		PrivateKey: []byte(p.PrivateKey),
+		Passphrase: []byte(p.Passphrase),
+		Comment:    p.ID,
+	}
+}
@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrWrongKey = errors.New("failed to decrypt the vault, the master key is wrong or the file is damaged")
+
+const fileVersion = 1
+
+// scrypt parameters of the key derivation
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// envelope is the content of the vault file
+type envelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"` // AES-256-GCM encrypted JSON array of the profiles
+}
+
+// NewFileStore opens the vault file encrypted by the master key, the file is created by the first Put
+func NewFileStore(file string, masterKey []byte) (*FileStore, error) {
+	if len(masterKey) == 0 {
+		return nil, errors.New("the master key of the vault is empty")
+	}
+	s := &FileStore{file: file, masterKey: masterKey, profiles: map[string]*Profile{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FileStore 使用 AES-GCM 加密的文件存储
+type FileStore struct {
+	file      string
+	masterKey []byte
+	salt      []byte
+	aead      cipher.AEAD
+	mu        sync.RWMutex
+	profiles  map[string]*Profile
+}
+
+func (s *FileStore) File() string {
+	return s.file
+}
+
+func (s *FileStore) initCipher(salt []byte) error {
+	key, err := scrypt.Key(s.masterKey, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	s.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.salt = salt
+	return nil
+}
+
+func (s *FileStore) load() error {
+	b, err := os.ReadFile(s.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		salt := make([]byte, 16)
+		if _, err = rand.Read(salt); err != nil {
+			return err
+		}
+		return s.initCipher(salt)
+	}
+	env := envelope{}
+	if err = json.Unmarshal(b, &env); err != nil {
+		return fmt.Errorf("parse vault file %q: %w", s.file, err)
+	}
+	if env.Version != fileVersion || env.KDF != "scrypt" {
+		return fmt.Errorf("unsupported vault file %q: version %d, kdf %q", s.file, env.Version, env.KDF)
+	}
+	if err = s.initCipher(env.Salt); err != nil {
+		return err
+	}
+	plain, err := s.aead.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return ErrWrongKey
+	}
+	var profiles []*Profile
+	if err = json.Unmarshal(plain, &profiles); err != nil {
+		return fmt.Errorf("parse vault file %q: %w", s.file, err)
+	}
+	for _, p := range profiles {
+		s.profiles[p.ID] = p
+	}
+	return nil
+}
+
+// save writes all the profiles with a new nonce, the caller must hold the lock
+func (s *FileStore) save() error {
+	profiles := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID < profiles[j].ID })
+	plain, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	b, err := json.Marshal(&envelope{
+		Version: fileVersion,
+		KDF:     "scrypt",
+		Salt:    s.salt,
+		Nonce:   nonce,
+		Data:    s.aead.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+func (s *FileStore) Get(id string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *p
+	return &copied, nil
+}
+
+func (s *FileStore) List() ([]*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		copied := *p
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *FileStore) Put(profile *Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	copied := *profile
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, exists := s.profiles[profile.ID]
+	s.profiles[profile.ID] = &copied
+	if err := s.save(); err != nil {
+		if exists {
+			s.profiles[profile.ID] = old
+		} else {
+			delete(s.profiles, profile.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.profiles[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.profiles, id)
+	if err := s.save(); err != nil {
+		s.profiles[id] = old
+		return err
+	}
+	return nil
+}
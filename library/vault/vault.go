@@ -0,0 +1,182 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/admpub/web-terminal/config"
+)
+
+var (
+	ErrNotFound         = errors.New("profile is not found")
+	ErrInvalidProfileID = errors.New("invalid profile id")
+	ErrJumpLoop         = errors.New("the jump chain of the profile has a loop")
+
+	profileIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// MaxJumps limits the length of the jump chain
+const MaxJumps = 8
+
+// Backend stores the profiles, see FileStore
+type Backend interface {
+	Get(id string) (*Profile, error)
+	List() ([]*Profile, error)
+	Put(profile *Profile) error
+	Delete(id string) error
+}
+
+// Profile 保存的主机配置，包含凭据
+type Profile struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	Host          string   `json:"host"`
+	Port          int      `json:"port,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Password      string   `json:"password,omitempty"`
+	PrivateKey    string   `json:"privateKey,omitempty"`
+	Passphrase    string   `json:"passphrase,omitempty"`
+	Certificate   string   `json:"certificate,omitempty"` // the certificate of PrivateKey in authorized_keys format
+	Charset       string   `json:"charset,omitempty"`
+	HostKeyPolicy string   `json:"hostKeyPolicy,omitempty"`
+	Jumps         []string `json:"jumps,omitempty"`      // the profile IDs of the jump hosts, the first hop first
+	Principals    []string `json:"principals,omitempty"` // who may use the profile: "*", name or "role:name", empty means everyone
+
+	Agent        string   `json:"agent,omitempty"`        // "system" (SSH_AUTH_SOCK of the server) or "keyring", empty disables the agent
+	AgentKeys    []string `json:"agentKeys,omitempty"`    // the profile IDs whose private keys are added to the keyring besides the own key
+	ForwardAgent bool     `json:"forwardAgent,omitempty"` // forward the agent to the sessions
+
+	Algorithms config.AlgorithmOptions `json:"algorithms,omitempty"` // the algorithm preset and the lists in the OpenSSH syntax
+}
+
+const (
+	AgentSystem  = "system"
+	AgentKeyring = "keyring"
+)
+
+// Validate checks the profile before saving
+func (p *Profile) Validate() error {
+	if !ValidID(p.ID) {
+		return ErrInvalidProfileID
+	}
+	if len(p.Host) == 0 {
+		return fmt.Errorf("profile %q: host is empty", p.ID)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("profile %q: invalid port %d", p.ID, p.Port)
+	}
+	if len(p.HostKeyPolicy) > 0 {
+		if _, err := config.ParseHostKeyPolicy(p.HostKeyPolicy); err != nil {
+			return fmt.Errorf("profile %q: %w", p.ID, err)
+		}
+	}
+	switch p.Agent {
+	case "", AgentSystem, AgentKeyring:
+	default:
+		return fmt.Errorf("profile %q: invalid agent %q, expected %q or %q", p.ID, p.Agent, AgentSystem, AgentKeyring)
+	}
+	if p.ForwardAgent && len(p.Agent) == 0 {
+		return fmt.Errorf("profile %q: forwardAgent requires the agent", p.ID)
+	}
+	if _, err := p.Algorithms.Algorithms(); err != nil {
+		return fmt.Errorf("profile %q: %w", p.ID, err)
+	}
+	return nil
+}
+
+func ValidID(id string) bool {
+	return profileIDRegexp.MatchString(id)
+}
+
+func (p *Profile) GetPort() int {
+	if p.Port > 0 {
+		return p.Port
+	}
+	return 22
+}
+
+// Account returns the account of the profile
+func (p *Profile) Account() *config.AccountConfig {
+	account := &config.AccountConfig{
+		User:     p.User,
+		Password: p.Password,
+		Charset:  p.Charset,
+	}
+	if len(p.PrivateKey) > 0 {
+		account.PrivateKey = []byte(p.PrivateKey)
+	}
+	if len(p.Passphrase) > 0 {
+		account.Passphrase = []byte(p.Passphrase)
+	}
+	if len(p.Certificate) > 0 {
+		account.Certificate = []byte(p.Certificate)
+	}
+	if len(p.HostKeyPolicy) > 0 {
+		account.HostKeyPolicy, _ = config.ParseHostKeyPolicy(p.HostKeyPolicy)
+	}
+	return account
+}
+
+// Info is the profile without secrets, it is safe to send to the browser
+type Info struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name,omitempty"`
+	Host         string   `json:"host"`
+	Port         int      `json:"port"`
+	User         string   `json:"user,omitempty"`
+	Jumps        []string `json:"jumps,omitempty"`
+	HasKey       bool     `json:"hasKey"`
+	Agent        string   `json:"agent,omitempty"`
+	ForwardAgent bool     `json:"forwardAgent,omitempty"`
+	Algorithms   string   `json:"algorithms,omitempty"` // the preset
+}
+
+func (p *Profile) Info() *Info {
+	return &Info{
+		ID:     p.ID,
+		Name:   p.Name,
+		Host:   p.Host,
+		Port:   p.GetPort(),
+		User:   p.User,
+		Jumps:  p.Jumps,
+		HasKey: len(p.PrivateKey) > 0,
+
+		Agent:        p.Agent,
+		ForwardAgent: p.ForwardAgent,
+		Algorithms:   p.Algorithms.Preset,
+	}
+}
+
+// Chain returns the profiles of the jump hosts and the end host, the end host is the last one.
+// The jumps of a jump host profile are dialed before it.
+func Chain(b Backend, id string) ([]*Profile, error) {
+	var chain []*Profile
+	visiting := map[string]bool{}
+	var walk func(id string) error
+	walk = func(id string) error {
+		if visiting[id] {
+			return ErrJumpLoop
+		}
+		if len(chain) > MaxJumps {
+			return fmt.Errorf("the jump chain is longer than %d", MaxJumps)
+		}
+		profile, err := b.Get(id)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", id, err)
+		}
+		visiting[id] = true
+		for _, jump := range profile.Jumps {
+			if err = walk(jump); err != nil {
+				return err
+			}
+		}
+		visiting[id] = false
+		chain = append(chain, profile)
+		return nil
+	}
+	if err := walk(id); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
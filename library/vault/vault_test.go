@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "vault.json")
+	s, err := NewFileStore(file, []byte("master"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Profile{
+		{ID: "bastion", Host: "bastion.example.com", User: "jump", PrivateKey: "-----BEGIN KEY-----"},
+		{ID: "inner", Host: "10.0.0.2", Port: 2222, User: "root", Password: "s3cret", Jumps: []string{"bastion"}},
+	} {
+		if err = s.Put(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Put(&Profile{ID: "../x", Host: "h"}); err != ErrInvalidProfileID {
+		t.Fatalf("expected ErrInvalidProfileID, got %v", err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("s3cret")) || bytes.Contains(b, []byte("10.0.0.2")) {
+		t.Fatal("the vault file must be encrypted")
+	}
+
+	if _, err = NewFileStore(file, []byte("wrong")); err != ErrWrongKey {
+		t.Fatalf("expected ErrWrongKey, got %v", err)
+	}
+	s, err = NewFileStore(file, []byte("master"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := Chain(s, "inner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0].ID != "bastion" || chain[1].Password != "s3cret" {
+		t.Fatalf("unexpected chain %+v", chain)
+	}
+
+	bastion, _ := s.Get("bastion")
+	bastion.Jumps = []string{"inner"}
+	s.Put(bastion)
+	if _, err = Chain(s, "inner"); err != ErrJumpLoop {
+		t.Fatalf("expected ErrJumpLoop, got %v", err)
+	}
+	if err = s.Delete("bastion"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("bastion"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
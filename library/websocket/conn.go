@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/admpub/websocket"
+)
+
+// Conn is the websocket of the terminal handlers, it is pinged by KeepAlive.
+// The clients negotiating Subprotocol exchange Message frames. The legacy clients get the output as the raw text frames,
+// and their text frames are the input except the JSON control messages.
+// The writes are serialized, the reads must be made by one goroutine at a time.
+type Conn struct {
+	*websocket.Conn
+	request *http.Request
+	legacy  bool
+	writeMu sync.Mutex
+	pending []byte // the input not read yet
+	stop    func()
+	once    sync.Once
+
+	// OnResize is called by Read for the resize messages
+	OnResize func(cols, rows int)
+	// OnMessage is called by Read for the messages other than stdin and resize
+	OnMessage func(*Message)
+}
+
+// Upgrade upgrades the request to the websocket, DefaultUpgrader is used by default.
+// The upgrader replies to the client if it fails.
+func Upgrade(w http.ResponseWriter, req *http.Request, upgraders ...websocket.Upgrader) (*Conn, error) {
+	upgrader := DefaultUpgrader
+	if len(upgraders) > 0 {
+		upgrader = upgraders[0]
+	}
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(ws, req), nil
+}
+
+func NewConn(ws *websocket.Conn, req *http.Request) *Conn {
+	return &Conn{
+		Conn:    ws,
+		request: req,
+		legacy:  ws.Subprotocol() != Subprotocol,
+		stop:    KeepAlive(ws),
+	}
+}
+
+// Request returns the handshake request
+func (c *Conn) Request() *http.Request {
+	return c.request
+}
+
+// Legacy reports whether the client exchanges the raw text instead of the Message frames
+func (c *Conn) Legacy() bool {
+	return c.legacy
+}
+
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.setWriteDeadline()
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.setWriteDeadline()
+	return c.Conn.WriteJSON(v)
+}
+
+// setWriteDeadline bounds the next write by WriteTimeout, a stalled peer fails the write instead of blocking the writer
+func (c *Conn) setWriteDeadline() {
+	if WriteTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	}
+}
+
+// Send sends the message, the legacy clients get the data of the output and alert messages as the text frame
+func (c *Conn) Send(msg *Message) error {
+	if c.legacy {
+		switch msg.Type {
+		case MessageTypeStdout, MessageTypeStderr, MessageTypeConsole, MessageTypeAlert:
+			return c.WriteMessage(websocket.TextMessage, msg.Data)
+		}
+	}
+	if msg.Version == 0 {
+		versioned := *msg
+		versioned.Version = ProtocolVersion
+		msg = &versioned
+	}
+	return c.WriteJSON(msg)
+}
+
+// Write sends p as the stdout message
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.Send(&Message{Type: MessageTypeStdout, Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Receive returns the next message. The binary frames, e.g. zmodem, and the text typed by the legacy clients are stdin messages.
+// io.EOF is returned after the client closes the websocket.
+func (c *Conn) Receive() (*Message, error) {
+	messageType, data, err := c.Conn.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	Touch(c.Conn)
+	if messageType == websocket.TextMessage {
+		if msg := ParseMessage(data, c.legacy); msg != nil {
+			return msg, nil
+		}
+	}
+	return &Message{Type: MessageTypeStdin, Data: data}, nil
+}
+
+// Read reads the data of the stdin messages, the other messages are passed to OnResize and OnMessage
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		msg, err := c.Receive()
+		if err != nil {
+			return 0, err
+		}
+		switch msg.Type {
+		case MessageTypeStdin:
+			c.pending = msg.Data
+		case MessageTypeResize:
+			if c.OnResize != nil && msg.Cols > 0 && msg.Rows > 0 {
+				c.OnResize(msg.Cols, msg.Rows)
+			}
+		default:
+			if c.OnMessage != nil {
+				c.OnMessage(msg)
+			}
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Close sends the normal closure to the client and closes the connection, it may be called more than once
+func (c *Conn) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.stop()
+		c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		err = c.Conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/websocket"
+)
+
+// echoServer answers the stdin with the stdout and reports the resize and the other messages by stderr
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.OnResize = func(cols, rows int) {
+			conn.Send(&Message{Type: MessageTypeStderr, Data: []byte("resize")})
+		}
+		conn.OnMessage = func(msg *Message) {
+			conn.Send(&Message{Type: MessageTypeStderr, Data: []byte(msg.Type)})
+		}
+		buf := make([]byte, 3)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			conn.Write(buf[:n])
+		}
+	}))
+}
+
+func dial(t *testing.T, server *httptest.Server, subprotocols ...string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return ws
+}
+
+func TestConnMessages(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	ws := dial(t, server, Subprotocol)
+	defer ws.Close()
+
+	ws.WriteJSON(&Message{Version: ProtocolVersion, Type: MessageTypeResize, Cols: 100, Rows: 30})
+	ws.WriteJSON(&Message{Version: ProtocolVersion, Type: MessageTypeSignal, Signal: "INT"})
+	ws.WriteJSON(&Message{Version: ProtocolVersion, Type: MessageTypeStdin, Data: []byte("ls -l")})
+	ws.WriteMessage(websocket.BinaryMessage, []byte{0x18, 'B'})
+	var got []string
+	for len(got) < 5 {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Version != ProtocolVersion {
+			t.Fatalf("version %d", msg.Version)
+		}
+		got = append(got, string(msg.Type)+":"+string(msg.Data))
+	}
+	want := "stderr:resize,stderr:signal,stdout:ls ,stdout:-l,stdout:\x18B"
+	if s := strings.Join(got, ","); s != want {
+		t.Fatalf("messages %q, want %q", s, want)
+	}
+}
+
+func TestConnLegacy(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	ws := dial(t, server)
+	defer ws.Close()
+
+	// the JSON is typed text unless it begins with {"type":"
+	typed := `{"v":1,"type":"stdin","data":"bHM="}`
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type":"resize","cols":100,"rows":30}`))
+	ws.WriteMessage(websocket.TextMessage, []byte(typed))
+	got := ""
+	for len(got) < len("resize"+typed) {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != websocket.TextMessage {
+			t.Fatalf("message type %d", messageType)
+		}
+		got += string(data)
+	}
+	if got != "resize"+typed {
+		t.Fatalf("output %q", got)
+	}
+
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("the server did not close normally: %v", err)
+	}
+}
@@ -1,12 +1,7 @@
 package websocket
 
-// Writer websocket writer
-type Writer interface {
+// Sender sends the Message frames and the raw frames, e.g. the zmodem binary frames, it is implemented by Conn
+type Sender interface {
+	Send(*Message) error
 	WriteMessage(int, []byte) error
-	WriteJSON(v interface{}) error
-}
-
-// Reader websocket reader
-type Reader interface {
-	ReadMessage() (int, []byte, error)
 }
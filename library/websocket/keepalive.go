@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/admpub/websocket"
+)
+
+var (
+	// PingInterval is the interval of the ping frames to the peer, zero disables them
+	PingInterval = 30 * time.Second
+	// MaxMissedPongs closes the connection after this number of intervals without any frame from the peer
+	MaxMissedPongs = 3
+	// WriteTimeout fails the write of a message to the peer which does not read it, zero waits forever
+	WriteTimeout = 10 * time.Second
+)
+
+func idleTimeout() time.Duration {
+	missed := MaxMissedPongs
+	if missed <= 0 {
+		missed = 3
+	}
+	return PingInterval * time.Duration(missed)
+}
+
+// KeepAlive pings the peer every PingInterval until stop is called.
+// The read deadline is extended by every pong, the blocked read fails once the peer is gone.
+func KeepAlive(ws *websocket.Conn) (stop func()) {
+	if PingInterval <= 0 {
+		return func() {}
+	}
+	ws.SetReadDeadline(time.Now().Add(idleTimeout()))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(idleTimeout()))
+	})
+	done := make(chan struct{})
+	interval := PingInterval // the goroutine may outlive a change of PingInterval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Touch extends the read deadline after a message is received
+func Touch(ws *websocket.Conn) {
+	if PingInterval > 0 {
+		ws.SetReadDeadline(time.Now().Add(idleTimeout()))
+	}
+}
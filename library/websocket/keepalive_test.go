@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/admpub/websocket"
+)
+
+func TestKeepAlive(t *testing.T) {
+	interval, missed := PingInterval, MaxMissedPongs
+	PingInterval, MaxMissedPongs = 10*time.Millisecond, 3
+	defer func() { PingInterval, MaxMissedPongs = interval, missed }()
+
+	closed := make(chan time.Duration, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		start := time.Now()
+		for {
+			if _, err := conn.Receive(); err != nil {
+				closed <- time.Since(start)
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	// the client answers the pings while it is reading, it is kept although it sends nothing
+	ws := dial(t, server)
+	var pings int32
+	ws.SetPingHandler(func(data string) error {
+		atomic.AddInt32(&pings, 1)
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-closed:
+		t.Fatal("the peer answering the pings is disconnected")
+	case <-time.After(10 * idleTimeout()):
+	}
+	if atomic.LoadInt32(&pings) < 3 {
+		t.Fatalf("%d pings are received", pings)
+	}
+	ws.Close()
+	<-closed
+
+	// the client stops reading, so the pings are not answered
+	ws = dial(t, server)
+	defer ws.Close()
+	select {
+	case elapsed := <-closed:
+		if elapsed < idleTimeout() {
+			t.Fatalf("the peer is disconnected after %v, before the idle timeout", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the silent peer is not disconnected")
+	}
+}
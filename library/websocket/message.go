@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+const (
+	// ProtocolVersion is the version of the Message schema
+	ProtocolVersion = 1
+	// Subprotocol is requested by the clients using the Message schema by the Sec-WebSocket-Protocol header,
+	// the clients without it are legacy clients exchanging the raw text, see Conn
+	Subprotocol = "web-terminal.v1"
+)
+
+type MessageType string
+
+// Message is the JSON text frame exchanged with the browser, the data is base64 encoded.
+// The control messages, e.g. {"type":"prompt"} and {"type":"presence"}, share the "type" field and have their own fields.
+type Message struct {
+	Version int         `json:"v,omitempty"`
+	Type    MessageType `json:"type"`
+	Data    []byte      `json:"data"`
+	Cols    int         `json:"cols,omitempty"`
+	Rows    int         `json:"rows,omitempty"`
+	Signal  string      `json:"signal,omitempty"` // the signal name of MessageTypeSignal without the SIG prefix, e.g. INT
+
+	// Raw is the received frame, the fields of the control messages are decoded from it
+	Raw json.RawMessage `json:"-"`
+}
+
+const (
+	MessageTypeStdin   MessageType = "stdin"
+	MessageTypeStdout  MessageType = "stdout"
+	MessageTypeStderr  MessageType = "stderr"
+	MessageTypeResize  MessageType = "resize"
+	MessageTypeConsole MessageType = "console"
+	MessageTypeAlert   MessageType = "alert"
+	// MessageTypeConnectionLost is sent when the host stops answering the keepalive requests, Data is the reason
+	MessageTypeConnectionLost MessageType = "connection_lost"
+	// MessageTypeSignal sends the signal to the remote process or the foreground process group of the local terminal
+	MessageTypeSignal MessageType = "signal"
+	// MessageTypeExit is sent when the process exits, Data is the exit status
+	MessageTypeExit MessageType = "exit"
+)
+
+// ParseMessage decodes the JSON text frame, nil is returned if it is not a message, e.g. the text typed by a legacy client.
+// The legacy clients send the control messages only, they begin with {"type":".
+func ParseMessage(data []byte, legacy bool) *Message {
+	if legacy && !bytes.HasPrefix(data, []byte(`{"type":"`)) {
+		return nil
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(data, msg); err != nil || len(msg.Type) == 0 {
+		return nil
+	}
+	msg.Raw = data
+	return msg
+}
@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AllowedOrigins 允许跨域连接的来源。
+// Empty means same origin only, "*" allows all origins.
+// Entries are full origins ("https://example.com") or host patterns ("*.example.com", "example.com:8080").
+var AllowedOrigins []string
+
+// CheckOrigin reports whether the websocket handshake is allowed by the Origin header.
+// Requests without an Origin header come from non-browser clients and are allowed.
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Host) == 0 {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(allowed), strings.ToLower(u.Host)); ok {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(allowed), strings.ToLower(u.Hostname())); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,13 @@ import (
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/admpub/web-terminal/config"
 	"github.com/admpub/web-terminal/handler"
+	"github.com/admpub/web-terminal/library/audit"
+	"github.com/admpub/web-terminal/library/ca"
+	"github.com/admpub/web-terminal/library/policy"
+	"github.com/admpub/web-terminal/library/session"
+	"github.com/admpub/web-terminal/library/ticket"
+	"github.com/admpub/web-terminal/library/vault"
+	wsx "github.com/admpub/web-terminal/library/websocket"
 )
 
 func init() {
@@ -22,6 +29,58 @@ func main() {
 		return
 	}
 	config.Default.SetDefault()
+	authenticator, err := handler.NewAuthenticator(config.Default)
+	if err != nil {
+		fmt.Println(errors.New("load authentication fail, " + err.Error()))
+		return
+	}
+	if authenticator == nil {
+		fmt.Println("[web-terminal] [warn] authentication is disabled, see -auth_tokens, -auth_htpasswd and -auth_jwt_secret")
+	}
+	handler.Authenticator = authenticator
+	if len(config.Default.PolicyFile) > 0 {
+		handler.Policy, err = policy.Load(config.Default.PolicyFile)
+		if err != nil {
+			fmt.Println(errors.New("load policy fail, " + err.Error()))
+			return
+		}
+	}
+	handler.AuditLog = audit.New(config.Default.AuditLog)
+	handler.Tickets = ticket.NewStore(config.Default.TicketTTL)
+	handler.Sessions = session.NewManager(config.Default.SessionGrace, config.Default.SessionBuffer)
+	if len(config.Default.CAKeyFile) > 0 {
+		handler.CertSigner, err = ca.LoadSigner(config.Default.CAKeyFile, []byte(config.Default.CAKeyPassphrase), ca.Options{
+			Principals:      config.SplitList(config.Default.CAPrincipals),
+			Validity:        config.Default.CAValidity,
+			CriticalOptions: ca.ParseOptions(config.Default.CACriticalOptions),
+		})
+		if err != nil {
+			fmt.Println(errors.New("load ca key fail, " + err.Error()))
+			return
+		}
+	}
+	if len(config.Default.VaultKey) > 0 {
+		store, err := vault.NewFileStore(config.Default.VaultFile, []byte(config.Default.VaultKey))
+		if err != nil {
+			fmt.Println(errors.New("open vault fail, " + err.Error()))
+			return
+		}
+		handler.Vault = store
+	}
+	if _, err = config.AlgorithmPreset(config.Default.SSHAlgorithms); err != nil {
+		fmt.Println(errors.New("invalid -ssh_algorithms, " + err.Error()))
+		return
+	}
+	if len(config.Default.SSHConfigFile) > 0 {
+		handler.SSHHosts, err = config.LoadOpenSSHConfig(config.Default.SSHConfigFile)
+		if err != nil {
+			fmt.Println(errors.New("load ssh config fail, " + err.Error()))
+			return
+		}
+	}
+	wsx.AllowedOrigins = config.SplitList(config.Default.AllowedOrigins)
+	wsx.PingInterval = config.Default.WSKeepAlive
+	wsx.MaxMissedPongs = config.Default.WSKeepAliveCountMax
 
 	appRoot := config.Default.APPRoot
 	handler.Register(appRoot, http.Handle)
@@ -32,7 +91,7 @@ func main() {
 		return
 	}
 	httpFS := http.FileServer(templateBox.HTTPBox())
-	http.Handle(appRoot+"static/", http.StripPrefix(appRoot+"static/", httpFS))
+	http.Handle(appRoot+"static/", handler.AuthHandler(http.StripPrefix(appRoot+"static/", httpFS)))
 	fmt.Println("[web-terminal] listen at '" + config.Default.Listen + "' with root is '" + config.Default.ResourceDir + "'")
 	err = http.ListenAndServe(config.Default.Listen, nil)
 	if err != nil {
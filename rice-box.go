@@ -126,15 +126,15 @@ func init() {
 	}
 	fileu := &embedded.EmbeddedFile{
 		Filename:    "main.js",
-		FileModTime: time.Unix(1708056574, 0),
+		FileModTime: time.Unix(1792318810, 0),
 
-		Content: string("\nTerminal.applyAddon(attach);\nTerminal.applyAddon(fit);\nTerminal.applyAddon(fullscreen);\nTerminal.applyAddon(search);\nTerminal.applyAddon(webLinks);\nTerminal.applyAddon(winptyCompat);\n\nvar term,\n    socket\n\nvar terminalContainer = document.getElementById('terminal-container'),\n    actionElements = {\n      findText: document.getElementById('find-text'),\n      findNext: document.getElementById('find-next'),\n      findPrevious: document.getElementById('find-previous'),\n      toggleOptions: document.getElementById('toggle-options'),\n    },\n    loginElements = {\n      user: document.getElementById('userName'),\n      password: document.getElementById('password'),\n      login: document.getElementById('ssh-login'),\n    },\n    optionElements = {\n      cursorBlink: document.getElementById('option-cursor-blink'),\n      cursorStyle: document.getElementById('option-cursor-style'),\n      scrollback: document.getElementById('option-scrollback'),\n      tabstopwidth: document.getElementById('option-tabstopwidth'),\n      bellStyle: document.getElementById('option-bell-style')\n    },\n    colsElement = document.getElementById('cols'),\n    rowsElement = document.getElementById('rows');\n\n\nvar urlPrefix = getQueryStringByName(\"url_prefix\")\nvar protocol = getQueryStringByName(\"protocol\")\nvar hostname = getQueryStringByName(\"hostname\")\nvar file = getQueryStringByName(\"file\")\nvar port = getQueryStringByName(\"port\")\nvar cmd = getQueryStringByName(\"cmd\")\nvar is_debug = getQueryStringByName(\"debug\")\nvar user = getQueryStringByName(\"user\")\nvar password = getQueryStringByName(\"password\")\n\n//根据QueryString参数名称获取值\nfunction getQueryStringByName(name) {\n  var result = location.search.match(new RegExp(\"[\\?\\&]\" + name + \"=([^\\&]+)\", \"i\"));\n  if (result == null || result.length < 1) {\n      return \"\";\n  }\n  return result[1];\n}\n\nfunction startsWith(s, prefix) {\n  return s.indexOf(prefix) == 0;\n}\n\nfunction changeClassList(ele, add, del) {\n    var klsList = ele.classList;\n    klsList.add(add);\n    klsList.remove(del);\n}\n\nfunction toggleLogin() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(optionsEl, \"hide\", \"active\")\n    \n    var klsList = loginEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(loginEl, \"active\", \"hide\")\n    } else {\n      changeClassList(loginEl, \"hide\", \"active\")\n    }\n}\n\nfunction toggleLogin() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(optionsEl, \"hide\", \"active\")\n    \n    var klsList = loginEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(loginEl, \"active\", \"hide\")\n    } else {\n      changeClassList(loginEl, \"hide\", \"active\")\n    }\n}\n\n\nfunction toggleOptions() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(loginEl, \"hide\", \"active\")\n\n    var klsList = optionsEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(optionsEl, \"active\", \"hide\")\n    } else {\n      changeClassList(optionsEl, \"hide\", \"active\")\n    }\n}\n\nactionElements.findNext.addEventListener('click', function() {\n    term.findNext(actionElements.findText.value);\n});\nactionElements.findPrevious.addEventListener('click', function() {\n    term.findPrevious(actionElements.findText.value);\n});\nactionElements.toggleOptions.addEventListener('click',  function() {\n  toggleOptions();\n});\nloginElements.login.addEventListener('click', function() {\n    user = loginElements.user.value;\n    password = loginElements.password.value;\n\n    toggleLogin();\n    connect();\n});\n\nfunction setTerminalSize() {\n  var cols = parseInt(colsElement.value, 10);\n  var rows = parseInt(rowsElement.value, 10);\n  var viewportElement = document.querySelector('.xterm-viewport');\n  var scrollBarWidth = viewportElement.offsetWidth - viewportElement.clientWidth;\n  var width = (cols * term.charMeasure.width + 20 /*room for scrollbar*/).toString() + 'px';\n  var height = (rows * term.charMeasure.height).toString() + 'px';\n\n  terminalContainer.style.width = width;\n  terminalContainer.style.height = height;\n  term.resize(cols, rows);\n}\n\ncolsElement.addEventListener('change', setTerminalSize);\nrowsElement.addEventListener('change', setTerminalSize);\n\n\noptionElements.cursorBlink.addEventListener('change', function () {\n  term.setOption('cursorBlink', optionElements.cursorBlink.checked);\n});\noptionElements.cursorStyle.addEventListener('change', function () {\n  term.setOption('cursorStyle', optionElements.cursorStyle.value);\n});\noptionElements.bellStyle.addEventListener('change', function () {\n  term.setOption('bellStyle', optionElements.bellStyle.value);\n});\noptionElements.scrollback.addEventListener('change', function () {\n  term.setOption('scrollback', parseInt(optionElements.scrollback.value, 10));\n});\noptionElements.tabstopwidth.addEventListener('change', function () {\n  term.setOption('tabStopWidth', parseInt(optionElements.tabstopwidth.value, 10));\n});\n\nfunction connect() {\n    if(protocol == \"ssh\") {\n      if (undefined == password || null == password || \"\" == password) {\n        toggleLogin()\n        return\n      }\n    }\n    \n    var target_url = \"ws://\" + document.location.host + urlPrefix + \"/\" + protocol + \"?hostname=\" + hostname + \"&port=\" + port + \"&user=\" + encodeURIComponent(user) + \"&password=\" + encodeURIComponent(password) + \"&debug=\" + is_debug\n    if (\"replay\" == protocol) {\n        target_url = \"ws://\" + document.location.host + urlPrefix + \"/\" + protocol + \"?file=\" + encodeURIComponent(file) + \"&user=\" + encodeURIComponent(user) + \"&password=\" + encodeURIComponent(password)\n    } else if (\"ssh_exec\" == protocol) {\n        target_url = \"ws://\" + document.location.host + urlPrefix + \"/\" + protocol + \"?dump_file=\" + encodeURIComponent(file) + \"&hostname=\" + hostname + \"&port=\" + port + \"&user=\" + encodeURIComponent(user) + \"&password=\" + encodeURIComponent(password) + \"&cmd=\" + encodeURIComponent(cmd) + \"&debug=\" + is_debug\n    }\n\n    createTerminal(target_url);\n}\n\nfunction createTerminal(targetUrl) {\n  // Clean terminal\n  while (terminalContainer.children.length) {\n    terminalContainer.removeChild(terminalContainer.children[0]);\n  }\n  term = new Terminal({\n    cursorBlink: optionElements.cursorBlink.checked,\n    scrollback: parseInt(optionElements.scrollback.value, 10),\n    tabStopWidth: parseInt(optionElements.tabstopwidth.value, 10)\n  });\n  term.on('resize', function (size) {\n    //if (!pid) {\n    //  return;\n    //}\n    //var cols = size.cols,\n    //    rows = size.rows,\n    //    url = '/terminals/' + pid + '/size?cols=' + cols + '&rows=' + rows;\n\n    //fetch(url, {method: 'POST'});\n  });\n\n  term.open(terminalContainer);\n  term.fit();\n\n  // fit is called within a setTimeout, cols and rows need this.\n  setTimeout(function () {\n    colsElement.value = term.cols;\n    rowsElement.value = term.rows;\n\n    // Set terminal size again to set the specific dimensions on the demo\n    setTerminalSize();\n\n    socket = new WebSocket(targetUrl + '&columns=' + term.cols + '&rows=' + term.rows);\n    socket.onopen = function() {\n      term.attach(socket);\n      term._initialized = true;\n    };\n    socket.onclose = function() {\n      //term.destroy();\n    };\n    socket.onerror = function() {\n      alert(\"连接出错！\");\n    };\n  }, 0);\n}\n\nwindow.addEventListener('load', function () {\n    if (undefined == protocol || null == protocol || \"\" == protocol) {\n        protocol = \"ssh\"\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"22\"\n        }\n    } else if (\"telnet\" == protocol) {\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"23\"\n        }\n    } else if (\"ssh\" == protocol) {\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"22\"\n        }\n    }\n\n    if (\"replay\" == protocol) {\n        if (undefined == file || null == file || \"\" == file) {\n            alert(\"file is empty.\")\n            return\n        }\n    } else {\n        if (undefined == hostname || null == hostname || \"\" == hostname) {\n            alert(\"hostname is empty.\")\n            return\n        }\n    }\n\n    if(undefined != urlPrefix && null != urlPrefix && \"\" != urlPrefix) {\n      if (urlPrefix[urlPrefix.length-1] == \"/\") {\n        urlPrefix = urlPrefix.substr(0, urlPrefix.length-1)\n      }\n    }\n\n    if(undefined != urlPrefix && null != urlPrefix && \"\" != urlPrefix) {\n      if (urlPrefix.indexOf(\"/\") != 0) {\n        urlPrefix = \"/\" + urlPrefix\n      }\n    }\n\n    connect()\n}, false);"),
+		Content: string("\nTerminal.applyAddon(attach);\nTerminal.applyAddon(fit);\nTerminal.applyAddon(fullscreen);\nTerminal.applyAddon(search);\nTerminal.applyAddon(webLinks);\nTerminal.applyAddon(winptyCompat);\n\nvar term,\n    socket\n\n// 会话恢复：服务端发送的恢复令牌和已收到的输出字节数，断线后在宽限期内重新连接\nvar resumeState = {\n      url: null,\n      token: null,\n      offset: 0,\n      grace: 0,\n      deadline: 0\n    }\n\n// 会话共享：本连接的角色（owner、readwrite 或 readonly）和参与者 id\nvar shareState = {\n      role: null,\n      id: null\n    }\n\nvar terminalContainer = document.getElementById('terminal-container'),\n    actionElements = {\n      findText: document.getElementById('find-text'),\n      findNext: document.getElementById('find-next'),\n      findPrevious: document.getElementById('find-previous'),\n      toggleOptions: document.getElementById('toggle-options'),\n    },\n    loginElements = {\n      user: document.getElementById('userName'),\n      password: document.getElementById('password'),\n      login: document.getElementById('ssh-login'),\n    },\n    optionElements = {\n      cursorBlink: document.getElementById('option-cursor-blink'),\n      cursorStyle: document.getElementById('option-cursor-style'),\n      scrollback: document.getElementById('option-scrollback'),\n      tabstopwidth: document.getElementById('option-tabstopwidth'),\n      bellStyle: document.getElementById('option-bell-style')\n    },\n    colsElement = document.getElementById('cols'),\n    rowsElement = document.getElementById('rows');\n\n\nvar urlPrefix = getQueryStringByName(\"url_prefix\")\nvar protocol = getQueryStringByName(\"protocol\")\nvar hostname = decodeURIComponent(getQueryStringByName(\"hostname\"))\nvar file = getQueryStringByName(\"file\")\nvar recordingId = getQueryStringByName(\"id\")\nvar port = getQueryStringByName(\"port\")\nvar cmd = getQueryStringByName(\"cmd\")\nvar is_debug = getQueryStringByName(\"debug\")\nvar user = decodeURIComponent(getQueryStringByName(\"user\"))\nvar password = decodeURIComponent(getQueryStringByName(\"password\"))\nvar token = decodeURIComponent(getQueryStringByName(\"token\"))\nvar profile = getQueryStringByName(\"profile\")\nvar hostAlias = getQueryStringByName(\"host\")\nvar shareToken = getQueryStringByName(\"share\")\nvar terminalTypes = decodeURIComponent(getQueryStringByName(\"ttype\"))\n\n//根据QueryString参数名称获取值\nfunction getQueryStringByName(name) {\n  var result = location.search.match(new RegExp(\"[\\?\\&]\" + name + \"=([^\\&]+)\", \"i\"));\n  if (result == null || result.length < 1) {\n      return \"\";\n  }\n  return result[1];\n}\n\nfunction startsWith(s, prefix) {\n  return s.indexOf(prefix) == 0;\n}\n\nfunction changeClassList(ele, add, del) {\n    var klsList = ele.classList;\n    klsList.add(add);\n    klsList.remove(del);\n}\n\nfunction toggleLogin() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(optionsEl, \"hide\", \"active\")\n    \n    var klsList = loginEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(loginEl, \"active\", \"hide\")\n    } else {\n      changeClassList(loginEl, \"hide\", \"active\")\n    }\n}\n\nfunction toggleLogin() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(optionsEl, \"hide\", \"active\")\n    \n    var klsList = loginEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(loginEl, \"active\", \"hide\")\n    } else {\n      changeClassList(loginEl, \"hide\", \"active\")\n    }\n}\n\n\nfunction toggleOptions() {\n    var loginEl = document.getElementById(\"login\");\n    var optionsEl = document.getElementById(\"options\");\n\n    changeClassList(loginEl, \"hide\", \"active\")\n\n    var klsList = optionsEl.classList;\n    if (klsList.contains(\"hide\")) {\n      changeClassList(optionsEl, \"active\", \"hide\")\n    } else {\n      changeClassList(optionsEl, \"hide\", \"active\")\n    }\n}\n\nactionElements.findNext.addEventListener('click', function() {\n    term.findNext(actionElements.findText.value);\n});\nactionElements.findPrevious.addEventListener('click', function() {\n    term.findPrevious(actionElements.findText.value);\n});\nactionElements.toggleOptions.addEventListener('click',  function() {\n  toggleOptions();\n});\nloginElements.login.addEventListener('click', function() {\n    user = loginElements.user.value;\n    password = loginElements.password.value;\n\n    toggleLogin();\n    connect();\n});\n\nfunction setTerminalSize() {\n  var cols = parseInt(colsElement.value, 10);\n  var rows = parseInt(rowsElement.value, 10);\n  var viewportElement = document.querySelector('.xterm-viewport');\n  var scrollBarWidth = viewportElement.offsetWidth - viewportElement.clientWidth;\n  var width = (cols * term.charMeasure.width + 20 /*room for scrollbar*/).toString() + 'px';\n  var height = (rows * term.charMeasure.height).toString() + 'px';\n\n  terminalContainer.style.width = width;\n  terminalContainer.style.height = height;\n  term.resize(cols, rows);\n}\n\ncolsElement.addEventListener('change', setTerminalSize);\nrowsElement.addEventListener('change', setTerminalSize);\n\n\noptionElements.cursorBlink.addEventListener('change', function () {\n  term.setOption('cursorBlink', optionElements.cursorBlink.checked);\n});\noptionElements.cursorStyle.addEventListener('change', function () {\n  term.setOption('cursorStyle', optionElements.cursorStyle.value);\n});\noptionElements.bellStyle.addEventListener('change', function () {\n  term.setOption('bellStyle', optionElements.bellStyle.value);\n});\noptionElements.scrollback.addEventListener('change', function () {\n  term.setOption('scrollback', parseInt(optionElements.scrollback.value, 10));\n});\noptionElements.tabstopwidth.addEventListener('change', function () {\n  term.setOption('tabStopWidth', parseInt(optionElements.tabstopwidth.value, 10));\n});\n\nfunction connect() {\n    if (shareToken) {\n        // 通过共享链接加入他人的 ssh 会话\n        createTerminal(\"ws://\" + document.location.host + urlPrefix + \"/ssh?share=\" + encodeURIComponent(shareToken));\n        return\n    }\n    if ((profile || hostAlias) && (\"ssh\" == protocol || \"ssh_exec\" == protocol || \"forward\" == protocol)) {\n        // 使用服务端保存的主机配置或 ssh config 中的主机别名，无需凭据\n        var profile_url = \"ws://\" + document.location.host + urlPrefix + \"/\" + protocol\n        if (profile) {\n            profile_url += \"?profile=\" + profile\n        } else {\n            profile_url += \"?host=\" + hostAlias\n        }\n        profile_url += \"&debug=\" + is_debug\n        if (\"ssh_exec\" == protocol) {\n            profile_url += \"&dump_file=\" + encodeURIComponent(file) + \"&cmd=\" + encodeURIComponent(cmd)\n        }\n        if (\"forward\" == protocol) {\n            openForward(profile_url);\n            return\n        }\n        createTerminal(profile_url);\n        return\n    }\n    // 密码为空时由服务端弹出密码对话框\n    if(protocol == \"ssh\" || protocol == \"forward\") {\n      if (undefined == user || null == user || \"\" == user) {\n        toggleLogin()\n        return\n      }\n    }\n    \n    var base_url = \"ws://\" + document.location.host + urlPrefix + \"/\" + protocol\n    if (\"replay\" == protocol) {\n        createTerminal(base_url + \"?id=\" + encodeURIComponent(recordingId));\n        return\n    }\n    if (\"local\" == protocol) {\n        // 本地伪终端，cmd 参数是 -local_allow 允许的程序，默认运行 shell\n        createTerminal(base_url + \"?exec=\" + encodeURIComponent(cmd || \"\"));\n        return\n    }\n    if (\"telnet\" != protocol && \"ssh\" != protocol && \"ssh_exec\" != protocol && \"forward\" != protocol) {\n        createTerminal(base_url + \"?debug=\" + is_debug);\n        return\n    }\n\n    // 凭据通过 POST 换取一次性票据，不出现在 websocket 地址中\n    requestTicket({\n        protocol: protocol,\n        hostname: hostname,\n        port: parseInt(port, 10) || 0,\n        user: user,\n        password: password,\n        terminalTypes: terminalTypes\n    }, function (ticket) {\n        var target_url = base_url + \"?ticket=\" + encodeURIComponent(ticket) + \"&debug=\" + is_debug\n        if (\"ssh_exec\" == protocol) {\n            target_url += \"&dump_file=\" + encodeURIComponent(file) + \"&cmd=\" + encodeURIComponent(cmd)\n        }\n        if (\"forward\" == protocol) {\n            openForward(target_url);\n            return\n        }\n        createTerminal(target_url);\n    });\n}\n\nfunction requestTicket(params, callback) {\n    var xhr = new XMLHttpRequest();\n    var url = urlPrefix + \"/ticket\";\n    if (token) {\n        url += \"?token=\" + encodeURIComponent(token);\n    }\n    xhr.open(\"POST\", url, true);\n    xhr.setRequestHeader(\"Content-Type\", \"application/json\");\n    xhr.onload = function () {\n        var result = {};\n        try {\n            result = JSON.parse(xhr.responseText);\n        } catch (e) {\n            result.error = xhr.responseText;\n        }\n        if (xhr.status != 200 || !result.ticket) {\n            alert(\"获取连接票据失败：\" + (result.error || xhr.status));\n            return\n        }\n        callback(result.ticket);\n    };\n    xhr.onerror = function () {\n        alert(\"获取连接票据失败！\");\n    };\n    xhr.send(JSON.stringify(params));\n}\n\nfunction createTerminal(targetUrl) {\n  // Clean terminal\n  while (terminalContainer.children.length) {\n    terminalContainer.removeChild(terminalContainer.children[0]);\n  }\n  term = new Terminal({\n    cursorBlink: optionElements.cursorBlink.checked,\n    scrollback: parseInt(optionElements.scrollback.value, 10),\n    tabStopWidth: parseInt(optionElements.tabstopwidth.value, 10)\n  });\n  term.on('resize', function (size) {\n    //if (!pid) {\n    //  return;\n    //}\n    //var cols = size.cols,\n    //    rows = size.rows,\n    //    url = '/terminals/' + pid + '/size?cols=' + cols + '&rows=' + rows;\n\n    //fetch(url, {method: 'POST'});\n  });\n\n  term.open(terminalContainer);\n  term.fit();\n\n  // fit is called within a setTimeout, cols and rows need this.\n  setTimeout(function () {\n    colsElement.value = term.cols;\n    rowsElement.value = term.rows;\n\n    // Set terminal size again to set the specific dimensions on the demo\n    setTerminalSize();\n\n    if (token) {\n      targetUrl += '&token=' + encodeURIComponent(token);\n    }\n    resumeState.url = targetUrl.split('?')[0];\n    resumeState.token = null;\n    openSocket(targetUrl + '&columns=' + term.cols + '&rows=' + term.rows, false);\n  }, 0);\n}\n\nfunction openSocket(targetUrl, resuming) {\n  // 使用 web-terminal.v1 消息格式，不声明子协议的旧客户端收发原始文本\n  socket = new WebSocket(targetUrl, [\"web-terminal.v1\"]);\n  socket.onopen = function() {\n    attachMessageSocket(term, socket);\n    term._initialized = true;\n  };\n  socket.onclose = function(ev) {\n    // 1000 是服务端正常关闭（会话结束或被其他连接接管），其他情况视为网络中断\n    if (ev.code != 1000 && resumeState.token) {\n      if (!resuming) {\n        resumeState.deadline = Date.now() + resumeState.grace * 1000;\n        term.write(\"\\r\\n\\x1b[33m[connection interrupted, reconnecting...]\\x1b[0m\\r\\n\");\n      }\n      setTimeout(resumeSocket, 2000);\n    }\n  };\n  socket.onerror = function() {\n    if (!resuming) {\n      alert(\"连接出错！\");\n    }\n  };\n}\n\nfunction resumeSocket() {\n  if (Date.now() > resumeState.deadline) {\n    term.write(\"\\r\\n\\x1b[31m[the session expired]\\x1b[0m\\r\\n\");\n    return\n  }\n  var url = resumeState.url + \"?resume=\" + encodeURIComponent(resumeState.token) + \"&offset=\" + resumeState.offset;\n  if (token) {\n    url += '&token=' + encodeURIComponent(token);\n  }\n  openSocket(url, true);\n}\n\nfunction encodeData(str) {\n  var bytes = new TextEncoder().encode(str);\n  var binary = \"\";\n  for (var i = 0; i < bytes.length; i++) {\n    binary += String.fromCharCode(bytes[i]);\n  }\n  return btoa(binary);\n}\n\nfunction decodeData(data) {\n  var binary = atob(data);\n  var bytes = new Uint8Array(binary.length);\n  for (var i = 0; i < binary.length; i++) {\n    bytes[i] = binary.charCodeAt(i);\n  }\n  return bytes;\n}\n\n// handleControl 处理 prompt、session 等控制消息，不是控制消息时返回 false\nfunction handleControl(msg, socket) {\n  switch (msg.type) {\n  case \"prompt\":\n    showPrompt(msg, socket);\n    break;\n  case \"algorithms\":\n    showAlgorithms(msg);\n    break;\n  case \"terminal_type\":\n    // telnet 协商的终端类型，服务端可能依次询问多个类型\n    document.getElementById('algorithms').textContent = \"TERM=\" + msg.name;\n    break;\n  case \"connection_lost\":\n    // 主机不再响应 keepalive，连接已断开\n    term.write(\"\\r\\n\\x1b[31m\" + msg.reason + \"\\x1b[0m\\r\\n\");\n    resumeState.token = null;\n    break;\n  case \"session\":\n  case \"resumed\":\n    resumeState.token = msg.token;\n    resumeState.offset = msg.offset;\n    resumeState.grace = msg.grace;\n    setShareRole(msg.role, null);\n    break;\n  case \"joined\":\n    setShareRole(msg.role, msg.id);\n    break;\n  case \"presence\":\n    showParticipants(msg.participants);\n    break;\n  case \"share\":\n    showShareLink(msg);\n    break;\n  case \"input_denied\":\n    term.write(\"\\r\\n\\x1b[33m[\" + msg.reason + (msg.controller ? \": \" + msg.controller : \"\") + \"]\\x1b[0m\\r\\n\");\n    break;\n  default:\n    return false;\n  }\n  return true;\n}\n\n// attachMessageSocket 使用 JSON 消息：{\"v\":1,\"type\":\"stdin|stdout|stderr|resize|signal|exit\",\"data\":\"base64\"}，其他类型是控制消息\nfunction attachMessageSocket(term, socket) {\n  var decoder = new TextDecoder();\n  var send = function (msg) {\n    if (socket.readyState == 1) {\n      msg.v = 1;\n      socket.send(JSON.stringify(msg));\n    }\n  };\n  var onData = function (data) {\n    send({type: \"stdin\", data: encodeData(data)});\n  };\n  var onResize = function (size) {\n    send({type: \"resize\", cols: size.cols, rows: size.rows});\n  };\n  term.on('data', onData);\n  term.on('resize', onResize);\n  socket.addEventListener('message', function (ev) {\n    var msg = JSON.parse(ev.data);\n    if (handleControl(msg, socket)) {\n      return\n    }\n    var data = \"\";\n    if (msg.data) {\n      var bytes = decodeData(msg.data);\n      if (\"stdout\" == msg.type) {\n        // 恢复会话时从该位置重放输出\n        resumeState.offset += bytes.length;\n      }\n      data = decoder.decode(bytes, {stream: \"exit\" != msg.type});\n    }\n    switch (msg.type) {\n    case \"exit\":\n      term.write(\"\\r\\n\\x1b[33m[\" + data + \"]\\x1b[0m\\r\\n\");\n      break;\n    case \"alert\":\n      alert(data);\n      break;\n    case \"stdout\":\n    case \"stderr\":\n    case \"console\":\n      term.write(data);\n      break;\n    }\n  });\n  socket.addEventListener('close', function () {\n    term.off('data', onData);\n    term.off('resize', onResize);\n  });\n  onResize({cols: term.cols, rows: term.rows});\n}\n\n// setShareRole 根据角色显示共享或接管输入按钮\nfunction setShareRole(role, id) {\n  shareState.role = role;\n  shareState.id = id;\n  var shareActions = document.getElementById('share-actions');\n  var takeControl = document.getElementById('take-control');\n  if (\"owner\" == role) {\n    changeClassList(shareActions, \"active\", \"hide\");\n  } else {\n    changeClassList(shareActions, \"hide\", \"active\");\n  }\n  if (\"readonly\" == role) {\n    changeClassList(takeControl, \"hide\", \"active\");\n  } else {\n    changeClassList(takeControl, \"active\", \"hide\");\n  }\n}\n\nfunction sendControl(control) {\n  if (socket && socket.readyState == 1) {\n    control.v = 1;\n    socket.send(JSON.stringify(control));\n  }\n}\n\ndocument.getElementById('share-readonly').addEventListener('click', function () {\n  sendControl({type: \"share\", role: \"readonly\"});\n});\ndocument.getElementById('share-readwrite').addEventListener('click', function () {\n  sendControl({type: \"share\", role: \"readwrite\"});\n});\ndocument.getElementById('take-control').addEventListener('click', function () {\n  sendControl({type: \"control\"});\n  term.focus();\n});\n\n// telnet 的 BRK、IP 和 AO 命令\nfunction sendTelnetSignal(signal) {\n  sendControl({type: \"signal\", signal: signal});\n  term.focus();\n}\n\ndocument.getElementById('send-break').addEventListener('click', function () {\n  sendTelnetSignal(\"BRK\");\n});\ndocument.getElementById('send-ip').addEventListener('click', function () {\n  sendTelnetSignal(\"INT\");\n});\ndocument.getElementById('send-ao').addEventListener('click', function () {\n  sendTelnetSignal(\"AO\");\n});\n\n// 显示共享链接，链接在过期、会话结束或被撤销前有效\nfunction showShareLink(share) {\n  if (share.error) {\n    alert(\"共享失败：\" + share.error);\n    return\n  }\n  var url = document.location.origin + document.location.pathname + \"?protocol=ssh&share=\" + encodeURIComponent(share.token);\n  if (urlPrefix) {\n    url += \"&url_prefix=\" + encodeURIComponent(urlPrefix);\n  }\n  var label = (\"readonly\" == share.role ? \"只读\" : \"协作\") + \"共享链接\";\n  if (share.expires) {\n    label += \"（\" + new Date(share.expires).toLocaleString() + \" 前有效）\";\n  }\n  window.prompt(label + \"：\", url);\n}\n\n// 显示会话参与者，* 表示当前的输入者，会话所有者可以踢出其他参与者\nfunction showParticipants(participants) {\n  var el = document.getElementById('participants');\n  while (el.children.length) {\n    el.removeChild(el.children[0]);\n  }\n  for (var i = 0; i < participants.length; i++) {\n    var p = participants[i];\n    var item = document.createElement('span');\n    item.style.marginRight = \"6px\";\n    item.textContent = (p.control ? \"*\" : \"\") + (p.name || \"anonymous\") + \"(\" + p.role + \")\";\n    if (p.id == shareState.id) {\n      item.style.fontWeight = \"bold\";\n    }\n    if (\"owner\" == shareState.role && \"owner\" != p.role) {\n      var revoke = document.createElement('a');\n      revoke.href = \"javascript:void(0)\";\n      revoke.textContent = \"×\";\n      revoke.title = \"撤销\";\n      revoke.onclick = (function (id) {\n        return function () {\n          sendControl({type: \"revoke\", id: id});\n        };\n      })(p.id);\n      item.appendChild(revoke);\n    }\n    el.appendChild(item);\n  }\n}\n\n// 端口转发：不打开终端，在页面上启动、列出和停止远程(-R)和动态(-D)转发\nvar forwardElements = {\n      panel: document.getElementById('forward-panel'),\n      type: document.getElementById('forward-type'),\n      listen: document.getElementById('forward-listen'),\n      target: document.getElementById('forward-target'),\n      start: document.getElementById('forward-start'),\n      error: document.getElementById('forward-error'),\n      list: document.getElementById('forward-list')\n    }\n\nfunction openForward(targetUrl) {\n  if (token) {\n    targetUrl += '&token=' + encodeURIComponent(token);\n  }\n  changeClassList(forwardElements.panel, \"active\", \"hide\");\n  var timer = null;\n  socket = new WebSocket(targetUrl, [\"web-terminal.v1\"]);\n  socket.onopen = function () {\n    sendControl({type: \"stats\"});\n    timer = setInterval(function () {\n      sendControl({type: \"stats\"});\n    }, 2000);\n  };\n  socket.onmessage = function (ev) {\n    if (\"string\" != typeof ev.data) {\n      return\n    }\n    var msg = JSON.parse(ev.data);\n    switch (msg.type) {\n    case \"prompt\":\n      showPrompt(msg, socket);\n      break;\n    case \"stats\":\n      showForwards(msg.forwards || []);\n      break;\n    case \"started\":\n    case \"stopped\":\n      forwardElements.error.textContent = \"\";\n      sendControl({type: \"stats\"});\n      break;\n    case \"error\":\n      forwardElements.error.textContent = (msg.target || msg.listen || msg.id || \"\") + \" \" + msg.error;\n      break;\n    case \"stderr\":\n      forwardElements.error.textContent = new TextDecoder().decode(decodeData(msg.data));\n      break;\n    }\n  };\n  socket.onclose = function () {\n    clearInterval(timer);\n    forwardElements.start.disabled = true;\n    forwardElements.error.textContent += \" [connection closed]\";\n  };\n}\n\nforwardElements.type.addEventListener('change', function () {\n  forwardElements.target.disabled = \"dynamic\" == forwardElements.type.value;\n});\nforwardElements.start.addEventListener('click', function () {\n  var msg = {type: forwardElements.type.value, listen: forwardElements.listen.value};\n  if (\"remote\" == msg.type) {\n    msg.target = forwardElements.target.value;\n  }\n  sendControl(msg);\n});\n\nfunction formatBytes(n) {\n  if (n >= 1048576) {\n    return (n / 1048576).toFixed(1) + \"MB\";\n  }\n  if (n >= 1024) {\n    return (n / 1024).toFixed(1) + \"KB\";\n  }\n  return n + \"B\";\n}\n\n// 列出 remote 和 dynamic 转发，本地转发(-L)的连接由其它客户端通过 open 消息建立\nfunction showForwards(forwards) {\n  var el = forwardElements.list;\n  while (el.children.length) {\n    el.removeChild(el.children[0]);\n  }\n  for (var i = 0; i < forwards.length; i++) {\n    var f = forwards[i];\n    if (\"local\" == f.type) {\n      continue\n    }\n    var row = document.createElement('tr');\n    var cells = [f.type, f.listen, f.target || \"SOCKS5\", f.connections + \"/\" + f.total, formatBytes(f.bytesIn), formatBytes(f.bytesOut)];\n    for (var j = 0; j < cells.length; j++) {\n      var td = document.createElement('td');\n      td.textContent = cells[j];\n      row.appendChild(td);\n    }\n    var stop = document.createElement('button');\n    stop.textContent = \"停止\";\n    stop.onclick = (function (id) {\n      return function () {\n        sendControl({type: \"stop\", id: id});\n      };\n    })(f.id);\n    var td = document.createElement('td');\n    td.appendChild(stop);\n    row.appendChild(td);\n    el.appendChild(row);\n  }\n}\n\n// 显示与主机协商的算法\nfunction showAlgorithms(algorithms) {\n  var el = document.getElementById('algorithms');\n  var summary = [algorithms.kex, algorithms.cipher];\n  if (algorithms.mac) {\n    summary.push(algorithms.mac);\n  }\n  el.textContent = summary.join(\" / \");\n  el.title = \"host: \" + algorithms.host +\n    \"\\nkex: \" + algorithms.kex +\n    \"\\nhost key: \" + algorithms.hostKey +\n    \"\\ncipher: \" + algorithms.cipher + (algorithms.serverCipher && algorithms.serverCipher != algorithms.cipher ? \" / \" + algorithms.serverCipher : \"\") +\n    \"\\nmac: \" + (algorithms.mac || \"(aead)\") + (algorithms.serverMac && algorithms.serverMac != algorithms.mac ? \" / \" + algorithms.serverMac : \"\");\n}\n\nfunction showPrompt(prompt, socket) {\n  var form = document.getElementById('prompt');\n  var questionsEl = document.getElementById('prompt-questions');\n  var title = prompt.host || \"\";\n  if (prompt.user) {\n    title = prompt.user + \"@\" + title;\n  }\n  document.getElementById('prompt-title').textContent = prompt.name || title;\n  document.getElementById('prompt-error').textContent = prompt.error || \"\";\n  document.getElementById('prompt-instruction').textContent = prompt.instruction || \"\";\n  while (questionsEl.children.length) {\n    questionsEl.removeChild(questionsEl.children[0]);\n  }\n  var inputs = [];\n  if (\"confirm\" != prompt.kind) {\n    for (var i = 0; i < prompt.questions.length; i++) {\n      var label = document.createElement('label');\n      var input = document.createElement('input');\n      input.type = prompt.questions[i].echo ? \"text\" : \"password\";\n      input.autocomplete = \"off\";\n      label.appendChild(document.createTextNode(prompt.questions[i].text + \" \"));\n      label.appendChild(input);\n      var p = document.createElement('p');\n      p.appendChild(label);\n      questionsEl.appendChild(p);\n      inputs.push(input);\n    }\n  } else if (prompt.questions.length > 0) {\n    questionsEl.textContent = prompt.questions[0].text;\n  }\n\n  function reply(answer) {\n    form.onsubmit = null;\n    document.getElementById('prompt-cancel').onclick = null;\n    changeClassList(form, \"hide\", \"active\");\n    answer.v = 1;\n    socket.send(JSON.stringify(answer));\n    if (term) {\n      term.focus();\n    }\n  }\n  form.onsubmit = function () {\n    var answers = [];\n    if (\"confirm\" == prompt.kind) {\n      answers.push(\"yes\");\n    } else {\n      for (var i = 0; i < inputs.length; i++) {\n        answers.push(inputs[i].value);\n      }\n    }\n    reply({type: \"prompt_answer\", id: prompt.id, answers: answers});\n    return false;\n  };\n  document.getElementById('prompt-cancel').onclick = function () {\n    if (\"confirm\" == prompt.kind) {\n      reply({type: \"prompt_answer\", id: prompt.id, answers: [\"no\"]});\n    } else {\n      reply({type: \"prompt_answer\", id: prompt.id, cancel: true});\n    }\n  };\n  changeClassList(form, \"active\", \"hide\");\n  if (inputs.length > 0) {\n    inputs[0].focus();\n  }\n}\n\nwindow.addEventListener('load', function () {\n    if (undefined == protocol || null == protocol || \"\" == protocol) {\n        protocol = \"ssh\"\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"22\"\n        }\n    } else if (\"telnet\" == protocol) {\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"23\"\n        }\n        changeClassList(document.getElementById('telnet-actions'), \"active\", \"hide\");\n    } else if (\"ssh\" == protocol || \"forward\" == protocol) {\n        if (undefined == port || null == port || \"\" == port) {\n            port = \"22\"\n        }\n    }\n\n    if (\"replay\" == protocol) {\n        if (undefined == recordingId || null == recordingId || \"\" == recordingId) {\n            alert(\"id is empty.\")\n            return\n        }\n    } else if (!profile) {\n        if (undefined == hostname || null == hostname || \"\" == hostname) {\n            alert(\"hostname is empty.\")\n            return\n        }\n    }\n\n    if(undefined != urlPrefix && null != urlPrefix && \"\" != urlPrefix) {\n      if (urlPrefix[urlPrefix.length-1] == \"/\") {\n        urlPrefix = urlPrefix.substr(0, urlPrefix.length-1)\n      }\n    }\n\n    if(undefined != urlPrefix && null != urlPrefix && \"\" != urlPrefix) {\n      if (urlPrefix.indexOf(\"/\") != 0) {\n        urlPrefix = \"/\" + urlPrefix\n      }\n    }\n\n    connect()\n}, false);"),
 	}
 	filev := &embedded.EmbeddedFile{
 		Filename:    "terminal.html",
-		FileModTime: time.Unix(1532625437, 0),
+		FileModTime: time.Unix(1792318226, 0),
 
-		Content: string("<!doctype html>\n<html>\n<head>\n    <meta name=\"author\" content=\"runner.mei@gmail.com\"/>\n    <title>Simple TTY</title>\n    <link rel=\"shortcut icon\" href=\"/static/favicon.ico\">\n    <style>\n        body {\n            margin-top: 0;\n            font-family: helvetica, sans-serif, arial;\n            font-size: 14px;\n            color: #111;\n        }\n\n        h1 {\n            text-align: center;\n        }\n\n        #terminal-container {\n            width: 800px;\n            height: 450px;\n            margin: 0 auto;\n            padding: 2px;\n        }\n\n        #options, #login {\n            width: 300px;\n            /*-webkit-transition: height .5s;*/\n            /*-moz-transition: height .5s;*/\n            /*-o-transition: height .5s;*/\n        }\n\n        #options.active {\n            margin: 0;\n            height: 350px;\n        }\n\n        #login.active {\n            margin: 0;\n            height: 200px;\n        }\n\n        .hide {\n            display: none;\n        }\n\n    </style>\n\n    <link rel=\"stylesheet\" href=\"./xterm.css\"/>\n    <link rel=\"stylesheet\" href=\"./addons/fullscreen/fullscreen.css\"/>\n    <script src=\"./xterm.js\"></script>\n    <script src=\"./addons/attach/attach.js\"></script>\n    <!-- <script src=\"./zmodem.js\"></script>\n    <script src=\"./addons/zmodem/zmodem.js\" ></script> -->\n    <script src=\"./addons/fit/fit.js\"></script>\n    <script src=\"./addons/fullscreen/fullscreen.js\"></script>\n    <script src=\"./addons/search/search.js\"></script>\n    <script src=\"./addons/webLinks/webLinks.js\"></script>\n    <script src=\"./addons/winptyCompat/winptyCompat.js\"></script>\n</head>\n<body>\n<div style=\"overflow:hidden\">\n    <div style=\"float:right;\">\n        <p style=\"margin: 3px;height: 25px;line-height: 20px\">\n            <label><input id=\"find-text\"/></label>\n            <button id=\"find-next\">查找</button>\n            <button id=\"find-previous\">向前</button>\n            <button id=\"toggle-options\">选项</button>\n            <button onclick=\"toggleLogin()\">登录</button>\n        </p>\n        <div id=\"login\" class=\"hide\">\n            <h2 style=\"margin-top:0\">请输入用户名和密码</h2>\n            <p>\n                <label>用户名 <input type=\"text\" id=\"userName\"> </label>\n            </p>\n            <p>\n                <label>密码 <input type=\"password\" id=\"password\"></label>\n            </p>\n            <button id=\"ssh-login\">确认</button>\n        </div>\n        <div id=\"options\" class=\"hide\">\n            <h2 style=\"margin-top:0\">选项</h2>\n            <p>\n                <label><input type=\"checkbox\" id=\"option-cursor-blink\"> 光标闪烁</label>\n            </p>\n            <p>\n                <label>\n                    光标样式\n                    <select id=\"option-cursor-style\">\n                        <option value=\"block\">block</option>\n                        <option value=\"underline\">underline</option>\n                        <option value=\"bar\">bar</option>\n                    </select>\n                </label>\n            </p>\n            <p>\n                <label>\n                    铃声(试验性功能)\n                    <select id=\"option-bell-style\">\n                        <option value=\"\">none</option>\n                        <option value=\"sound\">sound</option>\n                        <option value=\"visual\">visual</option>\n                        <option value=\"both\">both</option>\n                    </select>\n                </label>\n            </p>\n            <p>\n                <label>屏幕缓冲区 <input type=\"number\" id=\"option-scrollback\" value=\"1000\"/></label>\n            </p>\n            <p>\n                <label>Tab 字符宽度 <input type=\"number\" id=\"option-tabstopwidth\" value=\"8\"/></label>\n            </p>\n            <div>\n                <h3>大小</h3>\n                <p>\n                    <label for=\"cols\">列</label>\n                    <input type=\"number\" id=\"cols\" value=\"80\"/>\n                </p>\n                <p>\n                    <label for=\"rows\">行</label>\n                    <input type=\"number\" id=\"rows\" value=\"32\"/>\n                </p>\n            </div>\n        </div>\n    </div>\n</div>\n<div id=\"terminal-container\"></div>\n<div id=\"zmodem_controls\">\n    <form id=\"zm_start\" style=\"display: none\" action=\"javascript:void(0)\">\n        ZMODEM detected: Start ZMODEM session?\n        <label><input id=\"zmstart_yes\" name=\"zmstart\" type=radio checked value=\"1\"> Yes</label>\n        &nbsp;\n        <label><input name=\"zmstart\" type=radio value=\"\"> No</label>\n        <button type=\"submit\">Submit</button>\n    </form>\n\n    <form id=\"zm_offer\" style=\"display: none\" action=\"javascript:void(0)\">\n        <p>ZMODEM File offered!</p>\n\n        <label><input id=\"zmaccept_yes\" name=\"zmaccept\" type=radio checked value=\"1\"> Accept</label>\n        &nbsp;\n        <label><input name=\"zmaccept\" type=radio value=\"\"> Skip</label>\n        <button type=\"submit\">Submit</button>\n    </form>\n\n    <div id=\"zm_file\" style=\"display: none\">\n        <div>Name: <span id=\"name\"></span></div>\n        <div>Size: <span id=\"size\"></span></div>\n        <div>Last modified: <span id=\"mtime\"></span></div>\n        <div>Mode: <span id=\"mode\"></span></div>\n        <br>\n        <div>Conversion: <span id=\"zfile_conversion\"></span></div>\n        <div>Management: <span id=\"zfile_management\"></span></div>\n        <div>Transport: <span id=\"zfile_transport\"></span></div>\n        <div>Sparse? <span id=\"zfile_sparse\"></span></div>\n        <br>\n        <div>Files remaining in batch: <span id=\"files_remaining\"></span></div>\n        <div>Bytes remaining in batch: <span id=\"bytes_remaining\"></span></div>\n    </div>\n\n    <form id=\"zm_progress\" style=\"display: none\" action=\"javascript:void(0)\">\n        <div><span id=\"percent_received\"></span>% (<span id=\"bytes_received\"></span> bytes) received</div>\n        <button id=\"zm_progress_skipper\" type=\"button\" onclick=\"skip_current_file();\">Skip File</button>\n    </form>\n\n    <form id=\"zm_choose\" style=\"display: none\" action=\"javascript:void(0)\">\n        <label>Choose file(s): <input id=\"zm_files\" type=\"file\" multiple></label>\n    </form>\n</div>\n<script src=\"./main.js\"></script>\n</body>\n</html>\n"),
+		Content: string("<!doctype html>\n<html>\n<head>\n    <meta name=\"author\" content=\"runner.mei@gmail.com\"/>\n    <title>Simple TTY</title>\n    <link rel=\"shortcut icon\" href=\"/static/favicon.ico\">\n    <style>\n        body {\n            margin-top: 0;\n            font-family: helvetica, sans-serif, arial;\n            font-size: 14px;\n            color: #111;\n        }\n\n        h1 {\n            text-align: center;\n        }\n\n        #terminal-container {\n            width: 800px;\n            height: 450px;\n            margin: 0 auto;\n            padding: 2px;\n        }\n\n        #options, #login {\n            width: 300px;\n            /*-webkit-transition: height .5s;*/\n            /*-moz-transition: height .5s;*/\n            /*-o-transition: height .5s;*/\n        }\n\n        #options.active {\n            margin: 0;\n            height: 350px;\n        }\n\n        #login.active {\n            margin: 0;\n            height: 200px;\n        }\n\n        .hide {\n            display: none;\n        }\n\n        #prompt {\n            position: fixed;\n            top: 80px;\n            left: 50%;\n            width: 360px;\n            margin-left: -190px;\n            padding: 10px;\n            background: #fff;\n            border: 1px solid #999;\n            box-shadow: 0 2px 8px rgba(0, 0, 0, .3);\n            z-index: 100;\n        }\n\n        #prompt.hide {\n            display: none;\n        }\n\n        #prompt-error {\n            color: #c00;\n        }\n\n        #prompt-instruction {\n            white-space: pre-wrap;\n        }\n\n        #forward-panel {\n            width: 800px;\n            margin: 0 auto;\n        }\n\n        #forward-panel td, #forward-panel th {\n            padding: 2px 8px;\n            text-align: left;\n        }\n\n        #forward-error {\n            color: #c00;\n        }\n\n    </style>\n\n    <link rel=\"stylesheet\" href=\"./xterm.css\"/>\n    <link rel=\"stylesheet\" href=\"./addons/fullscreen/fullscreen.css\"/>\n    <script src=\"./xterm.js\"></script>\n    <script src=\"./addons/attach/attach.js\"></script>\n    <!-- <script src=\"./zmodem.js\"></script>\n    <script src=\"./addons/zmodem/zmodem.js\" ></script> -->\n    <script src=\"./addons/fit/fit.js\"></script>\n    <script src=\"./addons/fullscreen/fullscreen.js\"></script>\n    <script src=\"./addons/search/search.js\"></script>\n    <script src=\"./addons/webLinks/webLinks.js\"></script>\n    <script src=\"./addons/winptyCompat/winptyCompat.js\"></script>\n</head>\n<body>\n<div style=\"overflow:hidden\">\n    <div style=\"float:right;\">\n        <p style=\"margin: 3px;height: 25px;line-height: 20px\">\n            <span id=\"algorithms\" style=\"color: #888; font-size: 12px\"></span>\n            <span id=\"participants\" style=\"font-size: 12px\"></span>\n            <span id=\"share-actions\" class=\"hide\">\n                <button id=\"share-readonly\">只读共享</button>\n                <button id=\"share-readwrite\">协作共享</button>\n            </span>\n            <button id=\"take-control\" class=\"hide\">接管输入</button>\n            <span id=\"telnet-actions\" class=\"hide\">\n                <button id=\"send-break\" title=\"发送 BRK，例如进入串口服务器的菜单\">Break</button>\n                <button id=\"send-ip\" title=\"发送 Interrupt Process\">中断</button>\n                <button id=\"send-ao\" title=\"发送 Abort Output\">丢弃输出</button>\n            </span>\n            <label><input id=\"find-text\"/></label>\n            <button id=\"find-next\">查找</button>\n            <button id=\"find-previous\">向前</button>\n            <button id=\"toggle-options\">选项</button>\n            <button onclick=\"toggleLogin()\">登录</button>\n        </p>\n        <div id=\"login\" class=\"hide\">\n            <h2 style=\"margin-top:0\">请输入用户名和密码</h2>\n            <p>\n                <label>用户名 <input type=\"text\" id=\"userName\"> </label>\n            </p>\n            <p>\n                <label>密码 <input type=\"password\" id=\"password\"></label>\n            </p>\n            <button id=\"ssh-login\">确认</button>\n        </div>\n        <form id=\"prompt\" class=\"hide\" action=\"javascript:void(0)\">\n            <h2 id=\"prompt-title\" style=\"margin-top:0\"></h2>\n            <p id=\"prompt-error\"></p>\n            <p id=\"prompt-instruction\"></p>\n            <div id=\"prompt-questions\"></div>\n            <button type=\"submit\" id=\"prompt-ok\">确认</button>\n            <button type=\"button\" id=\"prompt-cancel\">取消</button>\n        </form>\n        <div id=\"options\" class=\"hide\">\n            <h2 style=\"margin-top:0\">选项</h2>\n            <p>\n                <label><input type=\"checkbox\" id=\"option-cursor-blink\"> 光标闪烁</label>\n            </p>\n            <p>\n                <label>\n                    光标样式\n                    <select id=\"option-cursor-style\">\n                        <option value=\"block\">block</option>\n                        <option value=\"underline\">underline</option>\n                        <option value=\"bar\">bar</option>\n                    </select>\n                </label>\n            </p>\n            <p>\n                <label>\n                    铃声(试验性功能)\n                    <select id=\"option-bell-style\">\n                        <option value=\"\">none</option>\n                        <option value=\"sound\">sound</option>\n                        <option value=\"visual\">visual</option>\n                        <option value=\"both\">both</option>\n                    </select>\n                </label>\n            </p>\n            <p>\n                <label>屏幕缓冲区 <input type=\"number\" id=\"option-scrollback\" value=\"1000\"/></label>\n            </p>\n            <p>\n                <label>Tab 字符宽度 <input type=\"number\" id=\"option-tabstopwidth\" value=\"8\"/></label>\n            </p>\n            <div>\n                <h3>大小</h3>\n                <p>\n                    <label for=\"cols\">列</label>\n                    <input type=\"number\" id=\"cols\" value=\"80\"/>\n                </p>\n                <p>\n                    <label for=\"rows\">行</label>\n                    <input type=\"number\" id=\"rows\" value=\"32\"/>\n                </p>\n            </div>\n        </div>\n    </div>\n</div>\n<div id=\"forward-panel\" class=\"hide\">\n    <h2>端口转发</h2>\n    <p>\n        <select id=\"forward-type\">\n            <option value=\"remote\">远程转发 (-R)</option>\n            <option value=\"dynamic\">动态转发 (-D)</option>\n        </select>\n        <label>监听 <input id=\"forward-listen\" placeholder=\"127.0.0.1:8080\"/></label>\n        <label>目标 <input id=\"forward-target\" placeholder=\"10.0.0.5:80\"/></label>\n        <button id=\"forward-start\">启动</button>\n    </p>\n    <p id=\"forward-error\"></p>\n    <table>\n        <thead>\n        <tr><th>类型</th><th>监听</th><th>目标</th><th>连接</th><th>接收</th><th>发送</th><th></th></tr>\n        </thead>\n        <tbody id=\"forward-list\"></tbody>\n    </table>\n</div>\n<div id=\"terminal-container\"></div>\n<div id=\"zmodem_controls\">\n    <form id=\"zm_start\" style=\"display: none\" action=\"javascript:void(0)\">\n        ZMODEM detected: Start ZMODEM session?\n        <label><input id=\"zmstart_yes\" name=\"zmstart\" type=radio checked value=\"1\"> Yes</label>\n        &nbsp;\n        <label><input name=\"zmstart\" type=radio value=\"\"> No</label>\n        <button type=\"submit\">Submit</button>\n    </form>\n\n    <form id=\"zm_offer\" style=\"display: none\" action=\"javascript:void(0)\">\n        <p>ZMODEM File offered!</p>\n\n        <label><input id=\"zmaccept_yes\" name=\"zmaccept\" type=radio checked value=\"1\"> Accept</label>\n        &nbsp;\n        <label><input name=\"zmaccept\" type=radio value=\"\"> Skip</label>\n        <button type=\"submit\">Submit</button>\n    </form>\n\n    <div id=\"zm_file\" style=\"display: none\">\n        <div>Name: <span id=\"name\"></span></div>\n        <div>Size: <span id=\"size\"></span></div>\n        <div>Last modified: <span id=\"mtime\"></span></div>\n        <div>Mode: <span id=\"mode\"></span></div>\n        <br>\n        <div>Conversion: <span id=\"zfile_conversion\"></span></div>\n        <div>Management: <span id=\"zfile_management\"></span></div>\n        <div>Transport: <span id=\"zfile_transport\"></span></div>\n        <div>Sparse? <span id=\"zfile_sparse\"></span></div>\n        <br>\n        <div>Files remaining in batch: <span id=\"files_remaining\"></span></div>\n        <div>Bytes remaining in batch: <span id=\"bytes_remaining\"></span></div>\n    </div>\n\n    <form id=\"zm_progress\" style=\"display: none\" action=\"javascript:void(0)\">\n        <div><span id=\"percent_received\"></span>% (<span id=\"bytes_received\"></span> bytes) received</div>\n        <button id=\"zm_progress_skipper\" type=\"button\" onclick=\"skip_current_file();\">Skip File</button>\n    </form>\n\n    <form id=\"zm_choose\" style=\"display: none\" action=\"javascript:void(0)\">\n        <label>Choose file(s): <input id=\"zm_files\" type=\"file\" multiple></label>\n    </form>\n</div>\n<script src=\"./main.js\"></script>\n</body>\n</html>\n"),
 	}
 	filew := &embedded.EmbeddedFile{
 		Filename:    "xterm.css",
@@ -9,6 +9,21 @@ Terminal.applyAddon(winptyCompat);
 var term,
     socket
 
+// 会话恢复：服务端发送的恢复令牌和已收到的输出字节数，断线后在宽限期内重新连接
+var resumeState = {
+      url: null,
+      token: null,
+      offset: 0,
+      grace: 0,
+      deadline: 0
+    }
+
+// 会话共享：本连接的角色（owner、readwrite 或 readonly）和参与者 id
+var shareState = {
+      role: null,
+      id: null
+    }
+
 var terminalContainer = document.getElementById('terminal-container'),
     actionElements = {
       findText: document.getElementById('find-text'),
@@ -34,13 +49,19 @@ var terminalContainer = document.getElementById('terminal-container'),
 
 var urlPrefix = getQueryStringByName("url_prefix")
 var protocol = getQueryStringByName("protocol")
-var hostname = getQueryStringByName("hostname")
+var hostname = decodeURIComponent(getQueryStringByName("hostname"))
 var file = getQueryStringByName("file")
+var recordingId = getQueryStringByName("id")
 var port = getQueryStringByName("port")
 var cmd = getQueryStringByName("cmd")
 var is_debug = getQueryStringByName("debug")
-var user = getQueryStringByName("user")
-var password = getQueryStringByName("password")
+var user = decodeURIComponent(getQueryStringByName("user"))
+var password = decodeURIComponent(getQueryStringByName("password"))
+var token = decodeURIComponent(getQueryStringByName("token"))
+var profile = getQueryStringByName("profile")
+var hostAlias = getQueryStringByName("host")
+var shareToken = getQueryStringByName("share")
+var terminalTypes = decodeURIComponent(getQueryStringByName("ttype"))
 
 //根据QueryString参数名称获取值
 function getQueryStringByName(name) {
@@ -155,21 +176,99 @@ optionElements.tabstopwidth.addEventListener('change', function () {
 });
 
 function connect() {
-    if(protocol == "ssh") {
-      if (undefined == password || null == password || "" == password) {
+    if (shareToken) {
+        // 通过共享链接加入他人的 ssh 会话
+        createTerminal("ws://" + document.location.host + urlPrefix + "/ssh?share=" + encodeURIComponent(shareToken));
+        return
+    }
+    if ((profile || hostAlias) && ("ssh" == protocol || "ssh_exec" == protocol || "forward" == protocol)) {
+        // 使用服务端保存的主机配置或 ssh config 中的主机别名，无需凭据
+        var profile_url = "ws://" + document.location.host + urlPrefix + "/" + protocol
+        if (profile) {
+            profile_url += "?profile=" + profile
+        } else {
+            profile_url += "?host=" + hostAlias
+        }
+        profile_url += "&debug=" + is_debug
+        if ("ssh_exec" == protocol) {
+            profile_url += "&dump_file=" + encodeURIComponent(file) + "&cmd=" + encodeURIComponent(cmd)
+        }
+        if ("forward" == protocol) {
+            openForward(profile_url);
+            return
+        }
+        createTerminal(profile_url);
+        return
+    }
+    // 密码为空时由服务端弹出密码对话框
+    if(protocol == "ssh" || protocol == "forward") {
+      if (undefined == user || null == user || "" == user) {
         toggleLogin()
         return
       }
     }
     
-    var target_url = "ws://" + document.location.host + urlPrefix + "/" + protocol + "?hostname=" + hostname + "&port=" + port + "&user=" + encodeURIComponent(user) + "&password=" + encodeURIComponent(password) + "&debug=" + is_debug
+    var base_url = "ws://" + document.location.host + urlPrefix + "/" + protocol
     if ("replay" == protocol) {
-        target_url = "ws://" + document.location.host + urlPrefix + "/" + protocol + "?file=" + encodeURIComponent(file) + "&user=" + encodeURIComponent(user) + "&password=" + encodeURIComponent(password)
-    } else if ("ssh_exec" == protocol) {
-        target_url = "ws://" + document.location.host + urlPrefix + "/" + protocol + "?dump_file=" + encodeURIComponent(file) + "&hostname=" + hostname + "&port=" + port + "&user=" + encodeURIComponent(user) + "&password=" + encodeURIComponent(password) + "&cmd=" + encodeURIComponent(cmd) + "&debug=" + is_debug
+        createTerminal(base_url + "?id=" + encodeURIComponent(recordingId));
+        return
+    }
+    if ("local" == protocol) {
+        // 本地伪终端，cmd 参数是 -local_allow 允许的程序，默认运行 shell
+        createTerminal(base_url + "?exec=" + encodeURIComponent(cmd || ""));
+        return
+    }
+    if ("telnet" != protocol && "ssh" != protocol && "ssh_exec" != protocol && "forward" != protocol) {
+        createTerminal(base_url + "?debug=" + is_debug);
+        return
     }
 
-    createTerminal(target_url);
+    // 凭据通过 POST 换取一次性票据，不出现在 websocket 地址中
+    requestTicket({
+        protocol: protocol,
+        hostname: hostname,
+        port: parseInt(port, 10) || 0,
+        user: user,
+        password: password,
+        terminalTypes: terminalTypes
+    }, function (ticket) {
+        var target_url = base_url + "?ticket=" + encodeURIComponent(ticket) + "&debug=" + is_debug
+        if ("ssh_exec" == protocol) {
+            target_url += "&dump_file=" + encodeURIComponent(file) + "&cmd=" + encodeURIComponent(cmd)
+        }
+        if ("forward" == protocol) {
+            openForward(target_url);
+            return
+        }
+        createTerminal(target_url);
+    });
+}
+
+function requestTicket(params, callback) {
+    var xhr = new XMLHttpRequest();
+    var url = urlPrefix + "/ticket";
+    if (token) {
+        url += "?token=" + encodeURIComponent(token);
+    }
+    xhr.open("POST", url, true);
+    xhr.setRequestHeader("Content-Type", "application/json");
+    xhr.onload = function () {
+        var result = {};
+        try {
+            result = JSON.parse(xhr.responseText);
+        } catch (e) {
+            result.error = xhr.responseText;
+        }
+        if (xhr.status != 200 || !result.ticket) {
+            alert("获取连接票据失败：" + (result.error || xhr.status));
+            return
+        }
+        callback(result.ticket);
+    };
+    xhr.onerror = function () {
+        alert("获取连接票据失败！");
+    };
+    xhr.send(JSON.stringify(params));
 }
 
 function createTerminal(targetUrl) {
@@ -204,20 +303,453 @@ function createTerminal(targetUrl) {
     // Set terminal size again to set the specific dimensions on the demo
     setTerminalSize();
 
-    socket = new WebSocket(targetUrl + '&columns=' + term.cols + '&rows=' + term.rows);
-    socket.onopen = function() {
-      term.attach(socket);
-      term._initialized = true;
-    };
-    socket.onclose = function() {
-      //term.destroy();
-    };
-    socket.onerror = function() {
-      alert("连接出错！");
-    };
+    if (token) {
+      targetUrl += '&token=' + encodeURIComponent(token);
+    }
+    resumeState.url = targetUrl.split('?')[0];
+    resumeState.token = null;
+    openSocket(targetUrl + '&columns=' + term.cols + '&rows=' + term.rows, false);
   }, 0);
 }
 
+function openSocket(targetUrl, resuming) {
+  // 使用 web-terminal.v1 消息格式，不声明子协议的旧客户端收发原始文本
+  socket = new WebSocket(targetUrl, ["web-terminal.v1"]);
+  socket.onopen = function() {
+    attachMessageSocket(term, socket);
+    term._initialized = true;
+  };
+  socket.onclose = function(ev) {
+    // 1000 是服务端正常关闭（会话结束或被其他连接接管），其他情况视为网络中断
+    if (ev.code != 1000 && resumeState.token) {
+      if (!resuming) {
+        resumeState.deadline = Date.now() + resumeState.grace * 1000;
+        term.write("\r\n\x1b[33m[connection interrupted, reconnecting...]\x1b[0m\r\n");
+      }
+      setTimeout(resumeSocket, 2000);
+    }
+  };
+  socket.onerror = function() {
+    if (!resuming) {
+      alert("连接出错！");
+    }
+  };
+}
+
+function resumeSocket() {
+  if (Date.now() > resumeState.deadline) {
+    term.write("\r\n\x1b[31m[the session expired]\x1b[0m\r\n");
+    return
+  }
+  var url = resumeState.url + "?resume=" + encodeURIComponent(resumeState.token) + "&offset=" + resumeState.offset;
+  if (token) {
+    url += '&token=' + encodeURIComponent(token);
+  }
+  openSocket(url, true);
+}
+
+function encodeData(str) {
+  var bytes = new TextEncoder().encode(str);
+  var binary = "";
+  for (var i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary);
+}
+
+function decodeData(data) {
+  var binary = atob(data);
+  var bytes = new Uint8Array(binary.length);
+  for (var i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes;
+}
+
+// handleControl 处理 prompt、session 等控制消息，不是控制消息时返回 false
+function handleControl(msg, socket) {
+  switch (msg.type) {
+  case "prompt":
+    showPrompt(msg, socket);
+    break;
+  case "algorithms":
+    showAlgorithms(msg);
+    break;
+  case "terminal_type":
+    // telnet 协商的终端类型，服务端可能依次询问多个类型
+    document.getElementById('algorithms').textContent = "TERM=" + msg.name;
+    break;
+  case "connection_lost":
+    // 主机不再响应 keepalive，连接已断开
+    term.write("\r\n\x1b[31m" + msg.reason + "\x1b[0m\r\n");
+    resumeState.token = null;
+    break;
+  case "session":
+  case "resumed":
+    resumeState.token = msg.token;
+    resumeState.offset = msg.offset;
+    resumeState.grace = msg.grace;
+    setShareRole(msg.role, null);
+    break;
+  case "joined":
+    setShareRole(msg.role, msg.id);
+    break;
+  case "presence":
+    showParticipants(msg.participants);
+    break;
+  case "share":
+    showShareLink(msg);
+    break;
+  case "input_denied":
+    term.write("\r\n\x1b[33m[" + msg.reason + (msg.controller ? ": " + msg.controller : "") + "]\x1b[0m\r\n");
+    break;
+  default:
+    return false;
+  }
+  return true;
+}
+
+// attachMessageSocket 使用 JSON 消息：{"v":1,"type":"stdin|stdout|stderr|resize|signal|exit","data":"base64"}，其他类型是控制消息
+function attachMessageSocket(term, socket) {
+  var decoder = new TextDecoder();
+  var send = function (msg) {
+    if (socket.readyState == 1) {
+      msg.v = 1;
+      socket.send(JSON.stringify(msg));
+    }
+  };
+  var onData = function (data) {
+    send({type: "stdin", data: encodeData(data)});
+  };
+  var onResize = function (size) {
+    send({type: "resize", cols: size.cols, rows: size.rows});
+  };
+  term.on('data', onData);
+  term.on('resize', onResize);
+  socket.addEventListener('message', function (ev) {
+    var msg = JSON.parse(ev.data);
+    if (handleControl(msg, socket)) {
+      return
+    }
+    var data = "";
+    if (msg.data) {
+      var bytes = decodeData(msg.data);
+      if ("stdout" == msg.type) {
+        // 恢复会话时从该位置重放输出
+        resumeState.offset += bytes.length;
+      }
+      data = decoder.decode(bytes, {stream: "exit" != msg.type});
+    }
+    switch (msg.type) {
+    case "exit":
+      term.write("\r\n\x1b[33m[" + data + "]\x1b[0m\r\n");
+      break;
+    case "alert":
+      alert(data);
+      break;
+    case "stdout":
+    case "stderr":
+    case "console":
+      term.write(data);
+      break;
+    }
+  });
+  socket.addEventListener('close', function () {
+    term.off('data', onData);
+    term.off('resize', onResize);
+  });
+  onResize({cols: term.cols, rows: term.rows});
+}
+
+// setShareRole 根据角色显示共享或接管输入按钮
+function setShareRole(role, id) {
+  shareState.role = role;
+  shareState.id = id;
+  var shareActions = document.getElementById('share-actions');
+  var takeControl = document.getElementById('take-control');
+  if ("owner" == role) {
+    changeClassList(shareActions, "active", "hide");
+  } else {
+    changeClassList(shareActions, "hide", "active");
+  }
+  if ("readonly" == role) {
+    changeClassList(takeControl, "hide", "active");
+  } else {
+    changeClassList(takeControl, "active", "hide");
+  }
+}
+
+function sendControl(control) {
+  if (socket && socket.readyState == 1) {
+    control.v = 1;
+    socket.send(JSON.stringify(control));
+  }
+}
+
+document.getElementById('share-readonly').addEventListener('click', function () {
+  sendControl({type: "share", role: "readonly"});
+});
+document.getElementById('share-readwrite').addEventListener('click', function () {
+  sendControl({type: "share", role: "readwrite"});
+});
+document.getElementById('take-control').addEventListener('click', function () {
+  sendControl({type: "control"});
+  term.focus();
+});
+
+// telnet 的 BRK、IP 和 AO 命令
+function sendTelnetSignal(signal) {
+  sendControl({type: "signal", signal: signal});
+  term.focus();
+}
+
+document.getElementById('send-break').addEventListener('click', function () {
+  sendTelnetSignal("BRK");
+});
+document.getElementById('send-ip').addEventListener('click', function () {
+  sendTelnetSignal("INT");
+});
+document.getElementById('send-ao').addEventListener('click', function () {
+  sendTelnetSignal("AO");
+});
+
+// 显示共享链接，链接在过期、会话结束或被撤销前有效
+function showShareLink(share) {
+  if (share.error) {
+    alert("共享失败：" + share.error);
+    return
+  }
+  var url = document.location.origin + document.location.pathname + "?protocol=ssh&share=" + encodeURIComponent(share.token);
+  if (urlPrefix) {
+    url += "&url_prefix=" + encodeURIComponent(urlPrefix);
+  }
+  var label = ("readonly" == share.role ? "只读" : "协作") + "共享链接";
+  if (share.expires) {
+    label += "（" + new Date(share.expires).toLocaleString() + " 前有效）";
+  }
+  window.prompt(label + "：", url);
+}
+
+// 显示会话参与者，* 表示当前的输入者，会话所有者可以踢出其他参与者
+function showParticipants(participants) {
+  var el = document.getElementById('participants');
+  while (el.children.length) {
+    el.removeChild(el.children[0]);
+  }
+  for (var i = 0; i < participants.length; i++) {
+    var p = participants[i];
+    var item = document.createElement('span');
+    item.style.marginRight = "6px";
+    item.textContent = (p.control ? "*" : "") + (p.name || "anonymous") + "(" + p.role + ")";
+    if (p.id == shareState.id) {
+      item.style.fontWeight = "bold";
+    }
+    if ("owner" == shareState.role && "owner" != p.role) {
+      var revoke = document.createElement('a');
+      revoke.href = "javascript:void(0)";
+      revoke.textContent = "×";
+      revoke.title = "撤销";
+      revoke.onclick = (function (id) {
+        return function () {
+          sendControl({type: "revoke", id: id});
+        };
+      })(p.id);
+      item.appendChild(revoke);
+    }
+    el.appendChild(item);
+  }
+}
+
+// 端口转发：不打开终端，在页面上启动、列出和停止远程(-R)和动态(-D)转发
+var forwardElements = {
+      panel: document.getElementById('forward-panel'),
+      type: document.getElementById('forward-type'),
+      listen: document.getElementById('forward-listen'),
+      target: document.getElementById('forward-target'),
+      start: document.getElementById('forward-start'),
+      error: document.getElementById('forward-error'),
+      list: document.getElementById('forward-list')
+    }
+
+function openForward(targetUrl) {
+  if (token) {
+    targetUrl += '&token=' + encodeURIComponent(token);
+  }
+  changeClassList(forwardElements.panel, "active", "hide");
+  var timer = null;
+  socket = new WebSocket(targetUrl, ["web-terminal.v1"]);
+  socket.onopen = function () {
+    sendControl({type: "stats"});
+    timer = setInterval(function () {
+      sendControl({type: "stats"});
+    }, 2000);
+  };
+  socket.onmessage = function (ev) {
+    if ("string" != typeof ev.data) {
+      return
+    }
+    var msg = JSON.parse(ev.data);
+    switch (msg.type) {
+    case "prompt":
+      showPrompt(msg, socket);
+      break;
+    case "stats":
+      showForwards(msg.forwards || []);
+      break;
+    case "started":
+    case "stopped":
+      forwardElements.error.textContent = "";
+      sendControl({type: "stats"});
+      break;
+    case "error":
+      forwardElements.error.textContent = (msg.target || msg.listen || msg.id || "") + " " + msg.error;
+      break;
+    case "stderr":
+      forwardElements.error.textContent = new TextDecoder().decode(decodeData(msg.data));
+      break;
+    }
+  };
+  socket.onclose = function () {
+    clearInterval(timer);
+    forwardElements.start.disabled = true;
+    forwardElements.error.textContent += " [connection closed]";
+  };
+}
+
+forwardElements.type.addEventListener('change', function () {
+  forwardElements.target.disabled = "dynamic" == forwardElements.type.value;
+});
+forwardElements.start.addEventListener('click', function () {
+  var msg = {type: forwardElements.type.value, listen: forwardElements.listen.value};
+  if ("remote" == msg.type) {
+    msg.target = forwardElements.target.value;
+  }
+  sendControl(msg);
+});
+
+function formatBytes(n) {
+  if (n >= 1048576) {
+    return (n / 1048576).toFixed(1) + "MB";
+  }
+  if (n >= 1024) {
+    return (n / 1024).toFixed(1) + "KB";
+  }
+  return n + "B";
+}
+
+// 列出 remote 和 dynamic 转发，本地转发(-L)的连接由其它客户端通过 open 消息建立
+function showForwards(forwards) {
+  var el = forwardElements.list;
+  while (el.children.length) {
+    el.removeChild(el.children[0]);
+  }
+  for (var i = 0; i < forwards.length; i++) {
+    var f = forwards[i];
+    if ("local" == f.type) {
+      continue
+    }
+    var row = document.createElement('tr');
+    var cells = [f.type, f.listen, f.target || "SOCKS5", f.connections + "/" + f.total, formatBytes(f.bytesIn), formatBytes(f.bytesOut)];
+    for (var j = 0; j < cells.length; j++) {
+      var td = document.createElement('td');
+      td.textContent = cells[j];
+      row.appendChild(td);
+    }
+    var stop = document.createElement('button');
+    stop.textContent = "停止";
+    stop.onclick = (function (id) {
+      return function () {
+        sendControl({type: "stop", id: id});
+      };
+    })(f.id);
+    var td = document.createElement('td');
+    td.appendChild(stop);
+    row.appendChild(td);
+    el.appendChild(row);
+  }
+}
+
+// 显示与主机协商的算法
+function showAlgorithms(algorithms) {
+  var el = document.getElementById('algorithms');
+  var summary = [algorithms.kex, algorithms.cipher];
+  if (algorithms.mac) {
+    summary.push(algorithms.mac);
+  }
+  el.textContent = summary.join(" / ");
+  el.title = "host: " + algorithms.host +
+    "\nkex: " + algorithms.kex +
+    "\nhost key: " + algorithms.hostKey +
+    "\ncipher: " + algorithms.cipher + (algorithms.serverCipher && algorithms.serverCipher != algorithms.cipher ? " / " + algorithms.serverCipher : "") +
+    "\nmac: " + (algorithms.mac || "(aead)") + (algorithms.serverMac && algorithms.serverMac != algorithms.mac ? " / " + algorithms.serverMac : "");
+}
+
+function showPrompt(prompt, socket) {
+  var form = document.getElementById('prompt');
+  var questionsEl = document.getElementById('prompt-questions');
+  var title = prompt.host || "";
+  if (prompt.user) {
+    title = prompt.user + "@" + title;
+  }
+  document.getElementById('prompt-title').textContent = prompt.name || title;
+  document.getElementById('prompt-error').textContent = prompt.error || "";
+  document.getElementById('prompt-instruction').textContent = prompt.instruction || "";
+  while (questionsEl.children.length) {
+    questionsEl.removeChild(questionsEl.children[0]);
+  }
+  var inputs = [];
+  if ("confirm" != prompt.kind) {
+    for (var i = 0; i < prompt.questions.length; i++) {
+      var label = document.createElement('label');
+      var input = document.createElement('input');
+      input.type = prompt.questions[i].echo ? "text" : "password";
+      input.autocomplete = "off";
+      label.appendChild(document.createTextNode(prompt.questions[i].text + " "));
+      label.appendChild(input);
+      var p = document.createElement('p');
+      p.appendChild(label);
+      questionsEl.appendChild(p);
+      inputs.push(input);
+    }
+  } else if (prompt.questions.length > 0) {
+    questionsEl.textContent = prompt.questions[0].text;
+  }
+
+  function reply(answer) {
+    form.onsubmit = null;
+    document.getElementById('prompt-cancel').onclick = null;
+    changeClassList(form, "hide", "active");
+    answer.v = 1;
+    socket.send(JSON.stringify(answer));
+    if (term) {
+      term.focus();
+    }
+  }
+  form.onsubmit = function () {
+    var answers = [];
+    if ("confirm" == prompt.kind) {
+      answers.push("yes");
+    } else {
+      for (var i = 0; i < inputs.length; i++) {
+        answers.push(inputs[i].value);
+      }
+    }
+    reply({type: "prompt_answer", id: prompt.id, answers: answers});
+    return false;
+  };
+  document.getElementById('prompt-cancel').onclick = function () {
+    if ("confirm" == prompt.kind) {
+      reply({type: "prompt_answer", id: prompt.id, answers: ["no"]});
+    } else {
+      reply({type: "prompt_answer", id: prompt.id, cancel: true});
+    }
+  };
+  changeClassList(form, "active", "hide");
+  if (inputs.length > 0) {
+    inputs[0].focus();
+  }
+}
+
 window.addEventListener('load', function () {
     if (undefined == protocol || null == protocol || "" == protocol) {
         protocol = "ssh"
@@ -228,18 +760,19 @@ window.addEventListener('load', function () {
         if (undefined == port || null == port || "" == port) {
             port = "23"
         }
-    } else if ("ssh" == protocol) {
+        changeClassList(document.getElementById('telnet-actions'), "active", "hide");
+    } else if ("ssh" == protocol || "forward" == protocol) {
         if (undefined == port || null == port || "" == port) {
             port = "22"
         }
     }
 
     if ("replay" == protocol) {
-        if (undefined == file || null == file || "" == file) {
-            alert("file is empty.")
+        if (undefined == recordingId || null == recordingId || "" == recordingId) {
+            alert("id is empty.")
             return
         }
-    } else {
+    } else if (!profile) {
         if (undefined == hostname || null == hostname || "" == hostname) {
             alert("hostname is empty.")
             return